@@ -0,0 +1,398 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// dogeChainConfig 构建inscription交易链的参数，由InscriptionBuilder的选项或旧的Build函数的参数生成
+type dogeChainConfig struct {
+	netParam      *chaincfg.Params
+	feeRate       int64 // satoshis/B
+	lockValue     int64 // 每个P2SH输出的金额
+	maxPayloadLen int   // 每个partial的最大长度
+	outputAddress string
+	outputValue   int64 // 为0时为100000
+	changeAddress string
+	extra         *DogeExtraOutputs
+	revealInputs  []*TxInputUtxo // 必须由reveal交易花费的UTXO，不用于支付手续费的选币
+	revealOutputs []*wire.TxOut  // 紧随reveal输出的输出，例如MRC-20的token输出
+	signer        DogeSigner
+	logger        *slog.Logger            // 为nil时使用SetDogeLogger设置的日志
+	progress      func(DogeBuildProgress) // 每构建完一笔交易调用一次，可以为nil
+}
+
+// newDogeChainConfig 使用默认的lock金额、partial长度和PriHex签名生成交易链参数
+func newDogeChainConfig(
+	netParam *chaincfg.Params,
+	outputAddress string,
+	outputValue int64,
+	changeAddress string,
+	feeRate int64,
+	extra *DogeExtraOutputs,
+) *dogeChainConfig {
+	return &dogeChainConfig{
+		netParam:      netParam,
+		feeRate:       feeRate,
+		lockValue:     100000,
+		maxPayloadLen: int(MAX_PAYLOAD_LEN),
+		outputAddress: outputAddress,
+		outputValue:   outputValue,
+		changeAddress: changeAddress,
+		extra:         extra,
+		signer:        DogePriHexSigner,
+	}
+}
+
+// log 返回交易链使用的日志
+func (c *dogeChainConfig) log() *slog.Logger {
+	if c.logger != nil {
+		return c.logger
+	}
+	return dogeLog()
+}
+
+// DogeSigner 为交易中钱包UTXO的输入签名
+// 交易链中后一笔交易引用前一笔的txid，所以每笔交易在构建时立即签名，
+// utxos[i]对应tx.TxIn[startIndex+i]
+type DogeSigner interface {
+	SignInputs(ctx context.Context, tx *wire.MsgTx, utxos []*TxInputUtxo, startIndex int) error
+}
+
+// DogeSignerFunc 把函数转换为DogeSigner
+type DogeSignerFunc func(ctx context.Context, tx *wire.MsgTx, utxos []*TxInputUtxo, startIndex int) error
+
+// SignInputs 实现DogeSigner
+func (f DogeSignerFunc) SignInputs(ctx context.Context, tx *wire.MsgTx, utxos []*TxInputUtxo, startIndex int) error {
+	return f(ctx, tx, utxos, startIndex)
+}
+
+// DogePriHexSigner 使用TxInputUtxo.PriHex进行P2PKH签名，是默认的签名方式
+var DogePriHexSigner DogeSigner = DogeSignerFunc(func(ctx context.Context, tx *wire.MsgTx, utxos []*TxInputUtxo, startIndex int) error {
+	return signTransactionInputs(tx, utxos, startIndex)
+})
+
+// DogeCoinSelector 在构建前决定使用哪些UTXO以及使用顺序，fund按返回的顺序依次使用
+type DogeCoinSelector func(utxos []*TxInputUtxo) ([]*TxInputUtxo, error)
+
+// InscriptionBuilder 构建inscription交易链
+// 通过NewInscriptionBuilder和InscriptionOption设置参数，所有参数在构建前校验，
+// 需要新参数时增加选项，而不是增加BuildDogeMetaIdInscriptionTxs的位置参数
+type InscriptionBuilder struct {
+	cfg         dogeChainConfig
+	chunkLen    int
+	utxos       []*TxInputUtxo
+	selector    DogeCoinSelector
+	keySource   func() (*btcec.PrivateKey, error)
+	buildScript func(chunkLen int) ([]byte, error) // 按chunkLen构建inscription脚本
+	deferred    []func() error                     // 依赖其他选项（例如网络）的选项，在validate开始时执行
+}
+
+// InscriptionOption InscriptionBuilder的选项，参数不合法时返回error
+type InscriptionOption func(b *InscriptionBuilder) error
+
+// NewInscriptionBuilder 创建InscriptionBuilder并校验所有选项
+// 必须设置inscription内容（WithDoginal、WithMetaIdPin或WithInscriptionScript）、
+// WithUtxos、WithChangeAddress和WithFeeRate；reveal接收地址默认为找零地址
+func NewInscriptionBuilder(opts ...InscriptionOption) (*InscriptionBuilder, error) {
+	b := &InscriptionBuilder{
+		cfg:       *newDogeChainConfig(DogeMainNetParams, "", 0, "", 0, nil),
+		chunkLen:  int(MAX_CHUNK_LEN),
+		keySource: btcec.NewPrivateKey,
+	}
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
+		}
+	}
+	if err := b.validate(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// validate 校验选项之间的组合，地址需要在确定网络之后才能校验
+func (b *InscriptionBuilder) validate() error {
+	for _, fn := range b.deferred {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	if b.buildScript == nil {
+		return fmt.Errorf("%w: 没有设置inscription内容", ErrInvalidOption)
+	}
+	if len(b.utxos) == 0 {
+		return fmt.Errorf("%w: 没有设置UTXO", ErrInvalidOption)
+	}
+	if b.cfg.feeRate <= 0 {
+		return fmt.Errorf("%w: 没有设置费率", ErrInvalidOption)
+	}
+	if b.cfg.changeAddress == "" {
+		return fmt.Errorf("%w: 没有设置找零地址", ErrInvalidOption)
+	}
+	if _, err := decodeDogeAddress(b.cfg.changeAddress, b.cfg.netParam); err != nil {
+		return fmt.Errorf("找零地址: %w", err)
+	}
+	if b.cfg.outputAddress == "" {
+		b.cfg.outputAddress = b.cfg.changeAddress
+	}
+	if _, err := decodeDogeAddress(b.cfg.outputAddress, b.cfg.netParam); err != nil {
+		return fmt.Errorf("reveal接收地址: %w", err)
+	}
+	if _, err := b.cfg.extra.txOuts(b.cfg.netParam); err != nil {
+		return err
+	}
+	// commit交易至少要锁定lockValue
+	available := int64(0)
+	for _, utxo := range b.utxos {
+		available += int64(utxo.Amount)
+	}
+	if available < b.cfg.lockValue {
+		return &ErrInsufficientFunds{Needed: b.cfg.lockValue, Available: available}
+	}
+	return nil
+}
+
+// Build 构建交易链，返回每笔交易和费用明细
+// ctx在构建过程中被取消时返回*ErrBuildCancelled，可以用其中的Session继续构建
+func (b *InscriptionBuilder) Build(ctx context.Context) (*DogeInscriptionResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	inscriptionScript, err := b.buildScript(b.chunkLen)
+	if err != nil {
+		return nil, fmt.Errorf("构建inscription脚本失败: %w", err)
+	}
+	utxos := b.utxos
+	if b.selector != nil {
+		utxos, err = b.selector(utxos)
+		if err != nil {
+			return nil, fmt.Errorf("选择UTXO失败: %w", err)
+		}
+	}
+	privateKey, err := b.keySource()
+	if err != nil {
+		return nil, fmt.Errorf("生成私钥失败: %w", err)
+	}
+	cfg := b.cfg
+	result, _, err := buildDogeInscriptionChain(ctx, &cfg, privateKey, inscriptionScript, utxos)
+	return result, err
+}
+
+// setContent 设置inscription内容，只能设置一次
+func (b *InscriptionBuilder) setContent(buildScript func(chunkLen int) ([]byte, error)) error {
+	if b.buildScript != nil {
+		return fmt.Errorf("%w: inscription内容只能设置一次", ErrInvalidOption)
+	}
+	b.buildScript = buildScript
+	return nil
+}
+
+// WithDoginal 铸造Doginal格式的inscription
+func WithDoginal(data []byte, contentType string) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		return b.setContent(func(chunkLen int) ([]byte, error) {
+			return buildDoginalInscription(data, contentType, chunkLen)
+		})
+	}
+}
+
+// WithMetaIdPin 铸造MetaID PIN，加密的PIN需要Body已经是加密后的内容
+func WithMetaIdPin(pin *DogeMetaIdPin) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if pin == nil {
+			return fmt.Errorf("%w: PIN为空", ErrInvalidOption)
+		}
+		return b.setContent(func(chunkLen int) ([]byte, error) {
+			return buildDogeMetaIdPinInscription(pin, chunkLen)
+		})
+	}
+}
+
+// WithInscriptionScript 铸造已经构建好的inscription脚本，WithChunkSizes的chunkLen对其无效
+func WithInscriptionScript(inscriptionScript []byte) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if len(inscriptionScript) == 0 {
+			return fmt.Errorf("%w: inscription脚本为空", ErrInvalidOption)
+		}
+		return b.setContent(func(int) ([]byte, error) {
+			return inscriptionScript, nil
+		})
+	}
+}
+
+// WithNetwork 设置网络，默认DogeMainNetParams
+func WithNetwork(netParam *chaincfg.Params) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if netParam == nil {
+			return fmt.Errorf("%w: 网络参数为空", ErrInvalidOption)
+		}
+		b.cfg.netParam = netParam
+		return nil
+	}
+}
+
+// WithFeeRate 设置费率，单位satoshis/B
+func WithFeeRate(feeRate int64) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if feeRate <= 0 {
+			return fmt.Errorf("%w: 费率必须大于0: %d", ErrInvalidOption, feeRate)
+		}
+		b.cfg.feeRate = feeRate
+		return nil
+	}
+}
+
+// WithUtxos 设置用于支付的钱包UTXO
+func WithUtxos(utxos []*TxInputUtxo) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		for i, utxo := range utxos {
+			if utxo == nil {
+				return fmt.Errorf("%w: 第%d个UTXO为空", ErrInvalidOption, i)
+			}
+		}
+		b.utxos = utxos
+		return nil
+	}
+}
+
+// WithCoinSelector 设置选币方式，默认按传入的顺序使用UTXO
+func WithCoinSelector(selector DogeCoinSelector) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if selector == nil {
+			return fmt.Errorf("%w: 选币函数为空", ErrInvalidOption)
+		}
+		b.selector = selector
+		return nil
+	}
+}
+
+// WithSigner 设置钱包UTXO的签名方式，默认DogePriHexSigner
+func WithSigner(signer DogeSigner) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if signer == nil {
+			return fmt.Errorf("%w: 签名器为空", ErrInvalidOption)
+		}
+		b.cfg.signer = signer
+		return nil
+	}
+}
+
+// WithChunkSizes 设置数据块长度（默认MAX_CHUNK_LEN）和每个partial的最大长度（默认MAX_PAYLOAD_LEN）
+func WithChunkSizes(chunkLen int, maxPayloadLen int) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if chunkLen <= 0 || chunkLen > txscript.MaxScriptElementSize {
+			return fmt.Errorf("%w: 数据块长度必须在1到%d之间: %d", ErrInvalidOption, txscript.MaxScriptElementSize, chunkLen)
+		}
+		if maxPayloadLen < chunkLen || maxPayloadLen > DefaultDogePolicy.MaxScriptSigSize {
+			return fmt.Errorf("%w: partial长度必须在%d到%d之间: %d", ErrInvalidOption, chunkLen, DefaultDogePolicy.MaxScriptSigSize, maxPayloadLen)
+		}
+		b.chunkLen = chunkLen
+		b.cfg.maxPayloadLen = maxPayloadLen
+		return nil
+	}
+}
+
+// WithLockValue 设置每个P2SH输出锁定的金额，默认100000
+func WithLockValue(lockValue int64) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if lockValue < DogeDustLimit {
+			return &ErrDustOutput{Amount: lockValue, Limit: DogeDustLimit}
+		}
+		if lockValue > dogeMaxMoney {
+			return fmt.Errorf("%w: lock金额超出范围: %d", ErrInvalidOption, lockValue)
+		}
+		b.cfg.lockValue = lockValue
+		return nil
+	}
+}
+
+// WithRevealOutput 设置reveal交易的接收地址和金额，默认为找零地址和100000
+func WithRevealOutput(address string, value int64) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if address == "" {
+			return fmt.Errorf("%w: reveal接收地址为空", ErrInvalidOption)
+		}
+		if value < DogeDustLimit {
+			return &ErrDustOutput{Address: address, Amount: value, Limit: DogeDustLimit}
+		}
+		if value > dogeMaxMoney {
+			return fmt.Errorf("%w: reveal输出金额超出范围: %d", ErrInvalidOption, value)
+		}
+		b.cfg.outputAddress = address
+		b.cfg.outputValue = value
+		return nil
+	}
+}
+
+// WithChangeAddress 设置找零地址
+func WithChangeAddress(address string) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if address == "" {
+			return fmt.Errorf("%w: 找零地址为空", ErrInvalidOption)
+		}
+		b.cfg.changeAddress = address
+		return nil
+	}
+}
+
+// WithExtraOutputs 设置服务费和额外输出
+func WithExtraOutputs(extra *DogeExtraOutputs) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		b.cfg.extra = extra
+		return nil
+	}
+}
+
+// WithLogger 设置构建过程使用的日志，默认使用SetDogeLogger设置的日志
+func WithLogger(logger *slog.Logger) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if logger == nil {
+			return fmt.Errorf("%w: 日志为空", ErrInvalidOption)
+		}
+		b.cfg.logger = logger
+		return nil
+	}
+}
+
+// WithKeySource 设置P2SH临时密钥的来源，默认每次构建随机生成
+func WithKeySource(keySource func() (*btcec.PrivateKey, error)) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if keySource == nil {
+			return fmt.Errorf("%w: 密钥来源为空", ErrInvalidOption)
+		}
+		b.keySource = keySource
+		return nil
+	}
+}
+
+// WithProgress 设置进度回调，每构建完一笔交易在构建的goroutine中调用一次
+func WithProgress(progress func(DogeBuildProgress)) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if progress == nil {
+			return fmt.Errorf("%w: 进度回调为空", ErrInvalidOption)
+		}
+		b.cfg.progress = progress
+		return nil
+	}
+}
+
+// WithRevealInputs 设置必须由reveal交易花费的UTXO（例如MRC-20 mint需要的PIN UTXO），
+// 它们紧随P2SH输入，由签名器签名，金额计入reveal交易的输入；WithUtxos中相同的UTXO不会再被选用
+func WithRevealInputs(utxos []*TxInputUtxo) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		for i, utxo := range utxos {
+			if utxo == nil {
+				return fmt.Errorf("%w: 第%d个reveal输入为空", ErrInvalidOption, i)
+			}
+		}
+		b.cfg.revealInputs = utxos
+		return nil
+	}
+}
@@ -0,0 +1,143 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestInscriptionBuilder(t *testing.T) {
+	sim, priHex, address, utxos := testSimulatorWallet(t, 1, 1e8, 50e8)
+	// 签名器从外部取得私钥，传入的UTXO没有PriHex
+	signed := 0
+	signer := DogeSignerFunc(func(ctx context.Context, tx *wire.MsgTx, signUtxos []*TxInputUtxo, startIndex int) error {
+		signed += len(signUtxos)
+		withKeys := make([]*TxInputUtxo, len(signUtxos))
+		for i, utxo := range signUtxos {
+			withKey := *utxo
+			withKey.PriHex = priHex
+			withKeys[i] = &withKey
+		}
+		return DogePriHexSigner.SignInputs(ctx, tx, withKeys, startIndex)
+	})
+	noKeys := make([]*TxInputUtxo, len(utxos))
+	for i, utxo := range utxos {
+		noKey := *utxo
+		noKey.PriHex = ""
+		noKeys[i] = &noKey
+	}
+	key := testPrivateKey(9)
+	builder, err := NewInscriptionBuilder(
+		WithDoginal(bytes.Repeat([]byte{0x89}, 3000), "image/png"),
+		WithUtxos(noKeys),
+		WithChangeAddress(address),
+		WithFeeRate(1000),
+		WithSigner(signer),
+		WithCoinSelector(func(utxos []*TxInputUtxo) ([]*TxInputUtxo, error) {
+			sorted := append([]*TxInputUtxo{}, utxos...)
+			sort.Slice(sorted, func(i, j int) bool { return sorted[i].Amount > sorted[j].Amount })
+			return sorted, nil
+		}),
+		WithChunkSizes(100, 800),
+		WithLockValue(200000),
+		WithRevealOutput(address, 300000),
+		WithKeySource(func() (*btcec.PrivateKey, error) { return key, nil }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+	largest := utxos[0]
+	if utxos[1].Amount > largest.Amount {
+		largest = utxos[1]
+	}
+	if result.Txs[0].TxIn[0].PreviousOutPoint.Hash.String() != largest.TxId || signed == 0 {
+		t.Error("没有使用选币函数或签名器")
+	}
+	for i, tx := range result.Txs[:len(result.Txs)-1] {
+		if tx.TxOut[0].Value != 200000 {
+			t.Errorf("交易%d锁定%d", i, tx.TxOut[0].Value)
+		}
+	}
+	if result.Txs[len(result.Txs)-1].TxOut[0].Value != 300000 {
+		t.Errorf("reveal输出%d", result.Txs[len(result.Txs)-1].TxOut[0].Value)
+	}
+	// 数据块长度100，3000字节分为30块
+	inscription, err := ParseInscriptionFromTx(testTxHex(t, result.Txs[1]), InscriptionFormatDoginal)
+	if err != nil || inscription.PartsCount != 30 {
+		t.Fatalf("%v %+v", err, inscription)
+	}
+
+	// 相同的密钥来源构建的交易链相同
+	builder, err = NewInscriptionBuilder(
+		WithDoginal(bytes.Repeat([]byte{0x89}, 3000), "image/png"),
+		WithUtxos(utxos),
+		WithChangeAddress(address),
+		WithFeeRate(1000),
+		WithKeySource(func() (*btcec.PrivateKey, error) { return key, nil }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := builder.Build(context.Background())
+	if err != nil || first.CommitTxId != second.CommitTxId {
+		t.Errorf("两次构建的commit交易不同: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := builder.Build(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的ctx: %v", err)
+	}
+}
+
+// TestInscriptionBuilderLargeLockValue lock金额足以支付reveal交易时，reveal交易不使用钱包UTXO
+func TestInscriptionBuilderLargeLockValue(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	builder, err := NewInscriptionBuilder(
+		WithDoginal(bytes.Repeat([]byte{1}, 3000), "text/plain"),
+		WithUtxos(utxos),
+		WithChangeAddress(address),
+		WithFeeRate(100),
+		WithLockValue(10e8),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+	reveal := result.Txs[len(result.Txs)-1]
+	if len(reveal.TxIn) != 1 || len(reveal.TxOut) != 2 || reveal.TxOut[1].Value < 9e8 {
+		t.Errorf("reveal交易%d个输入, %d个输出", len(reveal.TxIn), len(reveal.TxOut))
+	}
+
+	// 钱包不足以锁定lockValue
+	_, _, _, utxos = testSimulatorWallet(t, 1, 5e8)
+	_, err = NewInscriptionBuilder(
+		WithDoginal(bytes.Repeat([]byte{1}, 3000), "text/plain"),
+		WithUtxos(utxos),
+		WithChangeAddress(address),
+		WithFeeRate(100),
+		WithLockValue(10e8),
+	)
+	var fundsErr *ErrInsufficientFunds
+	if !errors.As(err, &fundsErr) || fundsErr.Needed != 10e8 || fundsErr.Available != 5e8 {
+		t.Errorf("lock金额超过钱包余额: %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DRC-20协议字段，与BRC-20相同：所有值都是字符串，金额为十进制小数
+const (
+	DogeDrc20Protocol    = "drc-20"
+	DogeDrc20ContentType = "text/plain;charset=utf-8"
+
+	DogeDrc20OpDeploy   = "deploy"
+	DogeDrc20OpMint     = "mint"
+	DogeDrc20OpTransfer = "transfer"
+
+	// DogeDrc20TickLen tick的字符数（按UTF-8字符计算，不是字节数）
+	DogeDrc20TickLen = 4
+	// DogeDrc20MaxDecimals dec的最大值，也是没有写dec时的默认值
+	DogeDrc20MaxDecimals = 18
+)
+
+// dogeDrc20MaxAmount 金额的整数部分不能超过uint64的最大值
+var dogeDrc20MaxAmount = new(big.Int).SetUint64(^uint64(0))
+
+// DogeDrc20Op 一个DRC-20操作，字段顺序即序列化时JSON的字段顺序
+// tick不区分大小写，保留用户输入的写法，比较时使用TickKey
+type DogeDrc20Op struct {
+	Protocol string `json:"p"`
+	Op       string `json:"op"`
+	Tick     string `json:"tick"`
+	Max      string `json:"max,omitempty"` // deploy: 总量
+	Lim      string `json:"lim,omitempty"` // deploy: 每次mint的上限，为空时等于max
+	Dec      string `json:"dec,omitempty"` // deploy: 小数位数，为空时为18
+	Amt      string `json:"amt,omitempty"` // mint/transfer: 金额
+}
+
+// NewDogeDrc20Deploy 创建deploy操作，lim为空时不限制每次mint的数量，dec为18时不写入dec
+func NewDogeDrc20Deploy(tick, max, lim string, dec int) (*DogeDrc20Op, error) {
+	op := &DogeDrc20Op{
+		Protocol: DogeDrc20Protocol,
+		Op:       DogeDrc20OpDeploy,
+		Tick:     tick,
+		Max:      max,
+		Lim:      lim,
+	}
+	if dec != DogeDrc20MaxDecimals {
+		op.Dec = strconv.Itoa(dec)
+	}
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// NewDogeDrc20Mint 创建mint操作
+func NewDogeDrc20Mint(tick, amt string) (*DogeDrc20Op, error) {
+	return newDogeDrc20AmountOp(DogeDrc20OpMint, tick, amt)
+}
+
+// NewDogeDrc20Transfer 创建transfer操作，铸造到自己的地址后再把该inscription发送给接收方
+func NewDogeDrc20Transfer(tick, amt string) (*DogeDrc20Op, error) {
+	return newDogeDrc20AmountOp(DogeDrc20OpTransfer, tick, amt)
+}
+
+func newDogeDrc20AmountOp(operation, tick, amt string) (*DogeDrc20Op, error) {
+	op := &DogeDrc20Op{
+		Protocol: DogeDrc20Protocol,
+		Op:       operation,
+		Tick:     tick,
+		Amt:      amt,
+	}
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// TickKey 用于比较和索引的tick（小写）
+func (op *DogeDrc20Op) TickKey() string {
+	return strings.ToLower(op.Tick)
+}
+
+// Decimals deploy的小数位数，没有写dec时为18
+func (op *DogeDrc20Op) Decimals() int {
+	if op.Dec == "" {
+		return DogeDrc20MaxDecimals
+	}
+	dec, _ := strconv.Atoi(op.Dec)
+	return dec
+}
+
+// Validate 校验协议、tick和金额
+// mint和transfer在解析时不知道token的dec，只按最大的18位小数校验，是否超出dec由索引器判断
+func (op *DogeDrc20Op) Validate() error {
+	if op.Protocol != DogeDrc20Protocol {
+		return fmt.Errorf("%w: p不是%s: %q", ErrNotDrc20, DogeDrc20Protocol, op.Protocol)
+	}
+	if n := utf8.RuneCountInString(op.Tick); !utf8.ValidString(op.Tick) || n != DogeDrc20TickLen {
+		return fmt.Errorf("%w: tick必须是%d个字符: %q", ErrNotDrc20, DogeDrc20TickLen, op.Tick)
+	}
+	switch op.Op {
+	case DogeDrc20OpDeploy:
+		if op.Amt != "" {
+			return fmt.Errorf("%w: deploy不能包含amt", ErrNotDrc20)
+		}
+		dec := DogeDrc20MaxDecimals
+		if op.Dec != "" {
+			var err error
+			dec, err = parseDrc20Decimals(op.Dec)
+			if err != nil {
+				return err
+			}
+		}
+		max, err := parseDrc20Amount("max", op.Max, dec)
+		if err != nil {
+			return err
+		}
+		if op.Lim != "" {
+			lim, err := parseDrc20Amount("lim", op.Lim, dec)
+			if err != nil {
+				return err
+			}
+			if lim.Cmp(max) > 0 {
+				return fmt.Errorf("%w: lim不能大于max: %s > %s", ErrNotDrc20, op.Lim, op.Max)
+			}
+		}
+	case DogeDrc20OpMint, DogeDrc20OpTransfer:
+		if op.Max != "" || op.Lim != "" || op.Dec != "" {
+			return fmt.Errorf("%w: %s不能包含max、lim或dec", ErrNotDrc20, op.Op)
+		}
+		if _, err := parseDrc20Amount("amt", op.Amt, DogeDrc20MaxDecimals); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: 未知的op: %q", ErrNotDrc20, op.Op)
+	}
+	return nil
+}
+
+// parseDrc20Decimals 解析dec，只能是0到18的十进制整数
+func parseDrc20Decimals(s string) (int, error) {
+	dec, err := strconv.Atoi(s)
+	if err != nil || strconv.Itoa(dec) != s || dec < 0 || dec > DogeDrc20MaxDecimals {
+		return 0, fmt.Errorf("%w: dec必须是0到%d的整数: %q", ErrNotDrc20, DogeDrc20MaxDecimals, s)
+	}
+	return dec, nil
+}
+
+// parseDrc20Amount 解析金额，返回放大10^dec倍后的整数，金额必须大于0且整数部分不超过uint64
+func parseDrc20Amount(field, s string, dec int) (*big.Int, error) {
+	amount, err := parseTokenAmount(s, dec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s%v", ErrNotDrc20, field, err)
+	}
+	if amount.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: %s必须大于0: %q", ErrNotDrc20, field, s)
+	}
+	limit := new(big.Int).Mul(new(big.Int).Add(dogeDrc20MaxAmount, big.NewInt(1)), pow10(dec))
+	if amount.Cmp(limit) >= 0 {
+		return nil, fmt.Errorf("%w: %s超出范围: %q", ErrNotDrc20, field, s)
+	}
+	return amount, nil
+}
+
+// parseTokenAmount 解析token的十进制金额，返回放大10^dec倍后的整数，DRC-20和MRC-20共用
+// 只接受数字和一个小数点，不接受符号、指数和首尾的小数点；小数位数不能超过dec
+func parseTokenAmount(s string, dec int) (*big.Int, error) {
+	intPart, fracPart, hasDot := strings.Cut(s, ".")
+	if intPart == "" || (hasDot && fracPart == "") || !isDecimalDigits(intPart) || !isDecimalDigits(fracPart) {
+		return nil, fmt.Errorf("不是有效的金额: %q", s)
+	}
+	if len(fracPart) > dec {
+		return nil, fmt.Errorf("的小数位数超过%d: %q", dec, s)
+	}
+	amount, _ := new(big.Int).SetString(intPart+fracPart+strings.Repeat("0", dec-len(fracPart)), 10)
+	return amount, nil
+}
+
+// formatTokenAmount parseTokenAmount的逆运算，去掉小数部分末尾的0
+func formatTokenAmount(amount *big.Int, dec int) string {
+	digits := amount.String()
+	if dec == 0 {
+		return digits
+	}
+	if len(digits) <= dec {
+		digits = strings.Repeat("0", dec-len(digits)+1) + digits
+	}
+	intPart, fracPart := digits[:len(digits)-dec], strings.TrimRight(digits[len(digits)-dec:], "0")
+	if fracPart == "" {
+		return intPart
+	}
+	return intPart + "." + fracPart
+}
+
+// pow10 返回10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// isDecimalDigits s是否只包含0-9，空字符串返回true
+func isDecimalDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Marshal 校验后序列化为JSON，字段顺序固定为p、op、tick、max、lim、dec、amt，不包含空格
+func (op *DogeDrc20Op) Marshal() ([]byte, error) {
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	return json.Marshal(op)
+}
+
+// BuildDogeDrc20Inscription 构建DRC-20操作的Doginal inscription脚本，内容类型为text/plain
+func BuildDogeDrc20Inscription(op *DogeDrc20Op) ([]byte, error) {
+	data, err := op.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return BuildDoginalInscription(data, DogeDrc20ContentType)
+}
+
+// WithDrc20 铸造DRC-20操作
+func WithDrc20(op *DogeDrc20Op) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if op == nil {
+			return fmt.Errorf("%w: DRC-20操作为空", ErrInvalidOption)
+		}
+		data, err := op.Marshal()
+		if err != nil {
+			return err
+		}
+		// DRC-20操作总是写在一个数据块中，不受WithChunkSizes影响，ParseDogeDrc20只识别一个数据块的操作
+		return b.setContent(func(chunkLen int) ([]byte, error) {
+			return buildDoginalInscription(data, DogeDrc20ContentType, max(chunkLen, len(data)))
+		})
+	}
+}
+
+// ParseDogeDrc20 把解析出的Doginal inscription识别为DRC-20操作
+// 内容类型必须是text/plain或application/json，内容必须是p为drc-20的JSON对象且所有字段都是字符串，
+// 字段名区分大小写，不能有未知字段或重复字段；
+// DRC-20操作不超过MAX_CHUNK_LEN，只有一个数据块，后续partial或有多个数据块的inscription都不是完整的DRC-20操作；
+// 不是DRC-20或字段不合法时返回包装了ErrNotDrc20的错误
+func ParseDogeDrc20(inscription *InscriptionData) (*DogeDrc20Op, error) {
+	if inscription == nil || inscription.Format != InscriptionFormatDoginal {
+		return nil, fmt.Errorf("%w: 不是Doginal格式", ErrNotDrc20)
+	}
+	// 后续partial和只包含部分数据块的partial中的JSON不完整，不能先解析再判断
+	if inscription.Continuation {
+		return nil, fmt.Errorf("%w: 是inscription的后续partial", ErrNotDrc20)
+	}
+	if inscription.PartsCount != 1 || inscription.Index != 0 {
+		return nil, fmt.Errorf("%w: inscription有%d个数据块，DRC-20操作只有一个数据块", ErrNotDrc20, inscription.PartsCount)
+	}
+	mediaType, _, _ := strings.Cut(inscription.ContentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType != "text/plain" && mediaType != "application/json" {
+		return nil, fmt.Errorf("%w: 内容类型为%s", ErrNotDrc20, inscription.ContentType)
+	}
+	op, err := decodeDrc20Op(inscription.Data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrNotDrc20, err)
+	}
+	if err := op.Validate(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// dogeDrc20Fields DogeDrc20Op的JSON字段，字段名区分大小写
+var dogeDrc20Fields = map[string]bool{"p": true, "op": true, "tick": true, "max": true, "lim": true, "dec": true, "amt": true}
+
+// decodeDrc20Op 严格解析DRC-20的JSON：字段名区分大小写，不能有未知字段、重复字段或JSON之后的其他内容
+// encoding/json匹配字段名时不区分大小写，所以先用json.Token检查字段名，再用DisallowUnknownFields解析
+func decodeDrc20Op(data []byte) (*DogeDrc20Op, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if token, err := dec.Token(); err != nil || token != json.Delim('{') {
+		return nil, fmt.Errorf("不是JSON对象")
+	}
+	seen := make(map[string]bool, len(dogeDrc20Fields))
+	for dec.More() {
+		token, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		if !dogeDrc20Fields[key] {
+			return nil, fmt.Errorf("未知字段%q", key)
+		}
+		if seen[key] {
+			return nil, fmt.Errorf("重复字段%q", key)
+		}
+		seen[key] = true
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+
+	op := &DogeDrc20Op{}
+	dec = json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(op); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("JSON之后有其他内容")
+	}
+	return op, nil
+}
+
+// ParseDogeDrc20FromTx 从交易中解析DRC-20操作，txRaw为第一个partial交易（commit之后的第一笔交易）
+func ParseDogeDrc20FromTx(txRaw string) (*DogeDrc20Op, error) {
+	inscription, err := ParseInscriptionFromTx(txRaw, InscriptionFormatDoginal)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDogeDrc20(inscription)
+}
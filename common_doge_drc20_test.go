@@ -0,0 +1,135 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDogeDrc20Op(t *testing.T) {
+	deploy, err := NewDogeDrc20Deploy("DOGI", "21000000", "1000", 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := deploy.Marshal(); string(content) != `{"p":"drc-20","op":"deploy","tick":"DOGI","max":"21000000","lim":"1000","dec":"8"}` {
+		t.Errorf("deploy: %s", content)
+	}
+	// dec为18时不写入dec，lim为空时不写入lim
+	deploy, err = NewDogeDrc20Deploy("dogi", "1", "", 18)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := deploy.Marshal(); string(content) != `{"p":"drc-20","op":"deploy","tick":"dogi","max":"1"}` || deploy.Decimals() != 18 || deploy.TickKey() != "dogi" {
+		t.Errorf("deploy: %s", content)
+	}
+	if _, err := NewDogeDrc20Deploy("DOGI", "18446744073709551615.5", "", 1); err != nil {
+		t.Errorf("max为uint64的最大值: %v", err)
+	}
+	if _, err := NewDogeDrc20Mint("狗狗币币", "0.5"); err != nil {
+		t.Errorf("4个UTF-8字符的tick: %v", err)
+	}
+
+	for name, build := range map[string]func() (*DogeDrc20Op, error){
+		"tick长度":     func() (*DogeDrc20Op, error) { return NewDogeDrc20Deploy("DOG", "1", "", 8) },
+		"lim大于max":   func() (*DogeDrc20Op, error) { return NewDogeDrc20Deploy("DOGI", "1", "2", 8) },
+		"小数位数超过dec":  func() (*DogeDrc20Op, error) { return NewDogeDrc20Deploy("DOGI", "1.123", "", 2) },
+		"dec为19":     func() (*DogeDrc20Op, error) { return NewDogeDrc20Deploy("DOGI", "1", "", 19) },
+		"max为0":      func() (*DogeDrc20Op, error) { return NewDogeDrc20Deploy("DOGI", "0", "", 8) },
+		"max超出范围":    func() (*DogeDrc20Op, error) { return NewDogeDrc20Deploy("DOGI", "18446744073709551616", "", 0) },
+		"负数":         func() (*DogeDrc20Op, error) { return NewDogeDrc20Mint("DOGI", "-1") },
+		"末尾小数点":      func() (*DogeDrc20Op, error) { return NewDogeDrc20Mint("DOGI", "1.") },
+		"开头小数点":      func() (*DogeDrc20Op, error) { return NewDogeDrc20Mint("DOGI", ".1") },
+		"指数":         func() (*DogeDrc20Op, error) { return NewDogeDrc20Mint("DOGI", "1e3") },
+		"transfer为0": func() (*DogeDrc20Op, error) { return NewDogeDrc20Transfer("DOGI", "0.000") },
+	} {
+		if _, err := build(); !errors.Is(err, ErrNotDrc20) || DogeErrorCode(err) != ErrCodeNotDrc20 {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestParseDogeDrc20(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	mint, err := NewDogeDrc20Mint("DOGI", "1000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder, err := NewInscriptionBuilder(WithDrc20(mint), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+	op, err := ParseDogeDrc20FromTx(testTxHex(t, result.Txs[1]))
+	if err != nil || *op != *mint {
+		t.Fatalf("%v %+v", err, op)
+	}
+	// 数据块长度小于DRC-20操作时仍然只有一个数据块
+	builder, err = NewInscriptionBuilder(WithDrc20(mint), WithChunkSizes(10, 100), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err = builder.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if op, err := ParseDogeDrc20FromTx(testTxHex(t, result.Txs[1])); err != nil || *op != *mint {
+		t.Errorf("WithChunkSizes: %v %+v", err, op)
+	}
+
+	parse := func(contentType, content string) error {
+		_, err := ParseDogeDrc20(&InscriptionData{Format: InscriptionFormatDoginal, ContentType: contentType, Data: []byte(content), PartsCount: 1})
+		return err
+	}
+	for _, content := range []string{
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"}`,
+		` {"amt":"1", "tick":"DOGI", "op":"mint", "p":"drc-20"}` + "\n",
+	} {
+		if err := parse("text/plain;charset=utf-8", content); err != nil {
+			t.Errorf("%s: %v", content, err)
+		}
+	}
+	if err := parse("application/json", `{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"}`); err != nil {
+		t.Errorf("application/json: %v", err)
+	}
+	for _, content := range []string{
+		`{"p":"brc-20","op":"mint","tick":"DOGI","amt":"1"}`,
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":1}`,
+		`hello`,
+		`["drc-20"]`,
+		// 字段名区分大小写
+		`{"P":"drc-20","op":"mint","tick":"DOGI","amt":"1"}`,
+		`{"p":"drc-20","Op":"mint","tick":"DOGI","amt":"1"}`,
+		`{"p":"drc-20","op":"mint","TICK":"DOGI","amt":"1"}`,
+		// 未知字段、重复字段和JSON之后的内容
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1","to":"D"}`,
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1","amt":"2"}`,
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"}{}`,
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"} x`,
+		`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"`,
+	} {
+		if err := parse("text/plain", content); !errors.Is(err, ErrNotDrc20) || DogeErrorCode(err) != ErrCodeNotDrc20 {
+			t.Errorf("%s: %v", content, err)
+		}
+	}
+	if err := parse("image/png", `{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"}`); !errors.Is(err, ErrNotDrc20) {
+		t.Errorf("image/png: %v", err)
+	}
+	if _, err := ParseDogeDrc20(&InscriptionData{Format: InscriptionFormatMetaID}); !errors.Is(err, ErrNotDrc20) {
+		t.Errorf("MetaID: %v", err)
+	}
+
+	// 不完整的inscription：后续partial、缺少后面数据块的第一个partial
+	content := []byte(`{"p":"drc-20","op":"mint","tick":"DOGI","amt":"1"}`)
+	for name, inscription := range map[string]*InscriptionData{
+		"后续partial": {Format: InscriptionFormatDoginal, ContentType: "text/plain", Data: content, PartsCount: 1, Continuation: true},
+		"缺少数据块":     {Format: InscriptionFormatDoginal, ContentType: "text/plain", Data: content, PartsCount: 2, Index: 1},
+		"没有数据块":     {Format: InscriptionFormatDoginal, ContentType: "text/plain"},
+	} {
+		if _, err := ParseDogeDrc20(inscription); !errors.Is(err, ErrNotDrc20) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+)
+
+// 可以用errors.Is判断的错误，包内返回的错误通过%w包装这些错误
+var (
+	// ErrInvalidAddress 地址无法解码或与网络不匹配
+	ErrInvalidAddress = errors.New("无效的地址")
+	// ErrNotInscription 交易的第一个输入不是inscription的unlock脚本，或者不是指定的格式
+	ErrNotInscription = errors.New("不是inscription交易")
+	// ErrNotDrc20 inscription不是DRC-20操作，或者字段不合法
+	ErrNotDrc20 = errors.New("不是有效的DRC-20操作")
+	// ErrNotMrc20 PIN不是MRC-20操作，或者字段不合法
+	ErrNotMrc20 = errors.New("不是有效的MRC-20操作")
+	// ErrMetaFileCorrupt MetaFile的index或分片与记录的SHA-256、大小不一致
+	ErrMetaFileCorrupt = errors.New("MetaFile已损坏")
+	// ErrPinKeyMismatch 加密的PIN不是发给该私钥的，或者密文被篡改
+	ErrPinKeyMismatch = errors.New("密钥与加密的PIN不匹配")
+	// ErrInvalidOption 构建或解析的参数为空、无法解码、超出范围或者相互冲突
+	ErrInvalidOption = errors.New("无效的构建参数")
+	// ErrInvalidUtxo UTXO的txid、pkScript或私钥无法解码，或者是无法签名的类型
+	ErrInvalidUtxo = errors.New("无效的UTXO")
+)
+
+// decodeDogeAddress 解码地址，失败时返回包装了ErrInvalidAddress的错误
+func decodeDogeAddress(address string, netParam *chaincfg.Params) (btcutil.Address, error) {
+	addr, err := btcutil.DecodeAddress(address, netParam)
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %v", ErrInvalidAddress, address, err)
+	}
+	return addr, nil
+}
+
+// ErrInsufficientFunds 输入金额不足以支付输出和手续费，用errors.As获取
+type ErrInsufficientFunds struct {
+	Needed    int64 // 需要的金额（输出 + 手续费）
+	Available int64 // 可用的输入金额
+}
+
+func (e *ErrInsufficientFunds) Error() string {
+	return fmt.Sprintf("资金不足: 需要%d, 可用%d", e.Needed, e.Available)
+}
+
+// ErrScriptSigTooLarge 签名脚本超过Dogecoin的标准长度，交易不会被节点转发，用errors.As获取
+type ErrScriptSigTooLarge struct {
+	Input int // 输入的索引
+	Size  int // 签名脚本的长度
+	Limit int // 最大长度
+}
+
+func (e *ErrScriptSigTooLarge) Error() string {
+	return fmt.Sprintf("输入 %d 签名脚本过大: %d > %d", e.Input, e.Size, e.Limit)
+}
+
+// ErrDustOutput 输出金额低于Dogecoin的dust限制，交易不会被节点转发，用errors.As获取
+type ErrDustOutput struct {
+	Address string // 输出地址，无法得到地址时为空
+	Amount  int64
+	Limit   int64
+}
+
+func (e *ErrDustOutput) Error() string {
+	if e.Address == "" {
+		return fmt.Sprintf("输出金额低于dust限制: %d < %d", e.Amount, e.Limit)
+	}
+	return fmt.Sprintf("输出%s的金额低于dust限制: %d < %d", e.Address, e.Amount, e.Limit)
+}
+
+// 错误码，与插件src/data/errors.ts中ERRORS的key一致
+const (
+	ErrCodeInsufficientFunds = "INSUFFICIENT_FUNDS"
+	ErrCodeInvalidAddress    = "INVALID_ADDRESS"
+	ErrCodeNotInscription    = "NOT_INSCRIPTION"
+	ErrCodeScriptSigTooLarge = "SCRIPT_SIG_TOO_LARGE"
+	ErrCodeDustOutput        = "DUST_OUTPUT"
+	ErrCodeNotDrc20          = "NOT_DRC20"
+	ErrCodeNotMrc20          = "NOT_MRC20"
+	ErrCodePinKeyMismatch    = "PIN_KEY_MISMATCH"
+	ErrCodeInvalidOption     = "INVALID_OPTION"
+	ErrCodeInvalidUtxo       = "INVALID_UTXO"
+)
+
+// DogeErrorCode 返回err对应的错误码，用于后端返回给插件显示本地化的提示
+// err不包含本包定义的错误时返回空字符串
+func DogeErrorCode(err error) string {
+	var insufficientFunds *ErrInsufficientFunds
+	var scriptSigTooLarge *ErrScriptSigTooLarge
+	var dustOutput *ErrDustOutput
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &insufficientFunds):
+		return ErrCodeInsufficientFunds
+	case errors.As(err, &scriptSigTooLarge):
+		return ErrCodeScriptSigTooLarge
+	case errors.As(err, &dustOutput):
+		return ErrCodeDustOutput
+	case errors.Is(err, ErrInvalidAddress):
+		return ErrCodeInvalidAddress
+	case errors.Is(err, ErrNotInscription):
+		return ErrCodeNotInscription
+	case errors.Is(err, ErrNotDrc20):
+		return ErrCodeNotDrc20
+	case errors.Is(err, ErrNotMrc20):
+		return ErrCodeNotMrc20
+	case errors.Is(err, ErrPinKeyMismatch):
+		return ErrCodePinKeyMismatch
+	case errors.Is(err, ErrInvalidOption):
+		return ErrCodeInvalidOption
+	case errors.Is(err, ErrInvalidUtxo):
+		return ErrCodeInvalidUtxo
+	}
+	return ""
+}
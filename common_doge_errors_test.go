@@ -0,0 +1,113 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+)
+
+func TestDogeErrors(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 1e6)
+	_, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, bytes.Repeat([]byte("a"), 3000), "/x", utxos, address, 0, address, 1000, false, InscriptionFormatMetaID)
+	var fundsErr *ErrInsufficientFunds
+	if !errors.As(err, &fundsErr) || fundsErr.Needed <= fundsErr.Available || DogeErrorCode(err) != ErrCodeInsufficientFunds {
+		t.Errorf("资金不足: %v", err)
+	}
+	_, err = BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, []byte("a"), "/x", utxos, "Dxxxx", 0, address, 1000, false, InscriptionFormatMetaID)
+	if !errors.Is(err, ErrInvalidAddress) || DogeErrorCode(err) != ErrCodeInvalidAddress {
+		t.Errorf("无效地址: %v", err)
+	}
+	_, err = BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: address, Amount: 2e6}}, address, 1000, false)
+	if !errors.As(err, &fundsErr) {
+		t.Errorf("普通交易资金不足: %v", err)
+	}
+	_, err = BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: address, Amount: 5}}, address, 1000, false)
+	var dustErr *ErrDustOutput
+	if !errors.As(err, &dustErr) || dustErr.Amount != 5 || DogeErrorCode(err) != ErrCodeDustOutput {
+		t.Errorf("dust输出: %v", err)
+	}
+
+	// 一个1600字节的push无法放进标准的签名脚本
+	_, _, _, utxos = testSimulatorWallet(t, 1, 50e8)
+	script := append([]byte{txscript.OP_PUSHDATA2, 0x40, 0x06}, bytes.Repeat([]byte{1}, 1600)...)
+	_, err = BuildDogeInscriptionScriptTxs(DogeMainNetParams, script, utxos, address, 0, address, 1000, false)
+	var sigErr *ErrScriptSigTooLarge
+	if !errors.As(err, &sigErr) || DogeErrorCode(err) != ErrCodeScriptSigTooLarge {
+		t.Errorf("签名脚本过大: %v", err)
+	}
+
+	tx, err := BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: address, Amount: 2e6}}, address, 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ParseInscriptionFromTx(testTxHex(t, tx), InscriptionFormatMetaID)
+	if !errors.Is(err, ErrNotInscription) || DogeErrorCode(err) != ErrCodeNotInscription {
+		t.Errorf("普通交易: %v", err)
+	}
+	if DogeErrorCode(nil) != "" || DogeErrorCode(errors.New("x")) != "" {
+		t.Error("不是本包的错误应该返回空错误码")
+	}
+}
+
+func TestBuildDogeCommonTxInvalidUtxo(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 1e8)
+	p2sh := hex.EncodeToString(p2shPkScript([]byte{txscript.OP_TRUE}))
+	for name, mutate := range map[string]func(u *TxInputUtxo){
+		"txid":     func(u *TxInputUtxo) { u.TxId = "zz" },
+		"pkScript": func(u *TxInputUtxo) { u.PkScript = "zz" },
+		"无法解析地址":   func(u *TxInputUtxo) { u.PkScript = "6a" },
+		"P2SH":     func(u *TxInputUtxo) { u.PkScript = p2sh },
+		"私钥":       func(u *TxInputUtxo) { u.PriHex = "1234" },
+	} {
+		utxo := *utxos[0]
+		mutate(&utxo)
+		_, err := BuildDogeCommonTx(DogeMainNetParams, []*TxInputUtxo{&utxo}, []*TxOutput{{Address: address, Amount: 1e7}}, address, 1000, false)
+		if !errors.Is(err, ErrInvalidUtxo) || DogeErrorCode(err) != ErrCodeInvalidUtxo {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestInscriptionBuilderInvalidOption(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 1e8)
+	content := WithDoginal([]byte("a"), "text/plain")
+	for name, opts := range map[string][]InscriptionOption{
+		"没有内容":    {WithUtxos(utxos), WithFeeRate(1000), WithChangeAddress(address)},
+		"没有UTXO":  {content, WithFeeRate(1000), WithChangeAddress(address)},
+		"没有费率":    {content, WithUtxos(utxos), WithChangeAddress(address)},
+		"没有找零地址":  {content, WithUtxos(utxos), WithFeeRate(1000)},
+		"内容设置两次":  {content, content},
+		"空PIN":    {WithMetaIdPin(nil)},
+		"空脚本":     {WithInscriptionScript(nil)},
+		"空网络":     {WithNetwork(nil)},
+		"费率为0":    {WithFeeRate(0)},
+		"空UTXO":   {WithUtxos([]*TxInputUtxo{nil})},
+		"数据块长度":   {WithChunkSizes(0, 100)},
+		"partial": {WithChunkSizes(240, 100)},
+		"lock金额":  {WithLockValue(dogeMaxMoney + 1)},
+		"空日志":     {WithLogger(nil)},
+		"空进度回调":   {WithProgress(nil)},
+		"空DRC-20": {WithDrc20(nil)},
+		"空MRC-20": {WithMrc20Transfer(nil)},
+		"额外输出为空":  {content, WithUtxos(utxos), WithFeeRate(1000), WithChangeAddress(address), WithExtraOutputs(&DogeExtraOutputs{Outputs: []*TxOutput{nil}})},
+	} {
+		_, err := NewInscriptionBuilder(opts...)
+		if !errors.Is(err, ErrInvalidOption) || DogeErrorCode(err) != ErrCodeInvalidOption {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// 地址错误仍然是ErrInvalidAddress，dust仍然是ErrDustOutput
+	_, err := NewInscriptionBuilder(content, WithUtxos(utxos), WithFeeRate(1000), WithChangeAddress("Dxxxx"))
+	if !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("找零地址: %v", err)
+	}
+	_, err = NewInscriptionBuilder(WithLockValue(DogeDustLimit - 1))
+	var dustErr *ErrDustOutput
+	if !errors.As(err, &dustErr) {
+		t.Errorf("lock金额低于dust: %v", err)
+	}
+}
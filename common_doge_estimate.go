@@ -0,0 +1,160 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+)
+
+// DogeEstimateOptions 估算时使用的交易参数，字段为空时使用与真实构建相同的默认值
+// 地址类型（P2PKH/P2SH）会影响输出脚本长度，需要精确结果时应传入真实地址
+type DogeEstimateOptions struct {
+	NetParam      *chaincfg.Params  // 默认DogeMainNetParams
+	OutputAddress string            // reveal交易的接收地址，默认使用一个P2PKH地址
+	OutputValue   int64             // reveal输出金额，为0时为100000
+	ChangeAddress string            // 找零地址，默认与OutputAddress相同
+	Extra         *DogeExtraOutputs // 服务费和额外输出
+}
+
+// DogeInscriptionEstimate inscription交易链的估算结果，金额单位为satoshis
+type DogeInscriptionEstimate struct {
+	PartialCount int `json:"partialCount"` // partial数量，交易数量为PartialCount + 1
+	// TxSizes 每笔交易的字节数，按广播顺序；DER签名的长度不固定，真实交易每个签名可能相差1字节，
+	// 最后一笔交易的找零低于DogeDustLimit时不会添加找零输出
+	TxSizes     []int   `json:"txSizes"`
+	TxFees      []int64 `json:"txFees"` // 每笔交易的手续费，按广播顺序
+	CommitCost  int64   `json:"commitCost"`
+	RevealCost  int64   `json:"revealCost"`
+	ServiceCost int64   `json:"serviceCost"`
+	TotalFee    int64   `json:"totalFee"` // CommitCost + RevealCost
+	// MinFunding 只用一个UTXO支付时所需的最小金额：手续费 + reveal输出 + 服务费和额外输出，
+	// 低费率时还要保证每笔需要钱包资金的交易之前的找零不低于DogeDustLimit；
+	// 使用多个UTXO时每个额外输入都会增加手续费
+	MinFunding int64 `json:"minFunding"`
+}
+
+// EstimateInscription 不需要密钥和UTXO，估算把data铸造为inscription需要的交易数量和费用
+// 使用与BuildDogeMetaIdInscriptionTxs完全相同的拆分和手续费计算逻辑，只是输入换成临时密钥和一个足够大的虚拟UTXO；
+// 手续费只与交易结构有关，所以用一个不少于MinFunding的UTXO真实构建时，每笔交易的手续费与估算结果一致，
+// 只有最后一笔需要钱包资金的交易可能因为找零低于DogeDustLimit而多付手续费
+func EstimateInscription(
+	data []byte,
+	contentType string,
+	format InscriptionFormat,
+	feeRate int64, // satoshis/B
+	options *DogeEstimateOptions,
+) (*DogeInscriptionEstimate, error) {
+	inscriptionScript, err := buildDogeInscriptionScript(data, contentType, format)
+	if err != nil {
+		return nil, err
+	}
+	return EstimateInscriptionScript(inscriptionScript, feeRate, options)
+}
+
+// EstimateInscriptionSize 只知道数据大小时的估算（例如用户还没有选择文件）
+// 使用0xff填充数据；长度为1且值不超过16的数据会被编码为OP_1到OP_16，
+// 实际少1个字节，所以这里的结果是上限
+func EstimateInscriptionSize(
+	dataSize int,
+	contentType string,
+	format InscriptionFormat,
+	feeRate int64, // satoshis/B
+	options *DogeEstimateOptions,
+) (*DogeInscriptionEstimate, error) {
+	if dataSize < 0 {
+		return nil, fmt.Errorf("数据大小不能为负数: %d", dataSize)
+	}
+	return EstimateInscription(bytes.Repeat([]byte{0xff}, dataSize), contentType, format, feeRate, options)
+}
+
+// EstimateInscriptionScript 估算已经构建好的inscription脚本（例如加密的PIN）需要的交易数量和费用
+func EstimateInscriptionScript(
+	inscriptionScript []byte,
+	feeRate int64, // satoshis/B
+	options *DogeEstimateOptions,
+) (*DogeInscriptionEstimate, error) {
+	if options == nil {
+		options = &DogeEstimateOptions{}
+	}
+	netParam := options.NetParam
+	if netParam == nil {
+		netParam = DogeMainNetParams
+	}
+
+	// 虚拟钱包：固定的私钥和一个金额为MAX_MONEY的P2PKH UTXO
+	walletKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	walletAddr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(walletKey.PubKey().SerializeCompressed()), netParam)
+	if err != nil {
+		return nil, fmt.Errorf("生成估算地址失败: %w", err)
+	}
+	walletPkScript, err := txscript.PayToAddrScript(walletAddr)
+	if err != nil {
+		return nil, fmt.Errorf("构建估算地址脚本失败: %w", err)
+	}
+	ins := []*TxInputUtxo{{
+		TxId:     "0000000000000000000000000000000000000000000000000000000000000001",
+		TxIndex:  0,
+		PkScript: hex.EncodeToString(walletPkScript),
+		Amount:   uint64(dogeMaxMoney),
+		PriHex:   hex.EncodeToString(walletKey.Serialize()),
+		SignMode: SignModeLegacy,
+	}}
+
+	outputAddress := options.OutputAddress
+	if outputAddress == "" {
+		outputAddress = walletAddr.EncodeAddress()
+	}
+	changeAddress := options.ChangeAddress
+	if changeAddress == "" {
+		changeAddress = outputAddress
+	}
+	outputValue := options.OutputValue
+	if outputValue == 0 {
+		outputValue = 100000
+	}
+
+	// 临时密钥只影响公钥和签名的内容，压缩公钥长度固定，不影响手续费
+	privateKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{0x02}, 32))
+	cfg := newDogeChainConfig(netParam, outputAddress, outputValue, changeAddress, feeRate, options.Extra)
+	cfg.logger = slog.New(discardHandler{}) // 虚拟交易不写入日志，避免与真实构建混淆
+	result, _, err := buildDogeInscriptionChain(context.Background(), cfg, privateKey, inscriptionScript, ins)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &DogeInscriptionEstimate{
+		PartialCount: len(result.Details) - 1,
+		TxSizes:      make([]int, 0, len(result.Details)),
+		TxFees:       make([]int64, 0, len(result.Details)),
+		CommitCost:   result.CommitCost,
+		RevealCost:   result.RevealCost,
+		ServiceCost:  result.ServiceCost,
+		TotalFee:     result.CommitCost + result.RevealCost,
+	}
+	for _, detail := range result.Details {
+		estimate.TxSizes = append(estimate.TxSizes, detail.Size)
+		estimate.TxFees = append(estimate.TxFees, detail.Fee)
+	}
+	// 每笔交易从上一笔的找零中支付，找零低于DogeDustLimit时会并入手续费，
+	// 所以后续交易需要钱包资金时，之前剩余的找零至少为DogeDustLimit
+	spent := int64(0)
+	for i, detail := range result.Details {
+		walletSpend := detail.InputValue - detail.Change
+		if i > 0 {
+			walletSpend -= result.Details[i-1].LockedValue
+			if walletSpend > 0 {
+				estimate.MinFunding = max(estimate.MinFunding, spent+max(walletSpend, DogeDustLimit))
+			}
+		}
+		spent += walletSpend
+		estimate.MinFunding = max(estimate.MinFunding, spent)
+	}
+	return estimate, nil
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestEstimateInscription 用MinFunding和更多的资金真实构建，每笔交易的手续费必须与估算一致；
+// 低费率时找零容易低于DogeDustLimit，MinFunding仍然必须足够
+func TestEstimateInscription(t *testing.T) {
+	_, service := testDogeKey(t, 2)
+	_, address := testDogeKey(t, 1)
+	for _, feeRate := range []int64{100, 200, 1000} {
+		for _, format := range []InscriptionFormat{InscriptionFormatMetaID, InscriptionFormatDoginal} {
+			for _, size := range []int{1, 300, 1500, 5000} {
+				for _, extra := range []*DogeExtraOutputs{nil, {Service: &TxOutput{Address: service, Amount: 1e6}}} {
+					data := bytes.Repeat([]byte{0xff}, size)
+					options := &DogeEstimateOptions{OutputAddress: address, Extra: extra}
+					estimate, err := EstimateInscription(data, "text/plain", format, feeRate, options)
+					if err != nil {
+						t.Fatal(err)
+					}
+					bySize, err := EstimateInscriptionSize(size, "text/plain", format, feeRate, options)
+					if err != nil || bySize.TotalFee != estimate.TotalFee || bySize.MinFunding != estimate.MinFunding {
+						t.Errorf("format %d size %d: EstimateInscriptionSize与EstimateInscription不一致: %v", format, size, err)
+					}
+					if len(estimate.TxSizes) != estimate.PartialCount+1 || len(estimate.TxFees) != estimate.PartialCount+1 ||
+						estimate.TotalFee != estimate.CommitCost+estimate.RevealCost || estimate.ServiceCost != extraValue(extra) {
+						t.Errorf("format %d size %d: %+v", format, size, estimate)
+					}
+
+					inscriptionScript, err := buildDogeInscriptionScript(data, "text/plain", format)
+					if err != nil {
+						t.Fatal(err)
+					}
+					for _, funding := range []int64{estimate.MinFunding, estimate.MinFunding + 1e6} {
+						sim, _, _, utxos := testSimulatorWallet(t, 1, funding)
+						builder, err := NewInscriptionBuilder(
+							WithInscriptionScript(inscriptionScript),
+							WithUtxos(utxos),
+							WithChangeAddress(address),
+							WithFeeRate(feeRate),
+							WithExtraOutputs(extra),
+						)
+						if err != nil {
+							t.Fatal(err)
+						}
+						result, err := builder.Build(context.Background())
+						if err != nil {
+							t.Fatalf("feeRate %d format %d size %d funding %d: %v", feeRate, format, size, funding, err)
+						}
+						testSubmitAndMine(t, sim, result.Txs...)
+						if len(result.Details) != estimate.PartialCount+1 {
+							t.Fatalf("format %d size %d: %d笔交易, 估算%d", format, size, len(result.Details), estimate.PartialCount+1)
+						}
+						// 用MinFunding构建时，剩余的找零低于DogeDustLimit并入一笔交易的手续费
+						overpaid, overpaidTxs := funding-estimate.TotalFee-100000-estimate.ServiceCost, 0
+						for i, detail := range result.Details {
+							if detail.Fee != estimate.TxFees[i] {
+								overpaidTxs++
+								if funding > estimate.MinFunding || overpaidTxs > 1 || detail.Fee-estimate.TxFees[i] != overpaid {
+									t.Errorf("feeRate %d format %d size %d funding %d: 交易%d的手续费%d, 估算%d", feeRate, format, size, funding, i, detail.Fee, estimate.TxFees[i])
+								}
+							}
+							// DER签名的长度不固定，每个输入的签名可能比估算短2字节
+							slack := 2 * len(result.Txs[i].TxIn)
+							if diff := detail.Size - estimate.TxSizes[i]; funding > estimate.MinFunding && (diff > 2 || diff < -slack) {
+								t.Errorf("format %d size %d: 交易%d的大小%d, 估算%d", format, size, i, detail.Size, estimate.TxSizes[i])
+							}
+						}
+					}
+
+					_, _, _, utxos := testSimulatorWallet(t, 1, estimate.MinFunding-1)
+					builder, err := NewInscriptionBuilder(
+						WithInscriptionScript(inscriptionScript),
+						WithUtxos(utxos),
+						WithChangeAddress(address),
+						WithFeeRate(feeRate),
+						WithExtraOutputs(extra),
+					)
+					if err == nil {
+						_, err = builder.Build(context.Background())
+					}
+					var fundsErr *ErrInsufficientFunds
+					if !errors.As(err, &fundsErr) {
+						t.Errorf("feeRate %d format %d size %d MinFunding-1: %v", feeRate, format, size, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// extraValue 额外输出的总金额
+func extraValue(extra *DogeExtraOutputs) int64 {
+	if extra == nil {
+		return 0
+	}
+	total := int64(0)
+	if extra.Service != nil {
+		total += extra.Service.Amount
+	}
+	for _, output := range extra.Outputs {
+		total += output.Amount
+	}
+	return total
+}
+
+func TestEstimateInscriptionDefaults(t *testing.T) {
+	data := bytes.Repeat([]byte("d"), 2000)
+	withDefaults, err := EstimateInscription(data, "text/plain", InscriptionFormatDoginal, 1000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, address := testDogeKey(t, 1)
+	explicit, err := EstimateInscription(data, "text/plain", InscriptionFormatDoginal, 1000, &DogeEstimateOptions{
+		NetParam: DogeMainNetParams, OutputAddress: address, OutputValue: 100000, ChangeAddress: address,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if withDefaults.TotalFee != explicit.TotalFee || withDefaults.MinFunding != explicit.MinFunding {
+		t.Errorf("默认参数: %d/%d, P2PKH地址: %d/%d", withDefaults.TotalFee, withDefaults.MinFunding, explicit.TotalFee, explicit.MinFunding)
+	}
+
+	// 费率翻倍，手续费也翻倍
+	doubled, err := EstimateInscription(data, "text/plain", InscriptionFormatDoginal, 2000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doubled.TotalFee != 2*withDefaults.TotalFee {
+		t.Errorf("费率2000的手续费%d, 费率1000的手续费%d", doubled.TotalFee, withDefaults.TotalFee)
+	}
+
+	if _, err := EstimateInscription(data, "text/plain", InscriptionFormatDoginal, 1000, &DogeEstimateOptions{OutputAddress: "not-an-address"}); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("无效地址: %v", err)
+	}
+}
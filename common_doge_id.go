@@ -0,0 +1,83 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DogeMetaFileScheme metafile URI的前缀
+const DogeMetaFileScheme = "metafile://"
+
+// DogeInscriptionId Doginal inscription id和MetaID PIN id，格式都是<reveal txid>i<输出索引>
+// 本包构建的交易链中inscription始终在reveal交易的第0个输出上
+type DogeInscriptionId struct {
+	TxId  string // reveal交易的txid，64个小写十六进制字符
+	Index uint32 // 携带inscription的输出索引
+}
+
+// NewDogeInscriptionId 由reveal交易得到其第0个输出上的inscription id
+func NewDogeInscriptionId(revealTx *wire.MsgTx) DogeInscriptionId {
+	return DogeInscriptionId{TxId: revealTx.TxHash().String()}
+}
+
+// String 返回<txid>i<index>
+func (id DogeInscriptionId) String() string {
+	return id.TxId + "i" + strconv.FormatUint(uint64(id.Index), 10)
+}
+
+// MetaFileURI 返回metafile://<id>
+func (id DogeInscriptionId) MetaFileURI() string {
+	return DogeMetaFileScheme + id.String()
+}
+
+// OutPoint 携带inscription的输出
+func (id DogeInscriptionId) OutPoint() (*wire.OutPoint, error) {
+	hash, err := chainhash.NewHashFromStr(id.TxId)
+	if err != nil {
+		return nil, fmt.Errorf("解析TxId失败: %w", err)
+	}
+	return wire.NewOutPoint(hash, id.Index), nil
+}
+
+// ParseDogeInscriptionId 解析<txid>i<index>
+// txid必须是64个小写十六进制字符，index是不带前导0的十进制数，其他写法返回error，
+// 保证同一个inscription只有一种字符串形式，可以直接用作map的key
+func ParseDogeInscriptionId(s string) (DogeInscriptionId, error) {
+	txId, index, ok := strings.Cut(s, "i")
+	if !ok || len(txId) != 64 || !isHexString(txId) {
+		return DogeInscriptionId{}, fmt.Errorf("%w: 无效的inscription id: %q", ErrInvalidOption, s)
+	}
+	n, err := strconv.ParseUint(index, 10, 32)
+	if err != nil || strconv.FormatUint(n, 10) != index {
+		return DogeInscriptionId{}, fmt.Errorf("%w: 无效的inscription id: %q", ErrInvalidOption, s)
+	}
+	return DogeInscriptionId{TxId: txId, Index: uint32(n)}, nil
+}
+
+// ParseDogeMetaFileURI 解析metafile://<id>
+// 也接受分片上传后index PIN的URI，此时id为index PIN的id，用ReassembleDogeMetaFile获取文件
+func ParseDogeMetaFileURI(uri string) (DogeInscriptionId, error) {
+	id, ok := strings.CutPrefix(uri, DogeMetaFileScheme)
+	if !ok {
+		return DogeInscriptionId{}, fmt.Errorf("%w: 不是metafile URI: %q", ErrInvalidOption, uri)
+	}
+	return ParseDogeInscriptionId(id)
+}
+
+// isDogePinId s是否是PIN id（<txid>i<index>）
+func isDogePinId(s string) bool {
+	_, err := ParseDogeInscriptionId(s)
+	return err == nil
+}
+
+// InscriptionId 交易链的inscription id（同时也是MetaID PIN id），没有reveal交易时返回error
+func (r *DogeInscriptionResult) InscriptionId() (DogeInscriptionId, error) {
+	if len(r.Details) == 0 || r.Details[len(r.Details)-1].Role != DogeTxRoleReveal {
+		return DogeInscriptionId{}, fmt.Errorf("交易链没有reveal交易")
+	}
+	return DogeInscriptionId{TxId: r.Details[len(r.Details)-1].TxId}, nil
+}
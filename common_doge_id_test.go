@@ -0,0 +1,68 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestParseDogeInscriptionId(t *testing.T) {
+	txId := strings.Repeat("ab", 32)
+	// 同一个inscription只有一种字符串形式
+	for _, invalid := range []string{
+		"", txId, txId + "i", txId + "i01", txId + "i-1", txId + "i+1", txId + "i4294967296",
+		strings.ToUpper(txId) + "i0", txId[:62] + "i0", "x" + txId + "i0", txId + "i0i0",
+	} {
+		if _, err := ParseDogeInscriptionId(invalid); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%q: %v", invalid, err)
+		}
+	}
+
+	id, err := ParseDogeInscriptionId(txId + "i3")
+	if err != nil || id != (DogeInscriptionId{TxId: txId, Index: 3}) || id.String() != txId+"i3" {
+		t.Fatalf("%v %+v", err, id)
+	}
+	if id.MetaFileURI() != "metafile://"+txId+"i3" {
+		t.Errorf("MetaFileURI: %s", id.MetaFileURI())
+	}
+	if parsed, err := ParseDogeMetaFileURI(id.MetaFileURI()); err != nil || parsed != id {
+		t.Errorf("ParseDogeMetaFileURI: %v %+v", err, parsed)
+	}
+	if _, err := ParseDogeMetaFileURI(id.String()); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("没有metafile://前缀的URI: %v", err)
+	}
+	if max, err := ParseDogeInscriptionId(txId + "i4294967295"); err != nil || max.Index != 4294967295 {
+		t.Errorf("最大的index: %v", err)
+	}
+	outPoint, err := id.OutPoint()
+	if err != nil || outPoint.Hash.String() != txId || outPoint.Index != 3 {
+		t.Errorf("OutPoint: %v %v", err, outPoint)
+	}
+}
+
+func TestDogeInscriptionResultInscriptionId(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	builder, err := NewInscriptionBuilder(WithDoginal([]byte("hi"), "text/plain"), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reveal := result.Txs[len(result.Txs)-1]
+	id, err := result.InscriptionId()
+	if err != nil || id != NewDogeInscriptionId(reveal) || id.String() != result.RevealTxIds[len(result.RevealTxIds)-1]+"i0" {
+		t.Fatalf("%v %+v", err, id)
+	}
+	outPoint, err := id.OutPoint()
+	if err != nil || *outPoint != (wire.OutPoint{Hash: reveal.TxHash(), Index: 0}) {
+		t.Errorf("OutPoint: %v %v", err, outPoint)
+	}
+	if _, err := (&DogeInscriptionResult{}).InscriptionId(); err == nil {
+		t.Error("空的交易链没有返回错误")
+	}
+}
@@ -0,0 +1,291 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// 挂单交易的结构:
+//
+//	输入0: 买家的一个UTXO（金额最小的）   输出0: 买家接收inscription，金额为输入0 + inscription UTXO的金额
+//	输入1: 卖家的inscription UTXO          输出1: 卖家收款，金额为价格
+//	输入2...: 买家支付价格和手续费的UTXO  输出2: 买家找零
+//
+// 卖家用SigHashSingle|SigHashAnyOneCanPay签名输入1，只承诺输入1和输出1，买家可以自由添加其他输入和输出。
+// inscription在输入1的第一个satoshi上，前面有输入0的金额，所以落在输出0上，而不是卖家的收款输出
+const (
+	dogeListingSellerIndex = 1
+	dogeListingHashType    = txscript.SigHashSingle | txscript.SigHashAnyOneCanPay
+)
+
+// DogeListing 卖家导出的挂单，所有字段都可以公开，可以直接序列化为JSON发给买家或市场
+type DogeListing struct {
+	InscriptionTxId     string `json:"inscriptionTxId"` // inscription UTXO
+	InscriptionVout     int64  `json:"inscriptionVout"`
+	InscriptionValue    int64  `json:"inscriptionValue"`
+	InscriptionPkScript string `json:"inscriptionPkScript"`
+	SellerAddress       string `json:"sellerAddress"` // 收款地址
+	Price               int64  `json:"price"`
+	SignatureScript     string `json:"signatureScript"` // 卖家对inscription输入的签名脚本
+}
+
+// BuildDogeListing 卖家为inscription UTXO挂单，只有inscription UTXO的私钥参与签名
+// inscriptionUtxo必须是P2PKH并且填写了PriHex
+func BuildDogeListing(
+	netParam *chaincfg.Params,
+	inscriptionUtxo *TxInputUtxo,
+	sellerAddress string,
+	price int64,
+) (*DogeListing, error) {
+	if inscriptionUtxo == nil {
+		return nil, fmt.Errorf("%w: inscription UTXO为空", ErrInvalidOption)
+	}
+	if price < DogeDustLimit {
+		return nil, &ErrDustOutput{Address: sellerAddress, Amount: price, Limit: DogeDustLimit}
+	}
+	if price > dogeMaxMoney {
+		return nil, fmt.Errorf("%w: 价格超出范围: %d", ErrInvalidOption, price)
+	}
+	listing := &DogeListing{
+		InscriptionTxId:     inscriptionUtxo.TxId,
+		InscriptionVout:     inscriptionUtxo.TxIndex,
+		InscriptionValue:    int64(inscriptionUtxo.Amount),
+		InscriptionPkScript: inscriptionUtxo.PkScript,
+		SellerAddress:       sellerAddress,
+		Price:               price,
+	}
+	tx, err := listing.templateTx(netParam)
+	if err != nil {
+		return nil, err
+	}
+	if err := signTransactionInputsWithHashType(tx, []*TxInputUtxo{inscriptionUtxo}, dogeListingSellerIndex, dogeListingHashType); err != nil {
+		return nil, fmt.Errorf("签名挂单失败: %w", err)
+	}
+	listing.SignatureScript = hex.EncodeToString(tx.TxIn[dogeListingSellerIndex].SignatureScript)
+	return listing, nil
+}
+
+// templateTx 构建只包含卖家输入和收款输出的交易，输入0和输出0是占位符
+// 卖家的签名不承诺占位符，所以在这个交易上验证签名与在买家完成的交易上验证结果相同
+func (l *DogeListing) templateTx(netParam *chaincfg.Params) (*wire.MsgTx, error) {
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&chainhash.Hash{}, 0), nil, nil))
+	sellerTxIn, err := l.sellerTxIn()
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxIn(sellerTxIn)
+	tx.AddTxOut(wire.NewTxOut(0, nil))
+	priceTxOut, err := l.priceTxOut(netParam)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxOut(priceTxOut)
+	return tx, nil
+}
+
+// sellerTxIn 花费inscription UTXO的输入，签名脚本为挂单中的签名
+func (l *DogeListing) sellerTxIn() (*wire.TxIn, error) {
+	hash, err := chainhash.NewHashFromStr(l.InscriptionTxId)
+	if err != nil {
+		return nil, fmt.Errorf("解析inscription TxId失败: %w", err)
+	}
+	signatureScript, err := hex.DecodeString(l.SignatureScript)
+	if err != nil {
+		return nil, fmt.Errorf("解码挂单签名失败: %w", err)
+	}
+	return wire.NewTxIn(wire.NewOutPoint(hash, uint32(l.InscriptionVout)), signatureScript, nil), nil
+}
+
+// priceTxOut 卖家的收款输出
+func (l *DogeListing) priceTxOut(netParam *chaincfg.Params) (*wire.TxOut, error) {
+	addr, err := decodeDogeAddress(l.SellerAddress, netParam)
+	if err != nil {
+		return nil, fmt.Errorf("解码卖家地址失败: %w", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("构建卖家地址脚本失败: %w", err)
+	}
+	return wire.NewTxOut(l.Price, pkScript), nil
+}
+
+// verifySellerInput 用txscript验证tx中卖家的输入
+func (l *DogeListing) verifySellerInput(tx *wire.MsgTx) error {
+	pkScript, err := hex.DecodeString(l.InscriptionPkScript)
+	if err != nil {
+		return fmt.Errorf("解码inscription pkScript失败: %w", err)
+	}
+	if txscript.GetScriptClass(pkScript) != txscript.PubKeyHashTy {
+		return fmt.Errorf("inscription UTXO不是P2PKH")
+	}
+	prevOutFetcher := txscript.NewCannedPrevOutputFetcher(pkScript, l.InscriptionValue)
+	vm, err := txscript.NewEngine(pkScript, tx, dogeListingSellerIndex, DogeStandardVerifyFlags,
+		nil, nil, l.InscriptionValue, prevOutFetcher)
+	if err != nil {
+		return fmt.Errorf("创建脚本引擎失败: %w", err)
+	}
+	if err := vm.Execute(); err != nil {
+		return fmt.Errorf("卖家签名无效: %w", err)
+	}
+
+	// 签名类型必须是SigHashSingle|SigHashAnyOneCanPay，否则买家添加输入后签名会失效
+	pushes, err := txscript.PushedData(tx.TxIn[dogeListingSellerIndex].SignatureScript)
+	if err != nil || len(pushes) != 2 || len(pushes[0]) == 0 {
+		return fmt.Errorf("卖家签名脚本不是P2PKH格式")
+	}
+	if hashType := txscript.SigHashType(pushes[0][len(pushes[0])-1]); hashType != dogeListingHashType {
+		return fmt.Errorf("卖家签名类型不是SIGHASH_SINGLE|ANYONECANPAY: 0x%x", uint32(hashType))
+	}
+	return nil
+}
+
+// verifyPrevout 通过provider获取inscription UTXO所在的交易，pkScript和金额必须与挂单一致
+// 卖家的legacy签名不承诺输入金额，InscriptionValue只能从链上确认
+func (l *DogeListing) verifyPrevout(ctx context.Context, provider UtxoProvider) error {
+	txRaw, err := provider.GetRawTx(ctx, l.InscriptionTxId)
+	if err != nil {
+		return fmt.Errorf("获取inscription交易%s失败: %w", l.InscriptionTxId, err)
+	}
+	raw, err := hex.DecodeString(txRaw)
+	if err != nil {
+		return fmt.Errorf("%w: 解码inscription交易失败: %v", ErrInvalidUtxo, err)
+	}
+	var prevTx wire.MsgTx
+	if err := prevTx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return fmt.Errorf("%w: 反序列化inscription交易失败: %v", ErrInvalidUtxo, err)
+	}
+	if prevTx.TxHash().String() != l.InscriptionTxId {
+		return fmt.Errorf("%w: 获取的交易不是%s", ErrInvalidUtxo, l.InscriptionTxId)
+	}
+	if l.InscriptionVout < 0 || l.InscriptionVout >= int64(len(prevTx.TxOut)) {
+		return fmt.Errorf("%w: 交易%s没有输出%d", ErrInvalidUtxo, l.InscriptionTxId, l.InscriptionVout)
+	}
+	prevOut := prevTx.TxOut[l.InscriptionVout]
+	if hex.EncodeToString(prevOut.PkScript) != l.InscriptionPkScript || prevOut.Value != l.InscriptionValue {
+		return fmt.Errorf("%w: 挂单中的inscription UTXO与链上不一致: 金额%d, 链上%d", ErrInvalidUtxo, l.InscriptionValue, prevOut.Value)
+	}
+	return nil
+}
+
+// VerifyDogeListing 验证挂单中卖家的签名，并通过provider确认inscription UTXO的pkScript和金额，买家在付款前必须验证
+// 挂单中的字段都由卖家提供，签名不承诺InscriptionValue，不与链上比较时卖家可以虚报金额让买家多付
+func VerifyDogeListing(ctx context.Context, netParam *chaincfg.Params, provider UtxoProvider, listing *DogeListing) error {
+	if listing == nil {
+		return fmt.Errorf("%w: 挂单为空", ErrInvalidOption)
+	}
+	if provider == nil {
+		return fmt.Errorf("%w: 没有设置UtxoProvider，无法确认inscription UTXO", ErrInvalidOption)
+	}
+	tx, err := listing.templateTx(netParam)
+	if err != nil {
+		return err
+	}
+	if err := listing.verifySellerInput(tx); err != nil {
+		return err
+	}
+	return listing.verifyPrevout(ctx, provider)
+}
+
+// BuildDogeListingPurchaseTx 买家完成挂单，返回签名后可以直接广播的交易
+// 先用VerifyDogeListing验证签名并通过provider确认inscription UTXO，输出0的金额按链上的金额计算；
+// buyerUtxos中金额最小的UTXO作为输入0，其余依次用于支付价格和手续费；buyerUtxos中不能包含inscription。
+// 构建完成后再次验证卖家的签名，确认买家添加的输入和输出没有使其失效
+func BuildDogeListingPurchaseTx(
+	ctx context.Context,
+	netParam *chaincfg.Params,
+	provider UtxoProvider,
+	listing *DogeListing,
+	buyerUtxos []*TxInputUtxo,
+	receiveAddress string,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+) (*wire.MsgTx, error) {
+	if err := VerifyDogeListing(ctx, netParam, provider, listing); err != nil {
+		return nil, err
+	}
+	if changeAddress == "" {
+		return nil, fmt.Errorf("%w: 没有设置找零地址", ErrInvalidOption)
+	}
+
+	// 金额最小的UTXO作为输入0，其余保持传入的顺序
+	fundingUtxos := make([]*TxInputUtxo, 0, len(buyerUtxos))
+	for _, utxo := range buyerUtxos {
+		if utxo.TxId == listing.InscriptionTxId && utxo.TxIndex == listing.InscriptionVout {
+			continue
+		}
+		fundingUtxos = append(fundingUtxos, utxo)
+	}
+	if len(fundingUtxos) == 0 {
+		return nil, &ErrInsufficientFunds{Needed: listing.Price, Available: 0}
+	}
+	first := 0
+	for i, utxo := range fundingUtxos {
+		if utxo.Amount < fundingUtxos[first].Amount {
+			first = i
+		}
+	}
+	firstUtxo := fundingUtxos[first]
+	fundingUtxos = append(fundingUtxos[:first:first], fundingUtxos[first+1:]...)
+
+	receiveAddr, err := decodeDogeAddress(receiveAddress, netParam)
+	if err != nil {
+		return nil, fmt.Errorf("解码接收地址失败: %w", err)
+	}
+	receivePkScript, err := txscript.PayToAddrScript(receiveAddr)
+	if err != nil {
+		return nil, fmt.Errorf("构建接收地址脚本失败: %w", err)
+	}
+
+	tx := wire.NewMsgTx(2)
+	firstTxIn, err := utxoTxIn(firstUtxo)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxIn(firstTxIn)
+	sellerTxIn, err := listing.sellerTxIn()
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxIn(sellerTxIn)
+	receiveValue := int64(firstUtxo.Amount) + listing.InscriptionValue
+	tx.AddTxOut(wire.NewTxOut(receiveValue, receivePkScript))
+	priceTxOut, err := listing.priceTxOut(netParam)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxOut(priceTxOut)
+
+	// 输入0还没有签名，签名脚本约107字节；卖家的签名脚本已经计入交易大小
+	usedUtxos, _, _, err := fundTransaction(
+		tx,
+		fundingUtxos,
+		changeAddress,
+		netParam,
+		feeRate,
+		receiveValue,
+		107,
+		dogeLog(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fund购买交易失败: %w", err)
+	}
+
+	if err := signTransactionInputs(tx, []*TxInputUtxo{firstUtxo}, 0); err != nil {
+		return nil, fmt.Errorf("签名购买交易失败: %w", err)
+	}
+	if err := signTransactionInputs(tx, usedUtxos, dogeListingSellerIndex+1); err != nil {
+		return nil, fmt.Errorf("签名购买交易失败: %w", err)
+	}
+	if err := listing.verifySellerInput(tx); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
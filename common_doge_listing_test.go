@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestDogeListing(t *testing.T) {
+	ctx := context.Background()
+	sim, priHex, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	builder, err := NewInscriptionBuilder(WithDoginal([]byte("hi"), "text/plain"), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+	id, err := result.InscriptionId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utxos, err = ListSpendableUtxos(context.Background(), sim, address, priHex); err != nil {
+		t.Fatal(err)
+	}
+	var inscriptionUtxo *TxInputUtxo
+	for _, utxo := range utxos {
+		if utxo.TxId == id.TxId && utxo.TxIndex == 0 {
+			inscriptionUtxo = utxo
+		}
+	}
+	if inscriptionUtxo == nil {
+		t.Fatal("没有找到inscription UTXO")
+	}
+
+	listing, err := BuildDogeListing(DogeMainNetParams, inscriptionUtxo, address, 10e8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 挂单经过JSON传给买家后仍然有效
+	raw, err := json.Marshal(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var received DogeListing
+	if err := json.Unmarshal(raw, &received); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyDogeListing(ctx, DogeMainNetParams, sim, &received); err != nil {
+		t.Fatal(err)
+	}
+	tampered := received
+	tampered.Price++
+	if err := VerifyDogeListing(ctx, DogeMainNetParams, sim, &tampered); err == nil {
+		t.Fatal("修改价格后签名仍然有效")
+	}
+	// 签名不承诺inscription UTXO的金额，虚报的金额只能通过链上的交易发现
+	overvalued := received
+	overvalued.InscriptionValue += 100e8
+	template, err := overvalued.templateTx(DogeMainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := overvalued.verifySellerInput(template); err != nil {
+		t.Fatalf("签名应该不承诺金额: %v", err)
+	}
+	if err := VerifyDogeListing(ctx, DogeMainNetParams, sim, &overvalued); !errors.Is(err, ErrInvalidUtxo) {
+		t.Errorf("虚报inscription金额: %v", err)
+	}
+
+	buyerPriHex, buyer := testDogeKey(t, 2)
+	for _, amount := range []int64{3e8, 20e8} {
+		if _, err := sim.Fund(buyer, amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buyerUtxos, err := ListSpendableUtxos(context.Background(), sim, buyer, buyerPriHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := BuildDogeListingPurchaseTx(ctx, DogeMainNetParams, sim, &received, buyerUtxos, buyer, buyer, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 金额最小的买家UTXO在输入0，inscription落在买家的输出0上
+	if tx.TxIn[1].PreviousOutPoint.Hash.String() != id.TxId || tx.TxOut[1].Value != 10e8 || tx.TxOut[0].Value != 3e8+int64(inscriptionUtxo.Amount) {
+		t.Fatal("购买交易的结构不正确")
+	}
+	testSubmitAndMine(t, sim, tx)
+
+	if _, err := BuildDogeListingPurchaseTx(ctx, DogeMainNetParams, sim, &tampered, buyerUtxos, buyer, buyer, 1000); err == nil {
+		t.Error("被修改的挂单可以购买")
+	}
+	if _, err := BuildDogeListingPurchaseTx(ctx, DogeMainNetParams, sim, &overvalued, buyerUtxos, buyer, buyer, 1000); !errors.Is(err, ErrInvalidUtxo) {
+		t.Errorf("虚报金额的挂单: %v", err)
+	}
+	for name, err := range map[string]error{
+		"inscription UTXO为空": func() error { _, err := BuildDogeListing(DogeMainNetParams, nil, address, 10e8); return err }(),
+		"价格超出范围": func() error {
+			_, err := BuildDogeListing(DogeMainNetParams, inscriptionUtxo, address, dogeMaxMoney+1)
+			return err
+		}(),
+		"挂单为空":           VerifyDogeListing(ctx, DogeMainNetParams, sim, nil),
+		"没有UtxoProvider": VerifyDogeListing(ctx, DogeMainNetParams, nil, &received),
+		"没有找零地址": func() error {
+			_, err := BuildDogeListingPurchaseTx(ctx, DogeMainNetParams, sim, listing, buyerUtxos, buyer, "", 1000)
+			return err
+		}(),
+	} {
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	var dustErr *ErrDustOutput
+	if _, err := BuildDogeListing(DogeMainNetParams, inscriptionUtxo, address, 1); !errors.As(err, &dustErr) {
+		t.Errorf("价格低于dust: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+)
+
+// redacted 代替私钥等敏感字段输出的内容
+const redacted = "[REDACTED]"
+
+// discardHandler 丢弃所有日志的slog.Handler，是包内默认的日志处理器
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+var dogeLogger atomic.Pointer[slog.Logger]
+
+func init() {
+	dogeLogger.Store(slog.New(discardHandler{}))
+}
+
+// SetDogeLogger 设置包内使用的日志，默认不输出任何日志
+// 构建交易的过程（选币、手续费计算、交易链的每一步）输出Debug级别日志；传入nil恢复为不输出
+func SetDogeLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(discardHandler{})
+	}
+	dogeLogger.Store(logger)
+}
+
+// dogeLog 返回当前的日志
+func dogeLog() *slog.Logger {
+	return dogeLogger.Load()
+}
+
+// redactSecret 非空的敏感字段替换为[REDACTED]，空字段保持为空，便于排查没有填充私钥的问题
+func redactSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	return redacted
+}
+
+// String 输出UTXO的字段，PriHex替换为[REDACTED]
+// fmt的%v、%+v、%s都会使用String，所以打印TxInputUtxo不会泄露私钥
+func (u TxInputUtxo) String() string {
+	return fmt.Sprintf("{TxId:%s TxIndex:%d PkScript:%s Amount:%d PriHex:%s SignMode:%s}",
+		u.TxId, u.TxIndex, u.PkScript, u.Amount, redactSecret(u.PriHex), u.SignMode)
+}
+
+// GoString 同String，用于%#v
+func (u TxInputUtxo) GoString() string {
+	return "TxInputUtxo" + u.String()
+}
+
+// LogValue 实现slog.LogValuer，PriHex替换为[REDACTED]
+func (u TxInputUtxo) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("txId", u.TxId),
+		slog.Int64("txIndex", u.TxIndex),
+		slog.Uint64("amount", u.Amount),
+		slog.String("signMode", string(u.SignMode)),
+		slog.String("priHex", redactSecret(u.PriHex)),
+	)
+}
+
+// utxosLogValue UTXO列表的日志内容，每个UTXO使用LogValue，不包含私钥
+func utxosLogValue(utxos []*TxInputUtxo) slog.Value {
+	attrs := make([]slog.Attr, 0, len(utxos))
+	for i, utxo := range utxos {
+		if utxo == nil {
+			continue
+		}
+		attrs = append(attrs, slog.Any(fmt.Sprint(i), *utxo))
+	}
+	return slog.GroupValue(attrs...)
+}
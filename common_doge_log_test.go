@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// testBuildWithLogger 设置日志后构建一条铭文交易链和一笔普通交易，返回钱包的私钥
+func testBuildWithLogger(t *testing.T, logger *slog.Logger) string {
+	SetDogeLogger(logger)
+	defer SetDogeLogger(nil)
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8, 1e8)
+	if _, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, bytes.Repeat([]byte("a"), 3000), "/x", utxos, address, 0, address, 1000, false, InscriptionFormatMetaID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BuildDogeCommonTx(DogeMainNetParams, utxos[1:], []*TxOutput{{Address: address, Amount: 1e7}}, address, 1000, false); err != nil {
+		t.Fatal(err)
+	}
+	return utxos[0].PriHex
+}
+
+func TestDogeLogger(t *testing.T) {
+	var out bytes.Buffer
+	priHex := testBuildWithLogger(t, slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	log := out.String()
+	for _, message := range []string{"计算交易手续费", "P2SH Inscription临时密钥对", "更新可用UTXO"} {
+		if !strings.Contains(log, message) {
+			t.Errorf("日志中没有%q", message)
+		}
+	}
+	// 日志中只有[REDACTED]，没有私钥
+	if strings.Contains(log, priHex) || !strings.Contains(log, redacted) {
+		t.Errorf("日志泄露私钥或没有脱敏: %s", log)
+	}
+
+	// Info级别不输出Debug日志
+	out.Reset()
+	testBuildWithLogger(t, slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	if out.Len() != 0 {
+		t.Errorf("Info级别输出了Debug日志: %s", out.String())
+	}
+
+	// 默认不输出，SetDogeLogger(nil)恢复默认
+	if dogeLog().Enabled(context.Background(), slog.LevelError) {
+		t.Error("默认日志没有静默")
+	}
+}
+
+// TestTxInputUtxoRedaction 直接把TxInputUtxo传给fmt或slog也不会输出私钥
+func TestTxInputUtxoRedaction(t *testing.T) {
+	priHex, _ := testDogeKey(t, 1)
+	utxo := &TxInputUtxo{TxId: "00", TxIndex: 1, Amount: 1e8, PriHex: priHex, SignMode: SignModeLegacy}
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, nil))
+	logger.Info("utxo", slog.Any("value", *utxo), slog.Any("pointer", utxo))
+	outputs := []string{out.String()}
+	for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+		outputs = append(outputs, fmt.Sprintf(format, *utxo), fmt.Sprintf(format, utxo), fmt.Sprintf(format, []*TxInputUtxo{utxo}))
+	}
+	for _, output := range outputs {
+		if strings.Contains(output, priHex) || !strings.Contains(output, redacted) {
+			t.Errorf("没有脱敏: %s", output)
+		}
+	}
+}
+
+// TestBuilderLogger WithLogger只作用于当前构建，不修改包内的日志
+func TestBuilderLogger(t *testing.T) {
+	var out bytes.Buffer
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	builder, err := NewInscriptionBuilder(
+		WithMetaIdPin(&DogeMetaIdPin{Path: "/x", Body: bytes.Repeat([]byte("a"), 3000)}),
+		WithUtxos(utxos),
+		WithChangeAddress(address),
+		WithFeeRate(1000),
+		WithLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := builder.Build(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "更新可用UTXO") || strings.Contains(out.String(), utxos[0].PriHex) {
+		t.Errorf("WithLogger的日志: %s", out.String())
+	}
+	if dogeLog().Enabled(context.Background(), slog.LevelError) {
+		t.Error("WithLogger修改了包内的日志")
+	}
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MetaFile分片上传的路径和内容类型
+// 文件按ChunkSize拆分为多个/file/_chunk PIN，最后一个/file/index PIN列出所有分片的PIN id，
+// index PIN的id即为文件的id，metafile://<index PIN id>可以像单个PIN的文件一样使用
+const (
+	DogeMetaFilePathChunk        = "/file/_chunk"
+	DogeMetaFilePathIndex        = "/file/index"
+	DogeMetaFileChunkContentType = "metafile/chunk;binary"
+	DogeMetaFileIndexContentType = "metafile/index;utf-8"
+
+	// DogeMetaFileDefaultChunkSize 默认分片大小，每个分片的交易链约16笔交易；
+	// 分片依次用上一个分片的找零支付，多个分片会超过节点的未确认祖先数量限制，需要按Batches分批广播
+	DogeMetaFileDefaultChunkSize = 20 * 1024
+)
+
+// DogeMetaFile 分片上传的文件
+type DogeMetaFile struct {
+	Data          []byte
+	Name          string
+	ContentType   string // 文件的内容类型，例如image/png
+	ChunkSize     int    // 为0时为DogeMetaFileDefaultChunkSize
+	OutputAddress string // 所有PIN的接收地址，为空时使用找零地址
+}
+
+// DogeMetaFileChunkRef index PIN中的一个分片
+type DogeMetaFileChunkRef struct {
+	Sha256 string `json:"sha256"`
+	PinId  string `json:"pinId"`
+}
+
+// DogeMetaFileIndex index PIN的内容
+type DogeMetaFileIndex struct {
+	Sha256      string                  `json:"sha256"` // 整个文件的SHA-256，十六进制
+	FileSize    int64                   `json:"fileSize"`
+	ChunkNumber int                     `json:"chunkNumber"`
+	ChunkSize   int                     `json:"chunkSize"`
+	DataType    string                  `json:"dataType"` // 文件的内容类型
+	Name        string                  `json:"name"`
+	ChunkList   []*DogeMetaFileChunkRef `json:"chunkList"`
+}
+
+// DogeMetaFileUpload 分片上传的结果
+type DogeMetaFileUpload struct {
+	IndexPinId  string
+	ChunkPinIds []string
+	URI         string // metafile://<index PIN id>
+	Chains      []*DogePinChain
+	// Result 所有PIN的费用明细，交易按广播顺序排列
+	Result *DogeInscriptionResult
+	// Batches Result中的交易按内存池交易链限制分组，见DogeBroadcastBatches：
+	// 每组确认后再广播下一组，只有一组时可以直接按Result的顺序广播
+	Batches [][]*wire.MsgTx
+}
+
+// BuildDogeMetaFileUpload 把文件拆分为分片PIN和一个index PIN，共用ins依次构建
+// index PIN中的分片PIN id在构建时通过引用填入，所以只需要一次调用，按Batches分批广播
+func BuildDogeMetaFileUpload(
+	netParam *chaincfg.Params,
+	file *DogeMetaFile,
+	ins []*TxInputUtxo,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+) (*DogeMetaFileUpload, error) {
+	if file == nil || len(file.Data) == 0 {
+		return nil, fmt.Errorf("%w: 文件内容为空", ErrInvalidOption)
+	}
+	if file.ContentType == "" {
+		return nil, fmt.Errorf("%w: 文件缺少内容类型", ErrInvalidOption)
+	}
+	chunkSize := file.ChunkSize
+	if chunkSize == 0 {
+		chunkSize = DogeMetaFileDefaultChunkSize
+	}
+	if chunkSize < 0 {
+		return nil, fmt.Errorf("%w: 分片大小不能为负数: %d", ErrInvalidOption, chunkSize)
+	}
+
+	fileHash := sha256.Sum256(file.Data)
+	index := &DogeMetaFileIndex{
+		Sha256:    hex.EncodeToString(fileHash[:]),
+		FileSize:  int64(len(file.Data)),
+		ChunkSize: chunkSize,
+		DataType:  file.ContentType,
+		Name:      file.Name,
+	}
+	details := make([]*DogePinDetail, 0, len(file.Data)/chunkSize+2)
+	refs := make(map[string]int)
+	for i := 0; i < len(file.Data); i += chunkSize {
+		end := i + chunkSize
+		if end > len(file.Data) {
+			end = len(file.Data)
+		}
+		chunk := file.Data[i:end]
+		chunkHash := sha256.Sum256(chunk)
+		placeholder := fmt.Sprintf("{{chunk%d}}", len(details))
+		refs[placeholder] = len(details)
+		index.ChunkList = append(index.ChunkList, &DogeMetaFileChunkRef{
+			Sha256: hex.EncodeToString(chunkHash[:]),
+			PinId:  DogeInscriptionId{TxId: placeholder}.String(), // 替换后为分片的PIN id
+		})
+		details = append(details, &DogePinDetail{
+			Pin: &DogeMetaIdPin{
+				Path:        DogeMetaFilePathChunk,
+				ContentType: DogeMetaFileChunkContentType,
+				Body:        chunk,
+			},
+			OutputAddress: file.OutputAddress,
+		})
+	}
+	index.ChunkNumber = len(index.ChunkList)
+
+	body, err := json.Marshal(index)
+	if err != nil {
+		return nil, fmt.Errorf("序列化MetaFile index失败: %w", err)
+	}
+	details = append(details, &DogePinDetail{
+		Pin: &DogeMetaIdPin{
+			Path:        DogeMetaFilePathIndex,
+			ContentType: DogeMetaFileIndexContentType,
+			Body:        body,
+		},
+		OutputAddress: file.OutputAddress,
+		Refs:          refs,
+	})
+
+	chains, err := BuildDogeMetaIdPinBatch(netParam, details, ins, changeAddress, feeRate)
+	if err != nil {
+		return nil, err
+	}
+	upload := &DogeMetaFileUpload{
+		ChunkPinIds: make([]string, 0, index.ChunkNumber),
+		Chains:      chains,
+	}
+	results := make([]*DogeInscriptionResult, 0, len(chains))
+	for _, chain := range chains {
+		results = append(results, chain.Result)
+	}
+	upload.Result = MergeDogeInscriptionResults(results)
+	upload.Batches = DogeBroadcastBatches(upload.Result.Txs)
+	for _, chain := range chains[:index.ChunkNumber] {
+		upload.ChunkPinIds = append(upload.ChunkPinIds, chain.PinId)
+	}
+	upload.IndexPinId = chains[len(chains)-1].PinId
+	upload.URI = DogeMetaFileScheme + upload.IndexPinId
+	return upload, nil
+}
+
+// DogePinLookup 按PIN id查询PIN的内容，例如通过索引器接口或节点获取reveal交易后用ParseInscriptionFromTx解析
+type DogePinLookup interface {
+	LookupPin(ctx context.Context, pinId string) (*InscriptionData, error)
+}
+
+// DogeMetaFileContent 重新组装后的文件
+type DogeMetaFileContent struct {
+	Data        []byte
+	ContentType string
+	Name        string
+}
+
+// ReassembleDogeMetaFile 根据index PIN获取所有分片并重新组装文件
+// 每个分片和整个文件的SHA-256、文件大小都必须与index一致，否则返回包装了ErrMetaFileCorrupt的错误
+func ReassembleDogeMetaFile(ctx context.Context, lookup DogePinLookup, indexPinId string) (*DogeMetaFileContent, error) {
+	indexPin, err := lookup.LookupPin(ctx, indexPinId)
+	if err != nil {
+		return nil, fmt.Errorf("获取MetaFile index %s失败: %w", indexPinId, err)
+	}
+	if indexPin.Format != InscriptionFormatMetaID || trimMetaIdHost(indexPin.Path) != DogeMetaFilePathIndex {
+		return nil, fmt.Errorf("%w: %s不是MetaFile index", ErrMetaFileCorrupt, indexPinId)
+	}
+	index := &DogeMetaFileIndex{}
+	if err := json.Unmarshal(indexPin.Data, index); err != nil {
+		return nil, fmt.Errorf("%w: 解析index失败: %v", ErrMetaFileCorrupt, err)
+	}
+	if index.ChunkNumber != len(index.ChunkList) || index.FileSize < 0 {
+		return nil, fmt.Errorf("%w: chunkNumber与chunkList不一致: %d != %d", ErrMetaFileCorrupt, index.ChunkNumber, len(index.ChunkList))
+	}
+
+	var file bytes.Buffer
+	for i, ref := range index.ChunkList {
+		if ref == nil {
+			return nil, fmt.Errorf("%w: 第%d个分片为空", ErrMetaFileCorrupt, i)
+		}
+		chunkPin, err := lookup.LookupPin(ctx, ref.PinId)
+		if err != nil {
+			return nil, fmt.Errorf("获取第%d个分片%s失败: %w", i, ref.PinId, err)
+		}
+		if chunkPin.Format != InscriptionFormatMetaID || trimMetaIdHost(chunkPin.Path) != DogeMetaFilePathChunk {
+			return nil, fmt.Errorf("%w: 第%d个分片%s不是MetaFile分片", ErrMetaFileCorrupt, i, ref.PinId)
+		}
+		chunkHash := sha256.Sum256(chunkPin.Data)
+		if hex.EncodeToString(chunkHash[:]) != ref.Sha256 {
+			return nil, fmt.Errorf("%w: 第%d个分片%s的SHA-256不一致", ErrMetaFileCorrupt, i, ref.PinId)
+		}
+		if int64(file.Len()+len(chunkPin.Data)) > index.FileSize {
+			return nil, fmt.Errorf("%w: 分片总大小超过fileSize %d", ErrMetaFileCorrupt, index.FileSize)
+		}
+		file.Write(chunkPin.Data)
+	}
+	if int64(file.Len()) != index.FileSize {
+		return nil, fmt.Errorf("%w: 文件大小不一致: %d != %d", ErrMetaFileCorrupt, file.Len(), index.FileSize)
+	}
+	fileHash := sha256.Sum256(file.Bytes())
+	if hex.EncodeToString(fileHash[:]) != index.Sha256 {
+		return nil, fmt.Errorf("%w: 文件的SHA-256不一致", ErrMetaFileCorrupt)
+	}
+	return &DogeMetaFileContent{
+		Data:        file.Bytes(),
+		ContentType: index.DataType,
+		Name:        index.Name,
+	}, nil
+}
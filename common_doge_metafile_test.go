@@ -0,0 +1,152 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// testPinLookup 用内存中的PIN实现DogePinLookup
+type testPinLookup map[string]*InscriptionData
+
+func (l testPinLookup) LookupPin(ctx context.Context, pinId string) (*InscriptionData, error) {
+	pin, ok := l[pinId]
+	if !ok {
+		return nil, fmt.Errorf("PIN %s不存在", pinId)
+	}
+	return pin, nil
+}
+
+func TestDogeMetaFile(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 500e8)
+	data := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(data)
+	upload, err := BuildDogeMetaFileUpload(DogeMainNetParams, &DogeMetaFile{Data: data, Name: "a.bin", ContentType: "application/octet-stream", ChunkSize: 3000}, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, upload.Result.Txs...)
+	if len(upload.ChunkPinIds) != 4 || len(upload.Chains) != 5 || upload.IndexPinId != upload.Chains[4].PinId || upload.URI != DogeMetaFileScheme+upload.IndexPinId {
+		t.Fatalf("%+v", upload)
+	}
+
+	// 从交易链解析每个PIN，与索引器返回的内容相同
+	lookup := testPinLookup{}
+	for _, chain := range upload.Chains {
+		pin, err := ParseInscriptionFromChain(testChainHexes(t, chain.Txs), InscriptionFormatMetaID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lookup[chain.PinId] = pin
+	}
+	var index DogeMetaFileIndex
+	if err := json.Unmarshal(lookup[upload.IndexPinId].Data, &index); err != nil {
+		t.Fatal(err)
+	}
+	if index.ChunkNumber != 4 || index.FileSize != 10000 || index.ChunkList[3].PinId != upload.ChunkPinIds[3] {
+		t.Errorf("index: %s", lookup[upload.IndexPinId].Data)
+	}
+
+	file, err := ReassembleDogeMetaFile(context.Background(), lookup, upload.IndexPinId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(file.Data, data) || file.Name != "a.bin" || file.ContentType != "application/octet-stream" {
+		t.Errorf("重新组装的文件: %s %s", file.Name, file.ContentType)
+	}
+
+	// 分片内容被替换、PIN不是分片、index不是index时返回ErrMetaFileCorrupt
+	for name, corrupt := range map[string]func(l testPinLookup){
+		"分片内容": func(l testPinLookup) {
+			l[upload.ChunkPinIds[2]] = &InscriptionData{Format: InscriptionFormatMetaID, Path: DogeMetaFilePathChunk, Data: data[:3000]}
+		},
+		"分片路径": func(l testPinLookup) {
+			l[upload.ChunkPinIds[0]] = &InscriptionData{Format: InscriptionFormatMetaID, Path: "/info/name", Data: data[:3000]}
+		},
+		"index路径": func(l testPinLookup) {
+			l[upload.IndexPinId] = &InscriptionData{Format: InscriptionFormatMetaID, Path: DogeMetaFilePathChunk, Data: l[upload.IndexPinId].Data}
+		},
+		"index内容": func(l testPinLookup) {
+			l[upload.IndexPinId] = &InscriptionData{Format: InscriptionFormatMetaID, Path: DogeMetaFilePathIndex, Data: []byte("{")}
+		},
+		"文件大小": func(l testPinLookup) {
+			changed := index
+			changed.FileSize = 9999
+			content, _ := json.Marshal(&changed)
+			l[upload.IndexPinId] = &InscriptionData{Format: InscriptionFormatMetaID, Path: DogeMetaFilePathIndex, Data: content}
+		},
+		"分片数量": func(l testPinLookup) {
+			changed := index
+			changed.ChunkNumber = 3
+			content, _ := json.Marshal(&changed)
+			l[upload.IndexPinId] = &InscriptionData{Format: InscriptionFormatMetaID, Path: DogeMetaFilePathIndex, Data: content}
+		},
+	} {
+		corrupted := testPinLookup{}
+		for pinId, pin := range lookup {
+			corrupted[pinId] = pin
+		}
+		corrupt(corrupted)
+		if _, err := ReassembleDogeMetaFile(context.Background(), corrupted, upload.IndexPinId); !errors.Is(err, ErrMetaFileCorrupt) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	// 找不到分片是查询失败，不是文件损坏
+	missing := testPinLookup{}
+	for pinId, pin := range lookup {
+		missing[pinId] = pin
+	}
+	delete(missing, upload.ChunkPinIds[1])
+	if _, err := ReassembleDogeMetaFile(context.Background(), missing, upload.IndexPinId); err == nil || errors.Is(err, ErrMetaFileCorrupt) {
+		t.Errorf("缺少分片: %v", err)
+	}
+}
+
+// TestDogeMetaFileUploadBatches 默认分片大小的多个分片超过未确认祖先数量限制，按Batches分批广播
+func TestDogeMetaFileUploadBatches(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 5000e8)
+	data := make([]byte, 3*DogeMetaFileDefaultChunkSize)
+	rand.New(rand.NewSource(2)).Read(data)
+	upload, err := BuildDogeMetaFileUpload(DogeMainNetParams, &DogeMetaFile{Data: data, Name: "b.bin", ContentType: "application/octet-stream"}, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upload.Result.Txs) <= DefaultDogePolicy.MaxAncestorCount || len(upload.Batches) < 2 {
+		t.Fatalf("%d笔交易分为%d组", len(upload.Result.Txs), len(upload.Batches))
+	}
+	// 同样资金的另一个模拟账本，一次广播所有交易会超过限制
+	if other, _, _, _ := testSimulatorWallet(t, 1, 5000e8); other.SubmitTxs(upload.Result.Txs) == nil {
+		t.Fatal("一次广播所有交易应该被拒绝")
+	}
+	count := 0
+	for _, batch := range upload.Batches {
+		for _, tx := range batch {
+			if tx != upload.Result.Txs[count] {
+				t.Fatalf("第%d笔交易不是按广播顺序分组", count)
+			}
+			count++
+		}
+		testSubmitAndMine(t, sim, batch...)
+	}
+	if count != len(upload.Result.Txs) {
+		t.Errorf("分组中有%d笔交易, 期望%d", count, len(upload.Result.Txs))
+	}
+}
+
+func TestBuildDogeMetaFileUploadInvalid(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	for name, file := range map[string]*DogeMetaFile{
+		"没有内容":    {ContentType: "text/plain"},
+		"没有内容类型":  {Data: []byte("a")},
+		"分片大小为负数": {Data: []byte("a"), ContentType: "text/plain", ChunkSize: -1},
+	} {
+		if _, err := BuildDogeMetaFileUpload(DogeMainNetParams, file, utxos, address, 1000); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
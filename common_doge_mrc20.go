@@ -0,0 +1,378 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MRC-20 PIN的路径，内容为application/json，对应插件src/lib/actions/btc/mrc20-*.ts的body
+const (
+	DogeMrc20PathDeploy   = "/ft/mrc20/deploy"
+	DogeMrc20PathMint     = "/ft/mrc20/mint"
+	DogeMrc20PathTransfer = "/ft/mrc20/transfer"
+
+	DogeMrc20OpDeploy   = "deploy"
+	DogeMrc20OpMint     = "mint"
+	DogeMrc20OpTransfer = "transfer"
+
+	// DogeMrc20MaxDecimals decimals的最大值
+	DogeMrc20MaxDecimals = 12
+)
+
+// DogeMrc20Qual mint的资格：持有指定路径的PIN才能mint
+type DogeMrc20Qual struct {
+	Path  string `json:"path,omitempty"`
+	Count string `json:"count,omitempty"`
+	Lvl   string `json:"lvl,omitempty"`
+}
+
+// DogeMrc20Deploy MRC-20 deploy的内容，与插件MRC20DeployParams.body的字段一致，所有值都是字符串
+type DogeMrc20Deploy struct {
+	Tick         string         `json:"tick"`      // 2到24个字符
+	TokenName    string         `json:"tokenName"` // 1到48个字符
+	Decimals     string         `json:"decimals"`
+	AmtPerMint   string         `json:"amtPerMint"`
+	MintCount    string         `json:"mintCount"`
+	PremineCount string         `json:"premineCount,omitempty"` // 不能超过mintCount
+	BlockHeight  string         `json:"blockheight,omitempty"`  // 开始mint的区块高度
+	Metadata     string         `json:"metadata,omitempty"`
+	Qual         *DogeMrc20Qual `json:"qual,omitempty"`
+}
+
+// DogeMrc20Mint MRC-20 mint的内容，id为deploy PIN的id（<txid>i<index>）
+type DogeMrc20Mint struct {
+	Id string `json:"id"`
+}
+
+// DogeMrc20TransferItem MRC-20 transfer内容中的一项：把amount个id发送到reveal交易的第vout个输出
+type DogeMrc20TransferItem struct {
+	Vout   int    `json:"vout"`
+	Id     string `json:"id"`
+	Amount string `json:"amount"`
+}
+
+// Validate 校验deploy的字段
+func (d *DogeMrc20Deploy) Validate() error {
+	if n := utf8.RuneCountInString(d.Tick); !utf8.ValidString(d.Tick) || n < 2 || n > 24 {
+		return fmt.Errorf("%w: tick必须是2到24个字符: %q", ErrNotMrc20, d.Tick)
+	}
+	if n := utf8.RuneCountInString(d.TokenName); !utf8.ValidString(d.TokenName) || n < 1 || n > 48 {
+		return fmt.Errorf("%w: tokenName必须是1到48个字符: %q", ErrNotMrc20, d.TokenName)
+	}
+	if _, err := parseMrc20Decimals(d.Decimals); err != nil {
+		return err
+	}
+	if _, err := parseMrc20Count("amtPerMint", d.AmtPerMint, false); err != nil {
+		return err
+	}
+	mintCount, err := parseMrc20Count("mintCount", d.MintCount, false)
+	if err != nil {
+		return err
+	}
+	if d.PremineCount != "" {
+		premineCount, err := parseMrc20Count("premineCount", d.PremineCount, true)
+		if err != nil {
+			return err
+		}
+		if premineCount > mintCount {
+			return fmt.Errorf("%w: premineCount不能大于mintCount: %s > %s", ErrNotMrc20, d.PremineCount, d.MintCount)
+		}
+	}
+	if d.BlockHeight != "" {
+		if _, err := parseMrc20Count("blockheight", d.BlockHeight, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pin 校验后生成deploy PIN
+func (d *DogeMrc20Deploy) Pin() (*DogeMetaIdPin, error) {
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+	return newDogeMrc20Pin(DogeMrc20PathDeploy, d)
+}
+
+// NewDogeMrc20MintPin 生成mint PIN
+// deploy设置了qual时，需要用WithRevealInputs把满足条件的PIN UTXO加入reveal交易
+func NewDogeMrc20MintPin(tickId string) (*DogeMetaIdPin, error) {
+	if err := validateMrc20TickId(tickId); err != nil {
+		return nil, err
+	}
+	return newDogeMrc20Pin(DogeMrc20PathMint, &DogeMrc20Mint{Id: tickId})
+}
+
+// newDogeMrc20Pin 把内容序列化为JSON并生成PIN
+func newDogeMrc20Pin(path string, body interface{}) (*DogeMetaIdPin, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化MRC-20内容失败: %w", err)
+	}
+	return &DogeMetaIdPin{
+		Path:        path,
+		ContentType: "application/json",
+		Body:        data,
+	}, nil
+}
+
+// validateMrc20TickId 校验token id，格式为<txid>i<index>
+func validateMrc20TickId(tickId string) error {
+	if !isDogePinId(tickId) {
+		return fmt.Errorf("%w: 无效的token id: %q", ErrNotMrc20, tickId)
+	}
+	return nil
+}
+
+// isHexString s是否只包含小写十六进制字符
+func isHexString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if (s[i] < '0' || s[i] > '9') && (s[i] < 'a' || s[i] > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseMrc20Decimals 解析decimals，只能是0到12的十进制整数
+func parseMrc20Decimals(s string) (int, error) {
+	dec, err := strconv.Atoi(s)
+	if err != nil || strconv.Itoa(dec) != s || dec < 0 || dec > DogeMrc20MaxDecimals {
+		return 0, fmt.Errorf("%w: decimals必须是0到%d的整数: %q", ErrNotMrc20, DogeMrc20MaxDecimals, s)
+	}
+	return dec, nil
+}
+
+// parseMrc20Count 解析非负的十进制整数，allowZero为false时必须大于0
+func parseMrc20Count(field, s string, allowZero bool) (uint64, error) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || strconv.FormatUint(n, 10) != s || (n == 0 && !allowZero) {
+		return 0, fmt.Errorf("%w: %s必须是正整数: %q", ErrNotMrc20, field, s)
+	}
+	return n, nil
+}
+
+// DogeMrc20TokenUtxo 携带MRC-20 token的UTXO，通常来自索引器的MRC-20 UTXO接口
+type DogeMrc20TokenUtxo struct {
+	Utxo   *TxInputUtxo
+	Amount string // 该UTXO携带的token数量
+}
+
+// DogeMrc20Receiver transfer的接收方
+type DogeMrc20Receiver struct {
+	Address string
+	Amount  string // token数量
+	Value   int64  // 输出的DOGE金额，为0时为dust限制
+}
+
+// DogeMrc20Transfer MRC-20 transfer的参数
+// reveal交易依次花费P2SH输入和所有TokenUtxos，输出依次为reveal输出、每个接收方、token找零，
+// transfer内容中的vout与这些输出对应；输入的token多于转出时，差额转到ChangeAddress，不依赖索引器的默认规则
+type DogeMrc20Transfer struct {
+	TickId        string
+	Decimals      int // token的decimals，用于计算token找零
+	TokenUtxos    []*DogeMrc20TokenUtxo
+	Receivers     []*DogeMrc20Receiver
+	ChangeAddress string // token找零地址，有token找零时必须设置
+}
+
+// plan 计算transfer的内容、reveal输入和reveal输出
+func (t *DogeMrc20Transfer) plan(cfg *dogeChainConfig) ([]*DogeMrc20TransferItem, []*TxInputUtxo, []*wire.TxOut, error) {
+	if err := validateMrc20TickId(t.TickId); err != nil {
+		return nil, nil, nil, err
+	}
+	if t.Decimals < 0 || t.Decimals > DogeMrc20MaxDecimals {
+		return nil, nil, nil, fmt.Errorf("%w: decimals必须是0到%d的整数: %d", ErrNotMrc20, DogeMrc20MaxDecimals, t.Decimals)
+	}
+	if len(t.TokenUtxos) == 0 || len(t.Receivers) == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: MRC-20 transfer需要token UTXO和接收方", ErrInvalidOption)
+	}
+
+	inputs := make([]*TxInputUtxo, 0, len(t.TokenUtxos))
+	available := new(big.Int)
+	for i, tokenUtxo := range t.TokenUtxos {
+		if tokenUtxo == nil || tokenUtxo.Utxo == nil {
+			return nil, nil, nil, fmt.Errorf("%w: 第%d个token UTXO为空", ErrInvalidOption, i)
+		}
+		amount, err := parseTokenAmount(tokenUtxo.Amount, t.Decimals)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: token UTXO %s:%d的数量%v", ErrNotMrc20, tokenUtxo.Utxo.TxId, tokenUtxo.Utxo.TxIndex, err)
+		}
+		available.Add(available, amount)
+		inputs = append(inputs, tokenUtxo.Utxo)
+	}
+
+	items := make([]*DogeMrc20TransferItem, 0, len(t.Receivers)+1)
+	outputs := make([]*wire.TxOut, 0, len(t.Receivers)+1)
+	addOutput := func(address string, value int64, amount *big.Int) error {
+		if value == 0 {
+			value = DogeDustLimit
+		}
+		if value < DogeDustLimit {
+			return &ErrDustOutput{Address: address, Amount: value, Limit: DogeDustLimit}
+		}
+		addr, err := decodeDogeAddress(address, cfg.netParam)
+		if err != nil {
+			return fmt.Errorf("解码MRC-20接收地址失败: %w", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return fmt.Errorf("构建MRC-20接收地址%s的脚本失败: %w", address, err)
+		}
+		outputs = append(outputs, wire.NewTxOut(value, pkScript))
+		items = append(items, &DogeMrc20TransferItem{
+			Vout:   len(outputs), // reveal输出的索引为0
+			Id:     t.TickId,
+			Amount: formatTokenAmount(amount, t.Decimals),
+		})
+		return nil
+	}
+
+	sent := new(big.Int)
+	for i, receiver := range t.Receivers {
+		if receiver == nil {
+			return nil, nil, nil, fmt.Errorf("%w: 第%d个接收方为空", ErrInvalidOption, i)
+		}
+		amount, err := parseTokenAmount(receiver.Amount, t.Decimals)
+		if err != nil || amount.Sign() <= 0 {
+			return nil, nil, nil, fmt.Errorf("%w: 接收方%s的数量必须大于0: %q", ErrNotMrc20, receiver.Address, receiver.Amount)
+		}
+		sent.Add(sent, amount)
+		if err := addOutput(receiver.Address, receiver.Value, amount); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	change := new(big.Int).Sub(available, sent)
+	if change.Sign() < 0 {
+		return nil, nil, nil, fmt.Errorf("%w: token不足: 需要%s, 可用%s", ErrInvalidOption,
+			formatTokenAmount(sent, t.Decimals), formatTokenAmount(available, t.Decimals))
+	}
+	if change.Sign() > 0 {
+		if t.ChangeAddress == "" {
+			return nil, nil, nil, fmt.Errorf("%w: 有token找零%s，但没有设置token找零地址", ErrInvalidOption, formatTokenAmount(change, t.Decimals))
+		}
+		if err := addOutput(t.ChangeAddress, 0, change); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return items, inputs, outputs, nil
+}
+
+// WithMrc20Transfer 铸造MRC-20 transfer PIN，并把token UTXO和接收方输出加入reveal交易
+// PIN的所有者是reveal输出（WithRevealOutput），不要与接收方混淆
+func WithMrc20Transfer(transfer *DogeMrc20Transfer) InscriptionOption {
+	return func(b *InscriptionBuilder) error {
+		if transfer == nil {
+			return fmt.Errorf("%w: MRC-20 transfer为空", ErrInvalidOption)
+		}
+		// 接收地址需要在确定网络之后才能解码
+		b.deferred = append(b.deferred, func() error {
+			items, inputs, outputs, err := transfer.plan(&b.cfg)
+			if err != nil {
+				return err
+			}
+			pin, err := newDogeMrc20Pin(DogeMrc20PathTransfer, items)
+			if err != nil {
+				return err
+			}
+			b.cfg.revealInputs = append(b.cfg.revealInputs, inputs...)
+			b.cfg.revealOutputs = append(b.cfg.revealOutputs, outputs...)
+			return b.setContent(func(chunkLen int) ([]byte, error) {
+				return buildDogeMetaIdPinInscription(pin, chunkLen)
+			})
+		})
+		return nil
+	}
+}
+
+// DogeMrc20Op 从PIN解析出的MRC-20操作，按Op只有一个字段不为空
+type DogeMrc20Op struct {
+	Op       string
+	Deploy   *DogeMrc20Deploy
+	Mint     *DogeMrc20Mint
+	Transfer []*DogeMrc20TransferItem
+}
+
+// ParseDogeMrc20 把解析出的MetaID PIN识别为MRC-20操作
+// 路径可以带host前缀（host:/ft/mrc20/...）；加密的PIN、其他路径或字段不合法时返回包装了ErrNotMrc20的错误
+func ParseDogeMrc20(inscription *InscriptionData) (*DogeMrc20Op, error) {
+	if inscription == nil || inscription.Format != InscriptionFormatMetaID {
+		return nil, fmt.Errorf("%w: 不是MetaID格式", ErrNotMrc20)
+	}
+	if inscription.Encryption != "" && inscription.Encryption != PinEncryptionNone {
+		return nil, fmt.Errorf("%w: PIN已加密", ErrNotMrc20)
+	}
+	path := trimMetaIdHost(inscription.Path)
+
+	op := &DogeMrc20Op{}
+	var err error
+	switch path {
+	case DogeMrc20PathDeploy:
+		op.Op = DogeMrc20OpDeploy
+		if err = unmarshalMrc20(inscription.Data, &op.Deploy); err == nil {
+			err = op.Deploy.Validate()
+		}
+	case DogeMrc20PathMint:
+		op.Op = DogeMrc20OpMint
+		if err = unmarshalMrc20(inscription.Data, &op.Mint); err == nil {
+			err = validateMrc20TickId(op.Mint.Id)
+		}
+	case DogeMrc20PathTransfer:
+		op.Op = DogeMrc20OpTransfer
+		if err = unmarshalMrc20(inscription.Data, &op.Transfer); err == nil {
+			err = validateMrc20TransferItems(op.Transfer)
+		}
+	default:
+		return nil, fmt.Errorf("%w: 路径为%s", ErrNotMrc20, inscription.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// unmarshalMrc20 解析MRC-20的JSON内容
+func unmarshalMrc20(data []byte, v interface{}) error {
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrNotMrc20, err)
+	}
+	return nil
+}
+
+// validateMrc20TransferItems 校验transfer内容，vout从1开始（0是PIN的reveal输出）
+func validateMrc20TransferItems(items []*DogeMrc20TransferItem) error {
+	if len(items) == 0 {
+		return fmt.Errorf("%w: transfer内容为空", ErrNotMrc20)
+	}
+	for i, item := range items {
+		if item == nil {
+			return fmt.Errorf("%w: 第%d项为空", ErrNotMrc20, i)
+		}
+		if item.Vout < 1 {
+			return fmt.Errorf("%w: 第%d项的vout无效: %d", ErrNotMrc20, i, item.Vout)
+		}
+		if err := validateMrc20TickId(item.Id); err != nil {
+			return err
+		}
+		amount, err := parseTokenAmount(item.Amount, DogeMrc20MaxDecimals)
+		if err != nil || amount.Sign() <= 0 {
+			return fmt.Errorf("%w: 第%d项的amount无效: %q", ErrNotMrc20, i, item.Amount)
+		}
+	}
+	return nil
+}
+
+// ParseDogeMrc20FromTx 从reveal交易中解析MRC-20操作
+func ParseDogeMrc20FromTx(txRaw string) (*DogeMrc20Op, error) {
+	inscription, err := ParseInscriptionFromTx(txRaw, InscriptionFormatMetaID)
+	if err != nil {
+		return nil, err
+	}
+	return ParseDogeMrc20(inscription)
+}
@@ -0,0 +1,177 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testPinId 由seed重复组成txid的PIN id
+func testPinId(seed byte) string {
+	return strings.Repeat(fmt.Sprintf("%02x", seed), 32) + "i0"
+}
+
+func TestDogeMrc20Deploy(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	deploy := &DogeMrc20Deploy{Tick: "dogx", TokenName: "Doge X", Decimals: "8", AmtPerMint: "1000", MintCount: "21000", PremineCount: "10", Qual: &DogeMrc20Qual{Path: "/info/name"}}
+	pin, err := deploy.Pin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder, err := NewInscriptionBuilder(WithMetaIdPin(pin), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+	op, err := ParseDogeMrc20FromTx(testTxHex(t, result.Txs[len(result.Txs)-1]))
+	if err != nil || op.Op != DogeMrc20OpDeploy || op.Deploy.Tick != "dogx" || *op.Deploy.Qual != *deploy.Qual {
+		t.Fatalf("%v %+v", err, op)
+	}
+
+	tickId := result.RevealTxIds[len(result.RevealTxIds)-1] + "i0"
+	mintPin, err := NewDogeMrc20MintPin(tickId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	op, err = ParseDogeMrc20(&InscriptionData{Format: InscriptionFormatMetaID, Path: "host:" + mintPin.Path, Data: mintPin.Body})
+	if err != nil || op.Op != DogeMrc20OpMint || op.Mint.Id != tickId {
+		t.Errorf("mint: %v %+v", err, op)
+	}
+
+	for name, deploy := range map[string]*DogeMrc20Deploy{
+		"tick太短":          {Tick: "x", TokenName: "a", Decimals: "8", AmtPerMint: "1", MintCount: "1"},
+		"没有tokenName":     {Tick: "dogx", Decimals: "8", AmtPerMint: "1", MintCount: "1"},
+		"decimals为13":     {Tick: "dogx", TokenName: "a", Decimals: "13", AmtPerMint: "1", MintCount: "1"},
+		"decimals不是最小编码":  {Tick: "dogx", TokenName: "a", Decimals: "08", AmtPerMint: "1", MintCount: "1"},
+		"amtPerMint为0":    {Tick: "dogx", TokenName: "a", Decimals: "8", AmtPerMint: "0", MintCount: "1"},
+		"premine超过mint数量": {Tick: "dogx", TokenName: "a", Decimals: "8", AmtPerMint: "1", MintCount: "1", PremineCount: "2"},
+		"blockheight为负数":  {Tick: "dogx", TokenName: "a", Decimals: "8", AmtPerMint: "1", MintCount: "1", BlockHeight: "-1"},
+	} {
+		if _, err := deploy.Pin(); !errors.Is(err, ErrNotMrc20) || DogeErrorCode(err) != ErrCodeNotMrc20 {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := NewDogeMrc20MintPin("abc"); !errors.Is(err, ErrNotMrc20) {
+		t.Errorf("无效的token id: %v", err)
+	}
+}
+
+func TestDogeMrc20Transfer(t *testing.T) {
+	sim, priHex, address, _ := testSimulatorWallet(t, 1, 50e8)
+	_, receiver := testDogeKey(t, 2)
+	// 两个携带token的UTXO
+	var tokenUtxos []*TxInputUtxo
+	for i := 0; i < 2; i++ {
+		utxo, err := sim.Fund(address, 100000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokenUtxos = append(tokenUtxos, utxo)
+	}
+	utxos, err := ListSpendableUtxos(context.Background(), sim, address, priHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, utxo := range utxos {
+		for i, tokenUtxo := range tokenUtxos {
+			if utxo.TxId == tokenUtxo.TxId {
+				tokenUtxos[i] = utxo
+			}
+		}
+	}
+
+	transfer := &DogeMrc20Transfer{
+		TickId:        testPinId(1),
+		Decimals:      8,
+		TokenUtxos:    []*DogeMrc20TokenUtxo{{Utxo: tokenUtxos[0], Amount: "600"}, {Utxo: tokenUtxos[1], Amount: "400.5"}},
+		Receivers:     []*DogeMrc20Receiver{{Address: receiver, Amount: "1000"}},
+		ChangeAddress: address,
+	}
+	builder, err := NewInscriptionBuilder(
+		WithMrc20Transfer(transfer),
+		WithUtxos(utxos),
+		WithChangeAddress(address),
+		WithFeeRate(1000),
+		WithExtraOutputs(&DogeExtraOutputs{Service: &TxOutput{Address: receiver, Amount: 200000}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+
+	// reveal交易依次花费P2SH和token UTXO，token UTXO不用于支付commit交易的手续费
+	reveal := result.Txs[len(result.Txs)-1]
+	if len(result.Txs) != 2 || len(reveal.TxIn) < 3 ||
+		reveal.TxIn[1].PreviousOutPoint.Hash.String() != tokenUtxos[0].TxId || reveal.TxIn[2].PreviousOutPoint.Hash.String() != tokenUtxos[1].TxId {
+		t.Fatal("reveal交易的输入不是P2SH和token UTXO")
+	}
+	for _, in := range result.Txs[0].TxIn {
+		if hash := in.PreviousOutPoint.Hash.String(); hash == tokenUtxos[0].TxId || hash == tokenUtxos[1].TxId {
+			t.Error("commit交易花费了token UTXO")
+		}
+	}
+	op, err := ParseDogeMrc20FromTx(testTxHex(t, reveal))
+	if err != nil || op.Op != DogeMrc20OpTransfer || len(op.Transfer) != 2 {
+		t.Fatalf("%v %+v", err, op)
+	}
+	// 输出依次为reveal输出、接收方、token找零、服务费
+	if *op.Transfer[0] != (DogeMrc20TransferItem{Vout: 1, Id: transfer.TickId, Amount: "1000"}) ||
+		*op.Transfer[1] != (DogeMrc20TransferItem{Vout: 2, Id: transfer.TickId, Amount: "0.5"}) {
+		t.Errorf("transfer内容: %+v %+v", op.Transfer[0], op.Transfer[1])
+	}
+	detail := result.Details[1]
+	if reveal.TxOut[3].Value != 200000 || detail.ExtraValue != 200000 || detail.LockedValue != 300000 {
+		t.Errorf("reveal输出: %+v", detail)
+	}
+
+	for name, mutate := range map[string]func(transfer *DogeMrc20Transfer){
+		"token不足":     func(transfer *DogeMrc20Transfer) { transfer.Receivers[0].Amount = "2000" },
+		"没有找零地址":      func(transfer *DogeMrc20Transfer) { transfer.ChangeAddress = "" },
+		"没有接收方":       func(transfer *DogeMrc20Transfer) { transfer.Receivers = nil },
+		"空token UTXO": func(transfer *DogeMrc20Transfer) { transfer.TokenUtxos[1] = nil },
+	} {
+		invalid := *transfer
+		invalid.Receivers = []*DogeMrc20Receiver{{Address: receiver, Amount: "1000"}}
+		invalid.TokenUtxos = append([]*DogeMrc20TokenUtxo{}, transfer.TokenUtxos...)
+		mutate(&invalid)
+		_, err := NewInscriptionBuilder(WithMrc20Transfer(&invalid), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	invalid := *transfer
+	invalid.Receivers = []*DogeMrc20Receiver{{Address: receiver, Amount: "1000", Value: 5}}
+	var dustErr *ErrDustOutput
+	if _, err := NewInscriptionBuilder(WithMrc20Transfer(&invalid), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000)); !errors.As(err, &dustErr) {
+		t.Errorf("接收方输出低于dust: %v", err)
+	}
+}
+
+func TestParseDogeMrc20(t *testing.T) {
+	tickId := testPinId(1)
+	for _, inscription := range []*InscriptionData{
+		nil,
+		{Format: InscriptionFormatDoginal, Path: DogeMrc20PathMint},
+		{Format: InscriptionFormatMetaID, Path: "/info/name", Data: []byte(`{"id":"` + tickId + `"}`)},
+		{Format: InscriptionFormatMetaID, Path: DogeMrc20PathMint, Encryption: "1", Data: []byte(`{"id":"` + tickId + `"}`)},
+		{Format: InscriptionFormatMetaID, Path: DogeMrc20PathMint, Data: []byte(`{"id":"x"}`)},
+		{Format: InscriptionFormatMetaID, Path: DogeMrc20PathMint, Data: []byte(`not json`)},
+		{Format: InscriptionFormatMetaID, Path: DogeMrc20PathTransfer, Data: []byte(`[]`)},
+		{Format: InscriptionFormatMetaID, Path: DogeMrc20PathTransfer, Data: []byte(`[{"vout":0,"id":"` + tickId + `","amount":"1"}]`)},
+		{Format: InscriptionFormatMetaID, Path: DogeMrc20PathTransfer, Data: []byte(`[{"vout":1,"id":"` + tickId + `","amount":"0"}]`)},
+	} {
+		if _, err := ParseDogeMrc20(inscription); !errors.Is(err, ErrNotMrc20) {
+			t.Errorf("%+v: %v", inscription, err)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// MRC-721 PIN的路径，集合名称是路径的一部分，item和desc通过路径引用集合，
+// 与插件src/lib/mrc721.ts中MRC721Collection、MRC721Item的字段对应
+const (
+	DogeMrc721PathPrefix = "/nft/mrc721/"
+	dogeMrc721ItemSuffix = "/item"
+	dogeMrc721DescSuffix = "/item/desc"
+)
+
+// DogeMrc721Collection 集合定义PIN的内容
+type DogeMrc721Collection struct {
+	Name        string `json:"name"`
+	TotalSupply int64  `json:"totalSupply"`
+	RoyaltyRate int    `json:"royaltyRate"` // 版税百分比，0到100
+	Desc        string `json:"desc,omitempty"`
+	Website     string `json:"website,omitempty"`
+	Cover       string `json:"cover,omitempty"` // metafile://<PIN id>
+	Metadata    string `json:"metadata,omitempty"`
+}
+
+// DogeMrc721Item 集合中的一个item，Content作为item PIN的内容上链
+// Name、Desc、Cover、Metadata不为空时写入desc PIN
+type DogeMrc721Item struct {
+	Content     []byte
+	ContentType string // 例如image/png
+	Name        string
+	Desc        string
+	Cover       string
+	Metadata    string
+}
+
+// dogeMrc721ItemDesc desc PIN中一个item的描述，ItemPinId为item PIN的id
+// 字段名与插件src/lib/mrc721.ts的MRC721Item一致，注意是metaData，而集合定义中是metadata
+type dogeMrc721ItemDesc struct {
+	ItemPinId string `json:"itemPinId"`
+	Name      string `json:"name,omitempty"`
+	Desc      string `json:"desc,omitempty"`
+	Cover     string `json:"cover,omitempty"`
+	Metadata  string `json:"metaData,omitempty"`
+}
+
+// hasDesc item是否需要写入desc PIN
+func (item *DogeMrc721Item) hasDesc() bool {
+	return item.Name != "" || item.Desc != "" || item.Cover != "" || item.Metadata != ""
+}
+
+// validateMrc721CollectionName 集合名称是路径的一段，不能为空，不能包含/和空白字符
+func validateMrc721CollectionName(collectionName string) error {
+	if collectionName == "" || len(collectionName) > 64 {
+		return fmt.Errorf("%w: 集合名称必须是1到64个字节: %q", ErrInvalidOption, collectionName)
+	}
+	if strings.ContainsFunc(collectionName, func(r rune) bool { return r == '/' || r == ':' || unicode.IsSpace(r) }) {
+		return fmt.Errorf("%w: 集合名称不能包含/、:或空白字符: %q", ErrInvalidOption, collectionName)
+	}
+	return nil
+}
+
+// NewDogeMrc721CollectionPin 生成集合定义PIN
+func NewDogeMrc721CollectionPin(collectionName string, collection *DogeMrc721Collection) (*DogeMetaIdPin, error) {
+	if err := validateMrc721CollectionName(collectionName); err != nil {
+		return nil, err
+	}
+	if collection == nil {
+		return nil, fmt.Errorf("%w: 集合定义为空", ErrInvalidOption)
+	}
+	if collection.TotalSupply <= 0 {
+		return nil, fmt.Errorf("%w: totalSupply必须大于0: %d", ErrInvalidOption, collection.TotalSupply)
+	}
+	if collection.RoyaltyRate < 0 || collection.RoyaltyRate > 100 {
+		return nil, fmt.Errorf("%w: royaltyRate必须在0到100之间: %d", ErrInvalidOption, collection.RoyaltyRate)
+	}
+	body, err := json.Marshal(collection)
+	if err != nil {
+		return nil, fmt.Errorf("序列化集合定义失败: %w", err)
+	}
+	return &DogeMetaIdPin{
+		Path:        DogeMrc721PathPrefix + collectionName,
+		ContentType: "application/json",
+		Body:        body,
+	}, nil
+}
+
+// NewDogeMrc721ItemPin 生成集合中一个item的PIN，内容为item.Content
+func NewDogeMrc721ItemPin(collectionName string, item *DogeMrc721Item) (*DogeMetaIdPin, error) {
+	if err := validateMrc721CollectionName(collectionName); err != nil {
+		return nil, err
+	}
+	if item == nil || len(item.Content) == 0 {
+		return nil, fmt.Errorf("%w: item内容为空", ErrInvalidOption)
+	}
+	if item.ContentType == "" {
+		return nil, fmt.Errorf("%w: item缺少内容类型", ErrInvalidOption)
+	}
+	return &DogeMetaIdPin{
+		Path:        DogeMrc721PathPrefix + collectionName + dogeMrc721ItemSuffix,
+		ContentType: item.ContentType,
+		Body:        item.Content,
+	}, nil
+}
+
+// DogeMrc721Mint 批量铸造的参数
+type DogeMrc721Mint struct {
+	CollectionName string
+	Collection     *DogeMrc721Collection // 不为空时先铸造集合定义PIN，为空时集合必须已经存在
+	Items          []*DogeMrc721Item
+	OutputAddress  string            // 所有PIN的接收地址，为空时使用找零地址
+	Extra          *DogeExtraOutputs // 服务费和额外输出，加在第一个PIN的交易链上
+}
+
+// DogeMrc721Batch 批量铸造的结果
+type DogeMrc721Batch struct {
+	CollectionPinId string   // 没有铸造集合定义PIN时为空
+	ItemPinIds      []string // 与Items一一对应
+	DescPinId       string   // 所有item共用一个desc PIN，没有item需要描述时为空
+	Chains          []*DogePinChain
+	// Result 整个批次的费用明细，交易按广播顺序排列
+	Result *DogeInscriptionResult
+	// Batches Result中的交易按内存池交易链限制分组，每组确认后再广播下一组，见DogeBroadcastBatches
+	Batches [][]*wire.MsgTx
+}
+
+// BuildDogeMrc721Batch 铸造MRC-721集合：可选的集合定义PIN、每个item的PIN、以及一个描述所有item的desc PIN
+// 所有PIN共用ins，前一个PIN的找零作为后一个PIN的输入；desc PIN通过引用得到item的PIN id，
+// 所以只需要一次调用，按Batches分批广播
+func BuildDogeMrc721Batch(
+	netParam *chaincfg.Params,
+	mint *DogeMrc721Mint,
+	ins []*TxInputUtxo,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+) (*DogeMrc721Batch, error) {
+	if mint == nil || len(mint.Items) == 0 {
+		return nil, fmt.Errorf("%w: 没有需要铸造的item", ErrInvalidOption)
+	}
+	if mint.Collection != nil && int64(len(mint.Items)) > mint.Collection.TotalSupply {
+		return nil, fmt.Errorf("%w: item数量超过totalSupply: %d > %d", ErrInvalidOption, len(mint.Items), mint.Collection.TotalSupply)
+	}
+
+	details := make([]*DogePinDetail, 0, len(mint.Items)+2)
+	addPin := func(pin *DogeMetaIdPin, refs map[string]int) {
+		detail := &DogePinDetail{Pin: pin, OutputAddress: mint.OutputAddress, Refs: refs}
+		if len(details) == 0 {
+			detail.Extra = mint.Extra
+		}
+		details = append(details, detail)
+	}
+
+	if mint.Collection != nil {
+		pin, err := NewDogeMrc721CollectionPin(mint.CollectionName, mint.Collection)
+		if err != nil {
+			return nil, err
+		}
+		addPin(pin, nil)
+	}
+	firstItem := len(details)
+	descs := make([]*dogeMrc721ItemDesc, 0, len(mint.Items))
+	descRefs := make(map[string]int)
+	for i, item := range mint.Items {
+		pin, err := NewDogeMrc721ItemPin(mint.CollectionName, item)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个item: %w", i, err)
+		}
+		if item.hasDesc() {
+			placeholder := fmt.Sprintf("{{item%d}}", i)
+			descRefs[placeholder] = len(details)
+			descs = append(descs, &dogeMrc721ItemDesc{
+				ItemPinId: DogeInscriptionId{TxId: placeholder}.String(), // 替换后为item的PIN id
+				Name:      item.Name,
+				Desc:      item.Desc,
+				Cover:     item.Cover,
+				Metadata:  item.Metadata,
+			})
+		}
+		addPin(pin, nil)
+	}
+	if len(descs) > 0 {
+		body, err := json.Marshal(map[string]interface{}{"items": descs})
+		if err != nil {
+			return nil, fmt.Errorf("序列化item描述失败: %w", err)
+		}
+		addPin(&DogeMetaIdPin{
+			Path:        DogeMrc721PathPrefix + mint.CollectionName + dogeMrc721DescSuffix,
+			ContentType: "application/json",
+			Body:        body,
+		}, descRefs)
+	}
+
+	chains, err := BuildDogeMetaIdPinBatch(netParam, details, ins, changeAddress, feeRate)
+	if err != nil {
+		return nil, err
+	}
+
+	batch := &DogeMrc721Batch{
+		ItemPinIds: make([]string, 0, len(mint.Items)),
+		Chains:     chains,
+	}
+	results := make([]*DogeInscriptionResult, 0, len(chains))
+	for _, chain := range chains {
+		results = append(results, chain.Result)
+	}
+	batch.Result = MergeDogeInscriptionResults(results)
+	batch.Batches = DogeBroadcastBatches(batch.Result.Txs)
+	if mint.Collection != nil {
+		batch.CollectionPinId = chains[0].PinId
+	}
+	for _, chain := range chains[firstItem : firstItem+len(mint.Items)] {
+		batch.ItemPinIds = append(batch.ItemPinIds, chain.PinId)
+	}
+	if len(descs) > 0 {
+		batch.DescPinId = chains[len(chains)-1].PinId
+	}
+	return batch, nil
+}
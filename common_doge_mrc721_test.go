@@ -0,0 +1,99 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestBuildDogeMrc721Batch(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	_, service := testDogeKey(t, 2)
+	items := []*DogeMrc721Item{
+		{Content: bytes.Repeat([]byte("a"), 3000), ContentType: "image/png", Name: "one"},
+		{Content: []byte("b"), ContentType: "text/plain"},
+		{Content: []byte("c"), ContentType: "text/plain", Desc: "third", Metadata: `{"rarity":"rare"}`},
+	}
+	batch, err := BuildDogeMrc721Batch(DogeMainNetParams, &DogeMrc721Mint{
+		CollectionName: "dogepunks",
+		Collection:     &DogeMrc721Collection{Name: "Doge Punks", TotalSupply: 10, RoyaltyRate: 5, Metadata: "{}"},
+		Items:          items,
+		Extra:          &DogeExtraOutputs{Service: &TxOutput{Address: service, Amount: 300000}},
+	}, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 交易数量没有超过未确认祖先数量限制，可以一次广播
+	if len(batch.Batches) != 1 || len(batch.Batches[0]) != len(batch.Result.Txs) {
+		t.Errorf("分为%d组", len(batch.Batches))
+	}
+	testSubmitAndMine(t, sim, batch.Result.Txs...)
+	// 集合定义、3个item、desc
+	if len(batch.Chains) != 5 || len(batch.ItemPinIds) != 3 || batch.Result.ServiceCost != 300000 ||
+		batch.CollectionPinId != batch.Chains[0].PinId || batch.DescPinId != batch.Chains[4].PinId {
+		t.Fatalf("%+v", batch)
+	}
+
+	parse := func(chain *DogePinChain) *InscriptionData {
+		inscription, err := ParseInscriptionFromChain(testChainHexes(t, chain.Txs), InscriptionFormatMetaID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return inscription
+	}
+	collection := parse(batch.Chains[0])
+	// 字段名与插件的MRC721Collection和MRC721Item一致
+	var collectionBody map[string]interface{}
+	if collection.Path != "/nft/mrc721/dogepunks" || json.Unmarshal(collection.Data, &collectionBody) != nil ||
+		collectionBody["totalSupply"] != 10.0 || collectionBody["metadata"] != "{}" {
+		t.Errorf("集合定义: %s %s", collection.Path, collection.Data)
+	}
+	for i, item := range items {
+		inscription := parse(batch.Chains[i+1])
+		if inscription.Path != "/nft/mrc721/dogepunks/item" || inscription.ContentType != item.ContentType || !bytes.Equal(inscription.Data, item.Content) {
+			t.Errorf("item %d: %s %s", i, inscription.Path, inscription.ContentType)
+		}
+	}
+
+	// 只有第1个和第3个item需要描述，desc中引用的是它们的PIN id
+	desc := parse(batch.Chains[4])
+	var descBody struct {
+		Items []map[string]string `json:"items"`
+	}
+	if desc.Path != "/nft/mrc721/dogepunks/item/desc" || json.Unmarshal(desc.Data, &descBody) != nil {
+		t.Fatalf("desc: %s %s", desc.Path, desc.Data)
+	}
+	if len(descBody.Items) != 2 || descBody.Items[0]["itemPinId"] != batch.ItemPinIds[0] || descBody.Items[0]["name"] != "one" ||
+		descBody.Items[1]["itemPinId"] != batch.ItemPinIds[2] || descBody.Items[1]["desc"] != "third" ||
+		descBody.Items[1]["metaData"] != `{"rarity":"rare"}` {
+		t.Errorf("desc: %s", desc.Data)
+	}
+
+	// 集合已经存在，没有item需要描述时只铸造item
+	batch, err = BuildDogeMrc721Batch(DogeMainNetParams, &DogeMrc721Mint{CollectionName: "dogepunks", Items: items[1:2]}, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Chains) != 1 || batch.CollectionPinId != "" || batch.DescPinId != "" || batch.ItemPinIds[0] != batch.Chains[0].PinId {
+		t.Errorf("只铸造item: %+v", batch)
+	}
+}
+
+func TestBuildDogeMrc721BatchInvalid(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	item := &DogeMrc721Item{Content: []byte("a"), ContentType: "text/plain"}
+	for name, mint := range map[string]*DogeMrc721Mint{
+		"没有item":           {CollectionName: "x"},
+		"集合名称包含/":          {CollectionName: "a/b", Items: []*DogeMrc721Item{item}},
+		"集合名称包含空格":         {CollectionName: "a b", Items: []*DogeMrc721Item{item}},
+		"item数量超过总量":       {CollectionName: "x", Collection: &DogeMrc721Collection{TotalSupply: 1}, Items: []*DogeMrc721Item{item, item}},
+		"royaltyRate超过100": {CollectionName: "x", Collection: &DogeMrc721Collection{TotalSupply: 1, RoyaltyRate: 101}, Items: []*DogeMrc721Item{item}},
+		"item没有内容":         {CollectionName: "x", Items: []*DogeMrc721Item{{ContentType: "text/plain"}}},
+		"item没有内容类型":       {CollectionName: "x", Items: []*DogeMrc721Item{{Content: []byte("a")}}},
+	} {
+		if _, err := BuildDogeMrc721Batch(DogeMainNetParams, mint, utxos, address, 1000); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
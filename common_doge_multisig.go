@@ -0,0 +1,447 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// dogeMultisigMaxKeys P2SH赎回脚本最多520字节，15个压缩公钥的m-of-n脚本为513字节
+const dogeMultisigMaxKeys = 15
+
+// DogeMultisig m-of-n多签钱包，赎回脚本为 OP_m <公钥>... OP_n OP_CHECKMULTISIG
+type DogeMultisig struct {
+	M            int
+	PubKeys      [][]byte // 与赎回脚本中的顺序相同
+	RedeemScript []byte
+}
+
+// NewDogeMultisig 由公钥（十六进制）创建m-of-n多签钱包
+// 公钥的顺序决定赎回脚本和地址，所有签名方必须使用相同的顺序；需要与顺序无关的地址时由调用方先排序
+func NewDogeMultisig(m int, pubKeyHexes []string) (*DogeMultisig, error) {
+	n := len(pubKeyHexes)
+	if n == 0 || n > dogeMultisigMaxKeys {
+		return nil, fmt.Errorf("%w: 公钥数量必须是1到%d个: %d", ErrInvalidOption, dogeMultisigMaxKeys, n)
+	}
+	if m < 1 || m > n {
+		return nil, fmt.Errorf("%w: 签名数量必须是1到%d: %d", ErrInvalidOption, n, m)
+	}
+	pubKeys := make([][]byte, 0, n)
+	addrs := make([]*btcutil.AddressPubKey, 0, n)
+	for i, pubKeyHex := range pubKeyHexes {
+		pubKey, err := hex.DecodeString(pubKeyHex)
+		if err != nil {
+			return nil, fmt.Errorf("解码第%d个公钥失败: %w", i, err)
+		}
+		for _, existing := range pubKeys {
+			if bytes.Equal(existing, pubKey) {
+				return nil, fmt.Errorf("%w: 第%d个公钥重复: %s", ErrInvalidOption, i, pubKeyHex)
+			}
+		}
+		// AddressPubKey只用来生成脚本，与网络参数无关
+		addr, err := btcutil.NewAddressPubKey(pubKey, DogeMainNetParams)
+		if err != nil {
+			return nil, fmt.Errorf("解析第%d个公钥失败: %w", i, err)
+		}
+		pubKeys = append(pubKeys, pubKey)
+		addrs = append(addrs, addr)
+	}
+	redeemScript, err := txscript.MultiSigScript(addrs, m)
+	if err != nil {
+		return nil, fmt.Errorf("构建赎回脚本失败: %w", err)
+	}
+	if len(redeemScript) > txscript.MaxScriptElementSize {
+		return nil, fmt.Errorf("赎回脚本超过%d字节: %d", txscript.MaxScriptElementSize, len(redeemScript))
+	}
+	return &DogeMultisig{M: m, PubKeys: pubKeys, RedeemScript: redeemScript}, nil
+}
+
+// ParseDogeMultisig 解析赎回脚本（十六进制），例如从其他钱包导入的多签钱包
+func ParseDogeMultisig(redeemScriptHex string) (*DogeMultisig, error) {
+	redeemScript, err := hex.DecodeString(redeemScriptHex)
+	if err != nil {
+		return nil, fmt.Errorf("解码赎回脚本失败: %w", err)
+	}
+	m, pubKeys, err := parseMultisigRedeemScript(redeemScript)
+	if err != nil {
+		return nil, err
+	}
+	return &DogeMultisig{M: m, PubKeys: pubKeys, RedeemScript: redeemScript}, nil
+}
+
+// parseMultisigRedeemScript 返回赎回脚本的签名数量和公钥
+func parseMultisigRedeemScript(redeemScript []byte) (int, [][]byte, error) {
+	if txscript.GetScriptClass(redeemScript) != txscript.MultiSigTy {
+		return 0, nil, fmt.Errorf("赎回脚本不是m-of-n多签脚本")
+	}
+	_, m, err := txscript.CalcMultiSigStats(redeemScript)
+	if err != nil {
+		return 0, nil, fmt.Errorf("解析赎回脚本失败: %w", err)
+	}
+	pubKeys, err := txscript.PushedData(redeemScript)
+	if err != nil {
+		return 0, nil, fmt.Errorf("解析赎回脚本失败: %w", err)
+	}
+	return m, pubKeys, nil
+}
+
+// Address P2SH地址，前缀为netParam.ScriptHashAddrID（主网为A或9开头）
+func (w *DogeMultisig) Address(netParam *chaincfg.Params) (string, error) {
+	addr, err := btcutil.NewAddressScriptHash(w.RedeemScript, netParam)
+	if err != nil {
+		return "", fmt.Errorf("生成P2SH地址失败: %w", err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// PkScript P2SH输出脚本 OP_HASH160 <hash160(赎回脚本)> OP_EQUAL
+func (w *DogeMultisig) PkScript() []byte {
+	return p2shPkScript(w.RedeemScript)
+}
+
+// p2shPkScript 赎回脚本对应的P2SH输出脚本
+func p2shPkScript(redeemScript []byte) []byte {
+	pkScript, _ := txscript.NewScriptBuilder().
+		AddOp(txscript.OP_HASH160).
+		AddData(btcutil.Hash160(redeemScript)).
+		AddOp(txscript.OP_EQUAL).
+		Script()
+	return pkScript
+}
+
+// SigScriptSize 签名完成后签名脚本的最大字节数:
+// OP_0 + m个签名（每个最多72字节加1字节push）+ 赎回脚本的push
+func (w *DogeMultisig) SigScriptSize() int {
+	return 1 + w.M*(1+72) + scriptPushSize(len(w.RedeemScript))
+}
+
+// scriptPushSize push n字节数据需要的字节数，包括push操作码
+func scriptPushSize(n int) int {
+	switch {
+	case n < txscript.OP_PUSHDATA1:
+		return 1 + n
+	case n <= 0xff:
+		return 2 + n
+	default:
+		return 3 + n
+	}
+}
+
+// DogeMultisigInput 多签交易中一个输入的签名状态
+type DogeMultisigInput struct {
+	RedeemScript string            `json:"redeemScript"`
+	Amount       int64             `json:"amount"`
+	Signatures   map[string]string `json:"signatures"` // 公钥（十六进制） -> 签名（DER + SigHashAll，十六进制）
+}
+
+// DogeMultisigTx 等待签名的多签交易，可以序列化为JSON在签名方之间传递
+// 每个签名方用Sign添加自己的签名，CombineDogeMultisigTx合并各方的签名，
+// 所有输入都有足够的签名后用Finalize得到可以广播的交易
+type DogeMultisigTx struct {
+	TxHex  string               `json:"txHex"` // 未签名的交易
+	Inputs []*DogeMultisigInput `json:"inputs"`
+}
+
+// BuildDogeMultisigTx 构建花费多签钱包UTXO的交易，ins中的UTXO必须属于wallet
+// 手续费按m个签名的签名脚本估算，找零在outs之后，changeAddress通常是多签地址本身
+func BuildDogeMultisigTx(
+	netParam *chaincfg.Params,
+	wallet *DogeMultisig,
+	ins []*TxInputUtxo,
+	outs []*TxOutput,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+) (*DogeMultisigTx, error) {
+	if wallet == nil {
+		return nil, fmt.Errorf("%w: 多签钱包为空", ErrInvalidOption)
+	}
+	if changeAddress == "" {
+		return nil, fmt.Errorf("%w: 没有设置找零地址", ErrInvalidOption)
+	}
+	pkScriptHex := hex.EncodeToString(wallet.PkScript())
+	for _, utxo := range ins {
+		if utxo.PkScript != pkScriptHex {
+			return nil, fmt.Errorf("%w: UTXO %s:%d不属于多签钱包", ErrInvalidUtxo, utxo.TxId, utxo.TxIndex)
+		}
+	}
+
+	tx := wire.NewMsgTx(2)
+	for _, out := range outs {
+		if out.Amount < DogeDustLimit {
+			return nil, &ErrDustOutput{Address: out.Address, Amount: out.Amount, Limit: DogeDustLimit}
+		}
+		addr, err := decodeDogeAddress(out.Address, netParam)
+		if err != nil {
+			return nil, fmt.Errorf("解码输出地址失败: %w", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("构建输出脚本失败: %w", err)
+		}
+		tx.AddTxOut(wire.NewTxOut(out.Amount, pkScript))
+	}
+
+	// 签名脚本超过252字节时长度前缀从1字节变为3字节
+	sigScriptSize := wallet.SigScriptSize()
+	inputSigSize := sigScriptSize + wire.VarIntSerializeSize(uint64(sigScriptSize)) - 1
+	usedUtxos, _, _, err := fundTransactionWithInputSize(
+		tx,
+		ins,
+		changeAddress,
+		netParam,
+		feeRate,
+		0,
+		0,
+		inputSigSize,
+		dogeLog(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fund多签交易失败: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return nil, fmt.Errorf("序列化多签交易失败: %w", err)
+	}
+	multisigTx := &DogeMultisigTx{
+		TxHex:  hex.EncodeToString(buf.Bytes()),
+		Inputs: make([]*DogeMultisigInput, 0, len(usedUtxos)),
+	}
+	for _, utxo := range usedUtxos {
+		multisigTx.Inputs = append(multisigTx.Inputs, &DogeMultisigInput{
+			RedeemScript: hex.EncodeToString(wallet.RedeemScript),
+			Amount:       int64(utxo.Amount),
+			Signatures:   make(map[string]string),
+		})
+	}
+	return multisigTx, nil
+}
+
+// unsignedTx 解码未签名的交易，并检查输入数量与Inputs一致
+func (t *DogeMultisigTx) unsignedTx() (*wire.MsgTx, error) {
+	raw, err := hex.DecodeString(t.TxHex)
+	if err != nil {
+		return nil, fmt.Errorf("解码多签交易失败: %w", err)
+	}
+	tx := wire.NewMsgTx(2)
+	if err := tx.Deserialize(bytes.NewReader(raw)); err != nil {
+		return nil, fmt.Errorf("解析多签交易失败: %w", err)
+	}
+	if len(tx.TxIn) != len(t.Inputs) {
+		return nil, fmt.Errorf("多签交易输入数量不一致: %d != %d", len(tx.TxIn), len(t.Inputs))
+	}
+	return tx, nil
+}
+
+// verifyMultisigSignature 验证sig是pubKey对tx第inputIndex个输入的SigHashAll签名
+func verifyMultisigSignature(tx *wire.MsgTx, inputIndex int, redeemScript, pubKey, sig []byte) error {
+	if len(sig) == 0 || txscript.SigHashType(sig[len(sig)-1]) != txscript.SigHashAll {
+		return fmt.Errorf("签名类型不是SIGHASH_ALL")
+	}
+	signature, err := ecdsa.ParseDERSignature(sig[:len(sig)-1])
+	if err != nil {
+		return fmt.Errorf("解析签名失败: %w", err)
+	}
+	key, err := btcec.ParsePubKey(pubKey)
+	if err != nil {
+		return fmt.Errorf("解析公钥失败: %w", err)
+	}
+	hash, err := txscript.CalcSignatureHash(redeemScript, txscript.SigHashAll, tx, inputIndex)
+	if err != nil {
+		return fmt.Errorf("计算签名哈希失败: %w", err)
+	}
+	if !signature.Verify(hash, key) {
+		return fmt.Errorf("签名验证失败")
+	}
+	return nil
+}
+
+// Sign 用私钥为所有赎回脚本中包含该公钥的输入签名，返回签名的输入数量
+// 私钥不属于任何输入的多签钱包时返回error
+func (t *DogeMultisigTx) Sign(priHex string) (int, error) {
+	tx, err := t.unsignedTx()
+	if err != nil {
+		return 0, err
+	}
+	privateKeyBytes, err := hex.DecodeString(priHex)
+	if err != nil {
+		return 0, fmt.Errorf("解码私钥失败: %w", err)
+	}
+	privateKey, publicKey := btcec.PrivKeyFromBytes(privateKeyBytes)
+	compressed := publicKey.SerializeCompressed()
+	uncompressed := publicKey.SerializeUncompressed()
+
+	signed := 0
+	for i, input := range t.Inputs {
+		redeemScript, err := hex.DecodeString(input.RedeemScript)
+		if err != nil {
+			return signed, fmt.Errorf("解码第%d个输入的赎回脚本失败: %w", i, err)
+		}
+		_, pubKeys, err := parseMultisigRedeemScript(redeemScript)
+		if err != nil {
+			return signed, fmt.Errorf("第%d个输入: %w", i, err)
+		}
+		for _, pubKey := range pubKeys {
+			if !bytes.Equal(pubKey, compressed) && !bytes.Equal(pubKey, uncompressed) {
+				continue
+			}
+			sig, err := txscript.RawTxInSignature(tx, i, redeemScript, txscript.SigHashAll, privateKey)
+			if err != nil {
+				return signed, fmt.Errorf("第%d个输入签名失败: %w", i, err)
+			}
+			if input.Signatures == nil {
+				input.Signatures = make(map[string]string)
+			}
+			input.Signatures[hex.EncodeToString(pubKey)] = hex.EncodeToString(sig)
+			signed++
+			break
+		}
+	}
+	if signed == 0 {
+		return 0, fmt.Errorf("私钥不属于多签交易的任何输入")
+	}
+	return signed, nil
+}
+
+// CombineDogeMultisigTx 合并各签名方返回的多签交易，所有交易必须是同一个未签名交易
+// 每个签名都会重新验证，无效的签名返回error而不是被合并
+func CombineDogeMultisigTx(txs ...*DogeMultisigTx) (*DogeMultisigTx, error) {
+	if len(txs) == 0 {
+		return nil, fmt.Errorf("没有需要合并的多签交易")
+	}
+	base := txs[0]
+	tx, err := base.unsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	combined := &DogeMultisigTx{
+		TxHex:  base.TxHex,
+		Inputs: make([]*DogeMultisigInput, len(base.Inputs)),
+	}
+	for i, input := range base.Inputs {
+		combined.Inputs[i] = &DogeMultisigInput{
+			RedeemScript: input.RedeemScript,
+			Amount:       input.Amount,
+			Signatures:   make(map[string]string),
+		}
+	}
+
+	for n, other := range txs {
+		if other.TxHex != base.TxHex || len(other.Inputs) != len(base.Inputs) {
+			return nil, fmt.Errorf("第%d个多签交易与第0个不是同一个交易", n)
+		}
+		for i, input := range other.Inputs {
+			if input.RedeemScript != base.Inputs[i].RedeemScript || input.Amount != base.Inputs[i].Amount {
+				return nil, fmt.Errorf("第%d个多签交易的第%d个输入与第0个不一致", n, i)
+			}
+			redeemScript, err := hex.DecodeString(input.RedeemScript)
+			if err != nil {
+				return nil, fmt.Errorf("解码第%d个输入的赎回脚本失败: %w", i, err)
+			}
+			for pubKeyHex, sigHex := range input.Signatures {
+				pubKey, err := hex.DecodeString(pubKeyHex)
+				if err != nil {
+					return nil, fmt.Errorf("解码公钥失败: %w", err)
+				}
+				sig, err := hex.DecodeString(sigHex)
+				if err != nil {
+					return nil, fmt.Errorf("解码签名失败: %w", err)
+				}
+				if err := verifyMultisigSignature(tx, i, redeemScript, pubKey, sig); err != nil {
+					return nil, fmt.Errorf("第%d个多签交易第%d个输入公钥%s的签名无效: %w", n, i, pubKeyHex, err)
+				}
+				combined.Inputs[i].Signatures[pubKeyHex] = sigHex
+			}
+		}
+	}
+	return combined, nil
+}
+
+// Complete 所有输入是否都有足够的签名
+func (t *DogeMultisigTx) Complete() bool {
+	for _, input := range t.Inputs {
+		redeemScript, err := hex.DecodeString(input.RedeemScript)
+		if err != nil {
+			return false
+		}
+		m, pubKeys, err := parseMultisigRedeemScript(redeemScript)
+		if err != nil {
+			return false
+		}
+		count := 0
+		for _, pubKey := range pubKeys {
+			if _, ok := input.Signatures[hex.EncodeToString(pubKey)]; ok {
+				count++
+			}
+		}
+		if count < m {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize 组装签名脚本 OP_0 <签名>... <赎回脚本> 并验证，返回可以广播的交易
+// 签名按公钥在赎回脚本中的顺序排列（OP_CHECKMULTISIG要求），超过m个时只使用前m个
+func (t *DogeMultisigTx) Finalize() (*wire.MsgTx, error) {
+	tx, err := t.unsignedTx()
+	if err != nil {
+		return nil, err
+	}
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, input := range t.Inputs {
+		redeemScript, err := hex.DecodeString(input.RedeemScript)
+		if err != nil {
+			return nil, fmt.Errorf("解码第%d个输入的赎回脚本失败: %w", i, err)
+		}
+		m, pubKeys, err := parseMultisigRedeemScript(redeemScript)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个输入: %w", i, err)
+		}
+		builder := txscript.NewScriptBuilder().AddOp(txscript.OP_0)
+		count := 0
+		for _, pubKey := range pubKeys {
+			sigHex, ok := input.Signatures[hex.EncodeToString(pubKey)]
+			if !ok {
+				continue
+			}
+			sig, err := hex.DecodeString(sigHex)
+			if err != nil {
+				return nil, fmt.Errorf("解码第%d个输入的签名失败: %w", i, err)
+			}
+			builder.AddData(sig)
+			if count++; count == m {
+				break
+			}
+		}
+		if count < m {
+			return nil, fmt.Errorf("第%d个输入签名不足: %d < %d", i, count, m)
+		}
+		sigScript, err := builder.AddData(redeemScript).Script()
+		if err != nil {
+			return nil, fmt.Errorf("构建第%d个输入的签名脚本失败: %w", i, err)
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+		prevOutFetcher.AddPrevOut(tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(input.Amount, p2shPkScript(redeemScript)))
+	}
+
+	for i, input := range t.Inputs {
+		prevOut := prevOutFetcher.FetchPrevOutput(tx.TxIn[i].PreviousOutPoint)
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i, DogeStandardVerifyFlags,
+			nil, nil, input.Amount, prevOutFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("创建脚本引擎失败: %w", err)
+		}
+		if err := vm.Execute(); err != nil {
+			return nil, fmt.Errorf("第%d个输入签名验证失败: %w", i, err)
+		}
+	}
+	return tx, nil
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// testMultisigClone 模拟签名方之间通过JSON传递多签交易
+func testMultisigClone(t *testing.T, tx *DogeMultisigTx) *DogeMultisigTx {
+	t.Helper()
+	raw, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var clone DogeMultisigTx
+	if err := json.Unmarshal(raw, &clone); err != nil {
+		t.Fatal(err)
+	}
+	return &clone
+}
+
+func TestDogeMultisig(t *testing.T) {
+	var priHexes, pubKeyHexes []string
+	for i := byte(1); i <= 3; i++ {
+		key := testPrivateKey(i)
+		priHexes = append(priHexes, hex.EncodeToString(key.Serialize()))
+		pubKeyHexes = append(pubKeyHexes, hex.EncodeToString(key.PubKey().SerializeCompressed()))
+	}
+	wallet, err := NewDogeMultisig(2, pubKeyHexes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address, err := wallet.Address(DogeMainNetParams)
+	if err != nil || address[0] != 'A' && address[0] != '9' {
+		t.Fatalf("P2SH地址: %s %v", address, err)
+	}
+	parsed, err := ParseDogeMultisig(hex.EncodeToString(wallet.RedeemScript))
+	if err != nil || parsed.M != 2 || len(parsed.PubKeys) != 3 {
+		t.Fatalf("解析赎回脚本: %v %+v", err, parsed)
+	}
+
+	sim := NewDogeSimulator(DogeMainNetParams)
+	for _, amount := range []int64{5e8, 7e8} {
+		if _, err := sim.Fund(address, amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	utxos, err := sim.ListUnspent(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, dest := testDogeKey(t, 4)
+	// 普通交易不能花费P2SH UTXO
+	if _, err := BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: dest, Amount: 1e8}}, address, 1000, true); !errors.Is(err, ErrInvalidUtxo) {
+		t.Errorf("普通交易花费P2SH: %v", err)
+	}
+	multisigTx, err := BuildDogeMultisigTx(DogeMainNetParams, wallet, utxos, []*TxOutput{{Address: dest, Amount: 10e8}}, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(multisigTx.Inputs) != 2 {
+		t.Fatalf("多签交易%d个输入", len(multisigTx.Inputs))
+	}
+
+	// 两个签名方分别签名后合并
+	first, third := testMultisigClone(t, multisigTx), testMultisigClone(t, multisigTx)
+	if signed, err := first.Sign(priHexes[0]); err != nil || signed != 2 {
+		t.Fatalf("签名%d个输入: %v", signed, err)
+	}
+	if _, err := third.Sign(priHexes[2]); err != nil {
+		t.Fatal(err)
+	}
+	if first.Complete() {
+		t.Error("只有1个签名时已经完成")
+	}
+	if _, err := first.Finalize(); err == nil {
+		t.Error("签名不足时可以完成")
+	}
+	// 第0个输入使用第1个输入的签名
+	swapped := testMultisigClone(t, third)
+	for pubKey := range swapped.Inputs[0].Signatures {
+		swapped.Inputs[0].Signatures[pubKey] = swapped.Inputs[1].Signatures[pubKey]
+	}
+	if _, err := CombineDogeMultisigTx(first, swapped); err == nil {
+		t.Error("合并了无效的签名")
+	}
+	combined, err := CombineDogeMultisigTx(first, third)
+	if err != nil || !combined.Complete() {
+		t.Fatalf("合并签名: %v", err)
+	}
+	tx, err := combined.Finalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 手续费按m个签名估算
+	fee := int64(12e8)
+	for _, out := range tx.TxOut {
+		fee -= out.Value
+	}
+	if fee < int64(tx.SerializeSize())*1000 || fee > int64(tx.SerializeSize()+20)*1000 {
+		t.Errorf("手续费%d, 交易%d字节", fee, tx.SerializeSize())
+	}
+	testSubmitAndMine(t, sim, tx)
+
+	_, p2pkh := testDogeKey(t, 1)
+	p2pkhUtxo, err := sim.Fund(p2pkh, 1e8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, err := range map[string]error{
+		"签名数量超过公钥数量": func() error { _, err := NewDogeMultisig(4, pubKeyHexes); return err }(),
+		"签名数量为0":     func() error { _, err := NewDogeMultisig(0, pubKeyHexes); return err }(),
+		"没有公钥":       func() error { _, err := NewDogeMultisig(1, nil); return err }(),
+		"公钥重复":       func() error { _, err := NewDogeMultisig(1, []string{pubKeyHexes[0], pubKeyHexes[0]}); return err }(),
+		"多签钱包为空": func() error {
+			_, err := BuildDogeMultisigTx(DogeMainNetParams, nil, utxos, []*TxOutput{{Address: dest, Amount: 1e8}}, address, 1000)
+			return err
+		}(),
+		"没有找零地址": func() error {
+			_, err := BuildDogeMultisigTx(DogeMainNetParams, wallet, utxos, []*TxOutput{{Address: dest, Amount: 1e8}}, "", 1000)
+			return err
+		}(),
+	} {
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	_, err = BuildDogeMultisigTx(DogeMainNetParams, wallet, []*TxInputUtxo{p2pkhUtxo}, []*TxOutput{{Address: dest, Amount: 1e7}}, address, 1000)
+	if !errors.Is(err, ErrInvalidUtxo) {
+		t.Errorf("UTXO不属于多签钱包: %v", err)
+	}
+}
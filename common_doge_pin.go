@@ -0,0 +1,274 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DogeMetaIdPin MetaID PIN的内容，对应createPin的MetaidData
+// 字段为空时使用BuildDogeMetaIdInscription一直以来的默认值
+type DogeMetaIdPin struct {
+	Operation   string // 操作类型，默认create；init只写入metaid和init
+	Path        string // 路径，如/protocols/simplebuzz
+	Encryption  string // 加密标志，默认"0"；Body需要已经是加密后的内容
+	Version     string // 版本，默认0.0.1
+	ContentType string // 内容类型，默认application/json
+	Body        []byte // 上链的内容
+}
+
+// BuildDogeMetaIdPinInscription 构建MetaID PIN的inscription脚本
+// 字段顺序: metaid, operation, path, encryption, version, contentType, payload
+func BuildDogeMetaIdPinInscription(pin *DogeMetaIdPin) ([]byte, error) {
+	return buildDogeMetaIdPinInscription(pin, int(MAX_CHUNK_LEN))
+}
+
+// buildDogeMetaIdPinInscription 按chunkLen把payload分块构建MetaID PIN的inscription脚本
+func buildDogeMetaIdPinInscription(pin *DogeMetaIdPin, chunkLen int) ([]byte, error) {
+	operation := pin.Operation
+	if operation == "" {
+		operation = "create"
+	}
+	encryption := pin.Encryption
+	if encryption == "" {
+		encryption = PinEncryptionNone
+	}
+	version := pin.Version
+	if version == "" {
+		version = "0.0.1"
+	}
+	contentType := pin.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	inscriptionBuilder := txscript.NewScriptBuilder()
+	inscriptionBuilder.
+		AddData([]byte("metaid")). //<metaid_flag>
+		AddData([]byte(operation)) //<operation>
+	if operation != "init" {
+		inscriptionBuilder.AddData([]byte(pin.Path))    //<path>
+		inscriptionBuilder.AddData([]byte(encryption))  //<Encryption>
+		inscriptionBuilder.AddData([]byte(version))     //<version>
+		inscriptionBuilder.AddData([]byte(contentType)) //<content-type>
+	}
+	inscriptionScript, err := inscriptionBuilder.Script()
+	if err != nil {
+		return nil, err
+	}
+	if operation == "init" {
+		return inscriptionScript, nil
+	}
+
+	// payload按chunkLen分块，每块单独编码后拼接，不受ScriptBuilder的10000字节限制
+	for i := 0; i < len(pin.Body); i += chunkLen {
+		end := i + chunkLen
+		if end > len(pin.Body) {
+			end = len(pin.Body)
+		}
+		partScript, err := txscript.NewScriptBuilder().AddData(pin.Body[i:end]).Script() //<payload>
+		if err != nil {
+			return nil, err
+		}
+		inscriptionScript = append(inscriptionScript, partScript...)
+	}
+	return inscriptionScript, nil
+}
+
+// DogePinDetail 批量创建中的一个PIN，对应createPin的PinDetail
+type DogePinDetail struct {
+	Pin           *DogeMetaIdPin
+	OutputAddress string // reveal交易的接收地址（revealAddr），为空时使用找零地址
+	OutputValue   int64  // reveal输出金额，为0时为100000
+	// Refs 引用替换规则: Body中的占位符（如{{img}}）替换为之前第N个PIN的reveal txid，
+	// 所以'metafile://{{img}}i0'会变成该PIN的PIN id
+	Refs map[string]int
+	// Extra 服务费和额外输出，对应PinOptions的service和outputs
+	Extra *DogeExtraOutputs
+}
+
+// DogePinChain 一个PIN的交易链
+type DogePinChain struct {
+	Txs        []*wire.MsgTx // 按广播顺序排列，最后一笔是reveal交易
+	RevealTxId string
+	PinId      string                 // reveal交易第0个输出的PIN id，见DogeInscriptionId
+	Result     *DogeInscriptionResult // 费用明细，多个PIN可以用MergeDogeInscriptionResults合并
+}
+
+// BuildDogeMetaIdPinBatch 按顺序为多个PIN构建交易链
+// 前一个PIN的找零作为后一个PIN的输入，Refs中的占位符在构建前替换为之前PIN的reveal txid，
+// 例如先上传图片再发布引用该图片的buzz，只需要一次调用；
+// 所有交易链是一条未确认的交易链，超过节点的祖先数量限制时不能一次广播，用DogeBroadcastBatches分组
+func BuildDogeMetaIdPinBatch(
+	netParam *chaincfg.Params,
+	pins []*DogePinDetail,
+	ins []*TxInputUtxo,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+) ([]*DogePinChain, error) {
+	chains := make([]*DogePinChain, 0, len(pins))
+	revealTxIds := make([]string, 0, len(pins))
+	availableUtxos := ins
+
+	for i, detail := range pins {
+		if detail == nil || detail.Pin == nil {
+			return nil, fmt.Errorf("%w: 第%d个PIN为空", ErrInvalidOption, i)
+		}
+		pin := *detail.Pin
+		body, err := replacePinRefs(pin.Body, detail.Refs, revealTxIds)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个PIN: %w", i, err)
+		}
+		pin.Body = body
+
+		inscriptionScript, err := BuildDogeMetaIdPinInscription(&pin)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个PIN构建inscription脚本失败: %w", i, err)
+		}
+
+		outputAddress := detail.OutputAddress
+		if outputAddress == "" {
+			outputAddress = changeAddress
+		}
+		if outputAddress == "" {
+			return nil, fmt.Errorf("%w: 第%d个PIN缺少reveal接收地址", ErrInvalidOption, i)
+		}
+		privateKey, err := btcec.NewPrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("生成私钥失败: %w", err)
+		}
+		cfg := newDogeChainConfig(netParam, outputAddress, detail.OutputValue, changeAddress, feeRate, detail.Extra)
+		result, remainingUtxos, err := buildDogeInscriptionChain(context.Background(), cfg, privateKey, inscriptionScript, availableUtxos)
+		if err != nil {
+			return nil, fmt.Errorf("第%d个PIN构建交易链失败: %w", i, err)
+		}
+		availableUtxos = remainingUtxos
+
+		revealTxId := result.Details[len(result.Details)-1].TxId
+		revealTxIds = append(revealTxIds, revealTxId)
+		chains = append(chains, &DogePinChain{
+			Txs:        result.Txs,
+			RevealTxId: revealTxId,
+			PinId:      DogeInscriptionId{TxId: revealTxId}.String(),
+			Result:     result,
+		})
+	}
+	return chains, nil
+}
+
+// DogeBroadcastBatches 把按广播顺序排列的交易分组，每组不超过DefaultDogePolicy的内存池交易链限制
+// （未确认祖先25笔、101kB），ins都已确认时第一组可以直接广播，之后每组要等前一组确认后再广播；
+// 交易链在超出限制的位置拆开，同一个PIN的交易链也可能分在两组
+func DogeBroadcastBatches(txs []*wire.MsgTx) [][]*wire.MsgTx {
+	var batches [][]*wire.MsgTx
+	var batch []*wire.MsgTx
+	pending := newDogeMempoolChain(DefaultDogePolicy)
+	for _, tx := range txs {
+		ancestors, err := pending.check(tx)
+		if err != nil && len(batch) > 0 {
+			// 前一组确认后tx没有未确认的祖先
+			batches = append(batches, batch)
+			batch = nil
+			pending = newDogeMempoolChain(DefaultDogePolicy)
+			ancestors, _ = pending.check(tx)
+		}
+		pending.add(tx, ancestors)
+		batch = append(batch, tx)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// replacePinRefs 把body中的占位符替换为之前PIN的reveal txid，只能引用已经构建的PIN
+// 所有占位符一次替换，较长的占位符优先，替换进去的txid不会再被替换
+func replacePinRefs(body []byte, refs map[string]int, revealTxIds []string) ([]byte, error) {
+	if len(refs) == 0 {
+		return body, nil
+	}
+	// 按长度和内容排序，{{img}}_thumb先于{{img}}匹配，结果与map遍历顺序无关
+	placeholders := make([]string, 0, len(refs))
+	for placeholder := range refs {
+		placeholders = append(placeholders, placeholder)
+	}
+	sort.Slice(placeholders, func(i, j int) bool {
+		if len(placeholders[i]) != len(placeholders[j]) {
+			return len(placeholders[i]) > len(placeholders[j])
+		}
+		return placeholders[i] < placeholders[j]
+	})
+
+	oldnew := make([]string, 0, 2*len(placeholders))
+	for _, placeholder := range placeholders {
+		index := refs[placeholder]
+		if placeholder == "" {
+			return nil, fmt.Errorf("%w: 引用的占位符为空", ErrInvalidOption)
+		}
+		if index < 0 || index >= len(revealTxIds) {
+			return nil, fmt.Errorf("%w: 引用%s指向的第%d个PIN不在当前PIN之前", ErrInvalidOption, placeholder, index)
+		}
+		oldnew = append(oldnew, placeholder, revealTxIds[index])
+	}
+	return []byte(strings.NewReplacer(oldnew...).Replace(string(body))), nil
+}
+
+// DogeExtraOutputsPosition 额外输出所在的交易
+type DogeExtraOutputsPosition int
+
+const (
+	// DogeExtraOutputsOnReveal 加在最后一笔reveal交易上
+	DogeExtraOutputsOnReveal DogeExtraOutputsPosition = iota
+	// DogeExtraOutputsOnCommit 加在第一笔（commit）交易上
+	DogeExtraOutputsOnCommit
+)
+
+// DogeExtraOutputs 附加到inscription交易链上的输出，对应createPin PinOptions的service和outputs
+// 金额计入所在交易的手续费计算，由钱包UTXO支付
+type DogeExtraOutputs struct {
+	Service  *TxOutput   // 服务费输出
+	Outputs  []*TxOutput // 其他额外输出（转账到其他地址）
+	Position DogeExtraOutputsPosition
+}
+
+// txOuts 校验额外输出并转换为交易输出，服务费在前
+// 每个输出的金额都不能低于Dogecoin的dust限制，否则交易不会被节点转发
+func (e *DogeExtraOutputs) txOuts(netParam *chaincfg.Params) ([]*wire.TxOut, error) {
+	if e == nil {
+		return nil, nil
+	}
+	outputs := make([]*TxOutput, 0, len(e.Outputs)+1)
+	if e.Service != nil {
+		outputs = append(outputs, e.Service)
+	}
+	outputs = append(outputs, e.Outputs...)
+
+	txOuts := make([]*wire.TxOut, 0, len(outputs))
+	for i, output := range outputs {
+		if output == nil {
+			return nil, fmt.Errorf("%w: 第%d个额外输出为空", ErrInvalidOption, i)
+		}
+		if output.Amount < DogeDustLimit {
+			return nil, &ErrDustOutput{Address: output.Address, Amount: output.Amount, Limit: DogeDustLimit}
+		}
+		if output.Amount > dogeMaxMoney {
+			return nil, fmt.Errorf("%w: 额外输出%s的金额超出范围: %d", ErrInvalidOption, output.Address, output.Amount)
+		}
+		addr, err := decodeDogeAddress(output.Address, netParam)
+		if err != nil {
+			return nil, fmt.Errorf("解码额外输出地址失败: %w", err)
+		}
+		pkScript, err := txscript.PayToAddrScript(addr)
+		if err != nil {
+			return nil, fmt.Errorf("构建额外输出%s的脚本失败: %w", output.Address, err)
+		}
+		txOuts = append(txOuts, wire.NewTxOut(output.Amount, pkScript))
+	}
+	return txOuts, nil
+}
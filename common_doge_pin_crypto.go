@@ -0,0 +1,316 @@
+package common
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// MetaID PIN的加密标志，见docs/createPin-API.md
+const (
+	PinEncryptionNone  = "0" // 不加密
+	PinEncryptionEcies = "1" // ECIES加密
+	PinEncryptionEcdh  = "2" // ECDH协商密钥加密
+)
+
+const (
+	eciesIvLen  = 16 // AES-CBC的IV长度
+	eciesTagLen = 32 // HMAC-SHA256校验值长度
+
+	ecdhPubKeyLen = 65 // P-256未压缩公钥长度
+	ecdhNonceLen  = 12 // AES-GCM的nonce长度
+)
+
+// ECIES加密
+// 与插件的eciesEncrypt/eciesDecrypt（src/lib/crypto.ts，meta-contract的mvc.ECIES）字节兼容:
+//   S = 发送方私钥 * 接收方公钥，kE||kM = SHA512(S.x)
+//   iv = HMAC-SHA256(发送方私钥, 明文)[:16]
+//   密文 = 发送方压缩公钥(33) + iv(16) + AES-256-CBC(kE, iv, 明文, PKCS7) + HMAC-SHA256(kM, iv+AES密文)(32)
+// 插件加密给自己时发送方和接收方是同一个密钥，解密时发送方公钥从密文中读取
+
+// EciesEncrypt 使用临时密钥作为发送方，把消息加密给recipient
+func EciesEncrypt(message []byte, recipient *btcec.PublicKey) ([]byte, error) {
+	sender, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("生成临时私钥失败: %w", err)
+	}
+	return EciesEncryptWithKey(message, sender, recipient)
+}
+
+// EciesEncryptWithKey 使用指定的发送方私钥把消息加密给recipient
+// IV由发送方私钥和明文确定，相同的输入总是得到相同的密文
+func EciesEncryptWithKey(message []byte, sender *btcec.PrivateKey, recipient *btcec.PublicKey) ([]byte, error) {
+	if sender == nil || recipient == nil {
+		return nil, fmt.Errorf("缺少ECIES密钥")
+	}
+	kE, kM := eciesKeys(sender, recipient)
+
+	ivMac := hmac.New(sha256.New, sender.Serialize())
+	ivMac.Write(message)
+	iv := ivMac.Sum(nil)[:eciesIvLen]
+
+	block, err := aes.NewCipher(kE)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES失败: %w", err)
+	}
+	padded := pkcs7Pad(message, aes.BlockSize)
+	c := make([]byte, eciesIvLen+len(padded))
+	copy(c, iv)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(c[eciesIvLen:], padded)
+
+	tagMac := hmac.New(sha256.New, kM)
+	tagMac.Write(c)
+
+	encrypted := make([]byte, 0, 33+len(c)+eciesTagLen)
+	encrypted = append(encrypted, sender.PubKey().SerializeCompressed()...)
+	encrypted = append(encrypted, c...)
+	encrypted = append(encrypted, tagMac.Sum(nil)...)
+	return encrypted, nil
+}
+
+// EciesDecrypt 使用接收方私钥解密ECIES密文
+// 密文不是加密给该私钥的（或被篡改）时返回包装了ErrPinKeyMismatch的错误
+func EciesDecrypt(encrypted []byte, privateKey *btcec.PrivateKey) ([]byte, error) {
+	if privateKey == nil {
+		return nil, fmt.Errorf("缺少ECIES私钥")
+	}
+	if len(encrypted) == 0 {
+		return nil, fmt.Errorf("ECIES密文为空")
+	}
+
+	var pubKeyLen int
+	switch encrypted[0] {
+	case 0x02, 0x03:
+		pubKeyLen = 33 // 压缩公钥
+	case 0x04:
+		pubKeyLen = 65 // 未压缩公钥
+	default:
+		return nil, fmt.Errorf("ECIES密文的公钥类型错误: 0x%x", encrypted[0])
+	}
+	if len(encrypted) < pubKeyLen+eciesIvLen+aes.BlockSize+eciesTagLen {
+		return nil, fmt.Errorf("ECIES密文长度不足: %d", len(encrypted))
+	}
+	sender, err := btcec.ParsePubKey(encrypted[:pubKeyLen])
+	if err != nil {
+		return nil, fmt.Errorf("解析ECIES发送方公钥失败: %w", err)
+	}
+	c := encrypted[pubKeyLen : len(encrypted)-eciesTagLen]
+	tag := encrypted[len(encrypted)-eciesTagLen:]
+
+	kE, kM := eciesKeys(privateKey, sender)
+	tagMac := hmac.New(sha256.New, kM)
+	tagMac.Write(c)
+	if !hmac.Equal(tag, tagMac.Sum(nil)) {
+		return nil, fmt.Errorf("%w: ECIES校验失败", ErrPinKeyMismatch)
+	}
+
+	if (len(c)-eciesIvLen)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("ECIES密文长度不是AES块大小的整数倍")
+	}
+	block, err := aes.NewCipher(kE)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES失败: %w", err)
+	}
+	plain := make([]byte, len(c)-eciesIvLen)
+	cipher.NewCBCDecrypter(block, c[:eciesIvLen]).CryptBlocks(plain, c[eciesIvLen:])
+	return pkcs7Unpad(plain, aes.BlockSize)
+}
+
+// decodeEciesPayload 取出PIN body中的ECIES密文
+// 插件的eciesEncrypt返回十六进制字符串，body中可能是原始密文，也可能是其十六进制文本；
+// 原始密文以公钥类型字节（0x02/0x03/0x04）开头，不会被误认为十六进制文本
+func decodeEciesPayload(payload []byte) []byte {
+	if decoded, err := hex.DecodeString(string(payload)); err == nil && len(decoded) > 0 {
+		return decoded
+	}
+	return payload
+}
+
+// eciesKeys 计算共享点并派生AES密钥kE和HMAC密钥kM
+func eciesKeys(privateKey *btcec.PrivateKey, publicKey *btcec.PublicKey) ([]byte, []byte) {
+	shared := btcec.GenerateSharedSecret(privateKey, publicKey) // 共享点的x坐标，32字节
+	kEkM := sha512.Sum512(shared)
+	return kEkM[:32], kEkM[32:]
+}
+
+// pkcs7Pad PKCS7填充
+func pkcs7Pad(data []byte, blockSize int) []byte {
+	padLen := blockSize - len(data)%blockSize
+	return append(append(make([]byte, 0, len(data)+padLen), data...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+}
+
+// pkcs7Unpad 去掉PKCS7填充
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	if len(data) == 0 || len(data)%blockSize != 0 {
+		return nil, fmt.Errorf("填充数据长度错误")
+	}
+	padLen := int(data[len(data)-1])
+	if padLen == 0 || padLen > blockSize {
+		return nil, fmt.Errorf("填充格式错误")
+	}
+	for _, b := range data[len(data)-padLen:] {
+		if int(b) != padLen {
+			return nil, fmt.Errorf("填充格式错误")
+		}
+	}
+	return data[:len(data)-padLen], nil
+}
+
+// ECDH协商密钥加密
+// 与插件的ECDH接口（src/lib/actions/common/ecdh.ts）一致: 私钥按P-256（prime256v1）曲线使用，
+// sharedSecret = SHA256(ECDH共享点的x坐标)，双方公钥为P-256未压缩公钥（插件返回的ecdhPubKey）。
+// PIN body结构:
+//   发送方公钥(65) + 接收方公钥(65) + nonce(12) + AES-256-GCM(sharedSecret, nonce, 明文, aad=双方公钥)
+// 接收方用自己的私钥和发送方公钥即可算出sharedSecret；发送方同样可以解密自己发出的PIN
+
+// EcdhPublicKey 计算私钥对应的P-256公钥，即插件ECDH接口返回的ecdhPubKey
+// 插件使用m/100'/0'/0'/0/0路径的私钥，需要互通时应传入同一路径的私钥
+func EcdhPublicKey(privateKey *btcec.PrivateKey) ([]byte, error) {
+	key, err := ecdhPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey().Bytes(), nil
+}
+
+// EcdhSharedSecret 计算与对方P-256公钥的sharedSecret，与插件ECDH接口的sharedSecret相同
+func EcdhSharedSecret(privateKey *btcec.PrivateKey, externalPubKey []byte) ([]byte, error) {
+	key, err := ecdhPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := ecdh.P256().NewPublicKey(externalPubKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析ECDH公钥失败: %w", err)
+	}
+	shared, err := key.ECDH(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("计算ECDH共享密钥失败: %w", err)
+	}
+	sharedSecret := sha256.Sum256(shared)
+	return sharedSecret[:], nil
+}
+
+// EcdhEncrypt 用发送方私钥和接收方P-256公钥协商密钥，加密消息
+func EcdhEncrypt(message []byte, sender *btcec.PrivateKey, recipientPubKey []byte) ([]byte, error) {
+	nonce := make([]byte, ecdhNonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成nonce失败: %w", err)
+	}
+	return ecdhEncryptWithNonce(message, sender, recipientPubKey, nonce)
+}
+
+// ecdhEncryptWithNonce 使用指定的nonce加密，nonce不能重复使用
+func ecdhEncryptWithNonce(message []byte, sender *btcec.PrivateKey, recipientPubKey []byte, nonce []byte) ([]byte, error) {
+	senderPubKey, err := EcdhPublicKey(sender)
+	if err != nil {
+		return nil, err
+	}
+	sharedSecret, err := EcdhSharedSecret(sender, recipientPubKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newEcdhAead(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append(append([]byte{}, senderPubKey...), recipientPubKey...)
+	encrypted := append(append([]byte{}, header...), nonce...)
+	return aead.Seal(encrypted, nonce, message, header), nil
+}
+
+// EcdhDecrypt 解密EcdhEncrypt的结果，privateKey可以是接收方或发送方的私钥
+// privateKey既不是接收方也不是发送方（或密文被篡改）时返回包装了ErrPinKeyMismatch的错误
+func EcdhDecrypt(encrypted []byte, privateKey *btcec.PrivateKey) ([]byte, error) {
+	ownPubKey, err := EcdhPublicKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(encrypted) < 2*ecdhPubKeyLen {
+		return nil, fmt.Errorf("ECDH密文长度不足: %d", len(encrypted))
+	}
+	header := encrypted[:2*ecdhPubKeyLen]
+	senderPubKey, recipientPubKey := header[:ecdhPubKeyLen], header[ecdhPubKeyLen:]
+
+	var externalPubKey []byte
+	switch {
+	case bytes.Equal(ownPubKey, recipientPubKey):
+		externalPubKey = senderPubKey
+	case bytes.Equal(ownPubKey, senderPubKey):
+		externalPubKey = recipientPubKey
+	default:
+		return nil, fmt.Errorf("%w: PIN不是发给该密钥的", ErrPinKeyMismatch)
+	}
+	sharedSecret, err := EcdhSharedSecret(privateKey, externalPubKey)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newEcdhAead(sharedSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := encrypted[2*ecdhPubKeyLen:]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return nil, fmt.Errorf("ECDH密文长度不足: %d", len(encrypted))
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: ECDH解密失败: %v", ErrPinKeyMismatch, err)
+	}
+	return plain, nil
+}
+
+// ecdhPrivateKey 把secp256k1私钥按P-256私钥使用，与插件的crypto.createECDH('prime256v1')一致
+func ecdhPrivateKey(privateKey *btcec.PrivateKey) (*ecdh.PrivateKey, error) {
+	if privateKey == nil {
+		return nil, fmt.Errorf("缺少ECDH私钥")
+	}
+	key, err := ecdh.P256().NewPrivateKey(privateKey.Serialize())
+	if err != nil {
+		return nil, fmt.Errorf("私钥不能用于P-256 ECDH: %w", err)
+	}
+	return key, nil
+}
+
+// newEcdhAead 用sharedSecret创建AES-256-GCM
+func newEcdhAead(sharedSecret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(sharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("创建AES失败: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("创建GCM失败: %w", err)
+	}
+	return aead, nil
+}
+
+// InscriptionDecrypter 解密MetaID PIN的body，encryption为PIN的加密标志，payload为链上的body
+// 返回nil明文和nil错误表示不处理该PIN，Data保留原样；
+// 返回包装了ErrPinKeyMismatch的错误表示PIN不是发给该密钥的，同样保留原样，不作为解析错误
+type InscriptionDecrypter func(encryption string, payload []byte) ([]byte, error)
+
+// NewKeyDecrypter 使用私钥解密ECIES（"1"）和ECDH（"2"）加密的PIN
+func NewKeyDecrypter(privateKey *btcec.PrivateKey) InscriptionDecrypter {
+	return func(encryption string, payload []byte) ([]byte, error) {
+		switch encryption {
+		case PinEncryptionEcies:
+			return EciesDecrypt(decodeEciesPayload(payload), privateKey)
+		case PinEncryptionEcdh:
+			return EcdhDecrypt(payload, privateKey)
+		default:
+			return nil, nil
+		}
+	}
+}
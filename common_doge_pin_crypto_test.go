@@ -0,0 +1,262 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// testPrivateKey 每个字节都是b的私钥
+func testPrivateKey(b byte) *btcec.PrivateKey {
+	privateKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{b}, 32))
+	return privateKey
+}
+
+// testEncryptedPinTx 把加密后的inscription脚本构建成交易链并提交到模拟账本，返回reveal交易
+func testEncryptedPinTx(t *testing.T, inscriptionScript []byte) string {
+	t.Helper()
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 10e8)
+	txs, err := BuildDogeInscriptionScriptTxs(DogeMainNetParams, inscriptionScript, utxos, address, 0, address, 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, txs...)
+	return testTxHex(t, txs[len(txs)-1])
+}
+
+// ECIES的已知结果由独立实现（Node.js crypto）按同样的格式计算:
+// kE||kM = SHA512(S.x)，iv = HMAC-SHA256(发送方私钥, 明文)[:16]，
+// 密文 = 发送方压缩公钥(33) + iv(16) + AES-256-CBC密文 + HMAC-SHA256(kM, iv+AES密文)(32)
+var eciesKnownAnswers = []struct {
+	name      string
+	sender    byte
+	recipient byte
+	message   []byte
+	encrypted string
+}{
+	{
+		name:      "utf8",
+		sender:    0x11,
+		recipient: 0x33,
+		message:   []byte("hello metaid 私信"),
+		encrypted: "034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa2c478ab8a5f5ea905b53d9b417554857539ad51ff6f502dc17a6b95a3baf17b12ed486b8cbcd65ed5ee685444c3a02bad3a36670ed0db792c6f72da8e75787b642d7508b5aba0ec413bdd2f6a6e18e5b",
+	},
+	{
+		name:      "加密给自己的空消息",
+		sender:    0x33,
+		recipient: 0x33,
+		message:   []byte{},
+		encrypted: "023c72addb4fdf09af94f0c94d7fe92a386a7e70cf8a1d85916386bb2535c7b1b112cd2fd8a6a1bbfdfb469a3988a8ae301ad771cd2cf905f1c5e767f4c36a6345faa9adb0031bfe926d3e015de79ed03cd913f1490e2ad68318d3af626c947fd0",
+	},
+	{
+		name:      "整块填充",
+		sender:    0x11,
+		recipient: 0x33,
+		message:   bytes.Repeat([]byte{0x10}, 32),
+		encrypted: "034f355bdcb7cc0af728ef3cceb9615d90684bb5b2ca5f859ab0f0b704075871aa0d3dc543452a56192910c9d7909d9b6d92189058229c88b915608f6405a245bc59a09f3314df7a53585a62d74a8c838661977b0414ad65e76ca49c0cb3f03a47a55fa90b466f5d77ebeedf54db84ccf9875d13bc640d0b0cc0cfbdc7f6eab2f2",
+	},
+}
+
+func TestEciesKnownAnswers(t *testing.T) {
+	for _, tt := range eciesKnownAnswers {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, recipient := testPrivateKey(tt.sender), testPrivateKey(tt.recipient)
+			encrypted, err := EciesEncryptWithKey(tt.message, sender, recipient.PubKey())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if hex.EncodeToString(encrypted) != tt.encrypted {
+				t.Errorf("密文不一致: %x", encrypted)
+			}
+
+			expected, _ := hex.DecodeString(tt.encrypted)
+			plain, err := EciesDecrypt(expected, recipient)
+			if err != nil || !bytes.Equal(plain, tt.message) {
+				t.Errorf("解密: %q, %v", plain, err)
+			}
+			// 插件的eciesEncrypt返回十六进制文本
+			plain, err = NewKeyDecrypter(recipient)(PinEncryptionEcies, []byte(tt.encrypted))
+			if err != nil || !bytes.Equal(plain, tt.message) {
+				t.Errorf("解密十六进制文本: %q, %v", plain, err)
+			}
+		})
+	}
+}
+
+func TestEciesDecryptErrors(t *testing.T) {
+	encrypted, _ := hex.DecodeString(eciesKnownAnswers[0].encrypted)
+	recipient := testPrivateKey(0x33)
+
+	if _, err := EciesDecrypt(encrypted, testPrivateKey(0x22)); !errors.Is(err, ErrPinKeyMismatch) {
+		t.Errorf("其他密钥: %v", err)
+	}
+	for i := range encrypted {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 1
+		// 0x03改为0x02得到发送方公钥的相反点，共享点的x坐标不变，仍然可以解密
+		if _, err := EciesDecrypt(tampered, recipient); err == nil && i != 0 {
+			t.Errorf("修改第%d字节后仍然解密成功", i)
+		}
+		if _, err := EciesDecrypt(encrypted[:i], recipient); err == nil {
+			t.Errorf("截断到%d字节后仍然解密成功", i)
+		}
+	}
+	if _, err := EciesDecrypt(encrypted, nil); err == nil {
+		t.Error("私钥为空应该返回错误")
+	}
+	if _, err := EciesEncryptWithKey([]byte("x"), nil, recipient.PubKey()); err == nil {
+		t.Error("发送方私钥为空应该返回错误")
+	}
+}
+
+func TestParseEciesPin(t *testing.T) {
+	recipient := testPrivateKey(0x33)
+	message := bytes.Repeat([]byte("私信"), 200)
+	inscriptionScript, err := BuildDogeMetaIdEciesInscription(message, "/protocols/simplemsg", recipient.PubKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	txRaw := testEncryptedPinTx(t, inscriptionScript)
+
+	result, err := ParseInscriptionFromTxWithKey(txRaw, InscriptionFormatMetaID, recipient)
+	if err != nil || !result.Decrypted || !bytes.Equal(result.Data, message) {
+		t.Fatalf("接收方解密: %v", err)
+	}
+
+	raw, err := ParseInscriptionFromTx(txRaw, InscriptionFormatMetaID)
+	if err != nil || raw.Decrypted || raw.Encryption != PinEncryptionEcies {
+		t.Fatalf("不解密: %v", err)
+	}
+
+	// 不是发给该密钥的PIN返回原始密文
+	other, err := ParseInscriptionFromTxWithKey(txRaw, InscriptionFormatMetaID, testPrivateKey(0x22))
+	if err != nil {
+		t.Fatalf("其他密钥: %v", err)
+	}
+	if other.Decrypted || !bytes.Equal(other.Data, raw.Data) || other.Path != "/protocols/simplemsg" {
+		t.Errorf("其他密钥: decrypted=%v path=%q", other.Decrypted, other.Path)
+	}
+
+	// 其他解密错误仍然返回
+	failing := func(string, []byte) ([]byte, error) { return nil, errors.New("硬件钱包拒绝") }
+	if _, err := ParseInscriptionFromTxWithDecrypter(txRaw, InscriptionFormatMetaID, failing); err == nil {
+		t.Error("decrypter的错误应该返回")
+	}
+	skip := func(string, []byte) ([]byte, error) { return nil, nil }
+	if result, err := ParseInscriptionFromTxWithDecrypter(txRaw, InscriptionFormatMetaID, skip); err != nil || result.Decrypted {
+		t.Errorf("decrypter不处理: %v", err)
+	}
+}
+
+// ECDH的已知结果由独立实现（Node.js crypto.createECDH('prime256v1')和aes-256-gcm）计算，
+// 发送方私钥为0x44...，接收方私钥为0x55...，nonce为12个0x07
+const (
+	ecdhKnownSenderPubKey    = "045b36890dacbd7c9a96bb74a1ee28b3d2d75b72e09a20ef25cf8e6fd8a9f0350d0e14bed8d4682a34d83538bdff5b96e89a6666ec0db5745d02fa1210072df75a"
+	ecdhKnownRecipientPubKey = "0457e977f6db7e33c3fe7acf2842ed987009caf56d458682fca447b7d3d762ab34c5ab3770ba573bdff5414065640ffb5b346dfa84dec4db4d68e5f59cc471c2ec"
+	ecdhKnownSharedSecret    = "c786a927985c7ca2e0e49906e00abf9350f7f0a85429f3c4b2ef7f72d4dc73fd"
+	ecdhKnownEncrypted       = ecdhKnownSenderPubKey + ecdhKnownRecipientPubKey + "070707070707070707070707" + "81ce40de8348d9b51f21a81f4723f0f0bcc509b0cc0746d6f4cd"
+	ecdhKnownMessage         = "secret msg"
+)
+
+func TestEcdhKnownAnswers(t *testing.T) {
+	sender, recipient := testPrivateKey(0x44), testPrivateKey(0x55)
+	senderPubKey, err := EcdhPublicKey(sender)
+	if err != nil || hex.EncodeToString(senderPubKey) != ecdhKnownSenderPubKey {
+		t.Fatalf("发送方公钥: %x, %v", senderPubKey, err)
+	}
+	recipientPubKey, err := EcdhPublicKey(recipient)
+	if err != nil || hex.EncodeToString(recipientPubKey) != ecdhKnownRecipientPubKey {
+		t.Fatalf("接收方公钥: %x, %v", recipientPubKey, err)
+	}
+	// 双方算出相同的sharedSecret
+	if sharedSecret, err := EcdhSharedSecret(sender, recipientPubKey); err != nil || hex.EncodeToString(sharedSecret) != ecdhKnownSharedSecret {
+		t.Errorf("发送方sharedSecret: %x, %v", sharedSecret, err)
+	}
+	if sharedSecret, err := EcdhSharedSecret(recipient, senderPubKey); err != nil || hex.EncodeToString(sharedSecret) != ecdhKnownSharedSecret {
+		t.Errorf("接收方sharedSecret: %x, %v", sharedSecret, err)
+	}
+
+	encrypted, err := ecdhEncryptWithNonce([]byte(ecdhKnownMessage), sender, recipientPubKey, bytes.Repeat([]byte{7}, ecdhNonceLen))
+	if err != nil || hex.EncodeToString(encrypted) != ecdhKnownEncrypted {
+		t.Errorf("密文不一致: %x, %v", encrypted, err)
+	}
+	expected, _ := hex.DecodeString(ecdhKnownEncrypted)
+	for _, privateKey := range []*btcec.PrivateKey{sender, recipient} {
+		plain, err := EcdhDecrypt(expected, privateKey)
+		if err != nil || string(plain) != ecdhKnownMessage {
+			t.Errorf("解密: %q, %v", plain, err)
+		}
+	}
+
+	// 随机nonce的密文不同，但都能解密
+	first, _ := EcdhEncrypt([]byte(ecdhKnownMessage), sender, recipientPubKey)
+	second, _ := EcdhEncrypt([]byte(ecdhKnownMessage), sender, recipientPubKey)
+	if bytes.Equal(first, second) {
+		t.Error("两次加密的密文相同")
+	}
+	if plain, err := EcdhDecrypt(second, recipient); err != nil || string(plain) != ecdhKnownMessage {
+		t.Errorf("解密: %q, %v", plain, err)
+	}
+}
+
+func TestEcdhDecryptErrors(t *testing.T) {
+	encrypted, _ := hex.DecodeString(ecdhKnownEncrypted)
+	recipient := testPrivateKey(0x55)
+
+	if _, err := EcdhDecrypt(encrypted, testPrivateKey(0x66)); !errors.Is(err, ErrPinKeyMismatch) {
+		t.Errorf("其他密钥: %v", err)
+	}
+	for i := range encrypted {
+		tampered := append([]byte{}, encrypted...)
+		tampered[i] ^= 1
+		if _, err := EcdhDecrypt(tampered, recipient); err == nil {
+			t.Errorf("修改第%d字节后仍然解密成功", i)
+		}
+		if _, err := EcdhDecrypt(encrypted[:i], recipient); err == nil {
+			t.Errorf("截断到%d字节后仍然解密成功", i)
+		}
+	}
+	// 修改nonce或密文时公钥仍然匹配，同样视为密钥不匹配
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := EcdhDecrypt(tampered, recipient); !errors.Is(err, ErrPinKeyMismatch) {
+		t.Errorf("修改密文: %v", err)
+	}
+	if _, err := EcdhSharedSecret(recipient, []byte{4, 1, 2}); err == nil {
+		t.Error("无效的公钥应该返回错误")
+	}
+}
+
+func TestParseEcdhPin(t *testing.T) {
+	sender, recipient := testPrivateKey(0x44), testPrivateKey(0x55)
+	recipientPubKey, _ := EcdhPublicKey(recipient)
+	inscriptionScript, err := BuildDogeMetaIdEcdhInscription([]byte(ecdhKnownMessage), "/protocols/simplemsg", sender, recipientPubKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txRaw := testEncryptedPinTx(t, inscriptionScript)
+
+	// 接收方和发送方都可以解密
+	for _, privateKey := range []*btcec.PrivateKey{sender, recipient} {
+		result, err := ParseInscriptionFromTxWithKey(txRaw, InscriptionFormatMetaID, privateKey)
+		if err != nil || !result.Decrypted || string(result.Data) != ecdhKnownMessage || result.Encryption != PinEncryptionEcdh {
+			t.Errorf("解密: %v", err)
+		}
+	}
+
+	// 不是发给该密钥的PIN返回原始密文
+	raw, err := ParseInscriptionFromTx(txRaw, InscriptionFormatMetaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ParseInscriptionFromTxWithKey(txRaw, InscriptionFormatMetaID, testPrivateKey(0x66))
+	if err != nil {
+		t.Fatalf("其他密钥: %v", err)
+	}
+	if other.Decrypted || !bytes.Equal(other.Data, raw.Data) {
+		t.Errorf("其他密钥: decrypted=%v", other.Decrypted)
+	}
+}
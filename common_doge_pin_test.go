@@ -0,0 +1,274 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestBuildDogeMetaIdPinInscription(t *testing.T) {
+	tests := []struct {
+		name     string
+		pin      *DogeMetaIdPin
+		expected InscriptionData
+	}{
+		{
+			name: "默认值",
+			pin:  &DogeMetaIdPin{Path: "/protocols/simplebuzz", Body: []byte(`{"content":"hi"}`)},
+			expected: InscriptionData{Operation: "create", Path: "/protocols/simplebuzz", Encryption: PinEncryptionNone,
+				Version: "0.0.1", ContentType: "application/json", Data: []byte(`{"content":"hi"}`)},
+		},
+		{
+			name: "全部字段",
+			pin: &DogeMetaIdPin{Operation: "modify", Path: "@abc/info/name", Encryption: PinEncryptionEcies,
+				Version: "1.0.0", ContentType: "text/plain", Body: []byte("doge")},
+			expected: InscriptionData{Operation: "modify", Path: "@abc/info/name", Encryption: PinEncryptionEcies,
+				Version: "1.0.0", ContentType: "text/plain", Data: []byte("doge")},
+		},
+		{
+			name:     "init只有metaid和operation",
+			pin:      &DogeMetaIdPin{Operation: "init", Path: "/ignored", Body: []byte("ignored")},
+			expected: InscriptionData{Operation: "init", Data: []byte{}},
+		},
+		{
+			name: "超过ScriptBuilder限制的body",
+			pin:  &DogeMetaIdPin{Path: "/file", ContentType: "image/png", Body: bytes.Repeat([]byte{0x89, 0x50}, 6000)},
+			expected: InscriptionData{Operation: "create", Path: "/file", Encryption: PinEncryptionNone,
+				Version: "0.0.1", ContentType: "image/png", Data: bytes.Repeat([]byte{0x89, 0x50}, 6000)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inscriptionScript, err := BuildDogeMetaIdPinInscription(tt.pin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tokens, err := tokenizeScript(inscriptionScript)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := parseMetaIDInscription(tokens)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Operation != tt.expected.Operation || result.Path != tt.expected.Path ||
+				result.Encryption != tt.expected.Encryption || result.Version != tt.expected.Version ||
+				result.ContentType != tt.expected.ContentType || !bytes.Equal(result.Data, tt.expected.Data) {
+				t.Errorf("%+v", result)
+			}
+		})
+	}
+
+	// BuildDogeMetaIdInscription的输出保持不变
+	legacy, _ := BuildDogeMetaIdInscription([]byte("x"), "/p")
+	pin, _ := BuildDogeMetaIdPinInscription(&DogeMetaIdPin{Path: "/p", Body: []byte("x")})
+	if !bytes.Equal(legacy, pin) {
+		t.Error("BuildDogeMetaIdInscription与默认值的PIN不一致")
+	}
+}
+
+func TestReplacePinRefs(t *testing.T) {
+	txIds := []string{strings.Repeat("a", 64), strings.Repeat("b", 64)}
+	tests := []struct {
+		name     string
+		body     string
+		refs     map[string]int
+		expected string
+		wantErr  bool
+	}{
+		{name: "没有引用", body: "{{img}}", expected: "{{img}}"},
+		{name: "PIN id", body: `["metafile://{{img}}i0"]`, refs: map[string]int{"{{img}}": 0}, expected: `["metafile://` + txIds[0] + `i0"]`},
+		{name: "多个引用和多次出现", body: "{{a}} {{b}} {{a}}", refs: map[string]int{"{{a}}": 0, "{{b}}": 1}, expected: txIds[0] + " " + txIds[1] + " " + txIds[0]},
+		// 较短的占位符是较长占位符的前缀
+		{name: "前缀", body: "{{img}} {{img}}_thumb", refs: map[string]int{"{{img}}": 0, "{{img}}_thumb": 1}, expected: txIds[0] + " " + txIds[1]},
+		// 替换进去的txid包含另一个占位符，不能再被替换
+		{name: "txid不再替换", body: "{{x}}", refs: map[string]int{"{{x}}": 1, "b": 0}, expected: txIds[1]},
+		{name: "引用之后的PIN", body: "{{x}}", refs: map[string]int{"{{x}}": 2}, wantErr: true},
+		{name: "负数索引", body: "{{x}}", refs: map[string]int{"{{x}}": -1}, wantErr: true},
+		{name: "空占位符", body: "x", refs: map[string]int{"": 0}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 多次执行，结果与map遍历顺序无关
+			for i := 0; i < 10; i++ {
+				body, err := replacePinRefs([]byte(tt.body), tt.refs, txIds)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("err = %v", err)
+				}
+				if !tt.wantErr && string(body) != tt.expected {
+					t.Fatalf("%s", body)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildDogeMetaIdPinBatch(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	_, receiver := testDogeKey(t, 2)
+	image := bytes.Repeat([]byte{0x89, 0x50}, 3000)
+	chains, err := BuildDogeMetaIdPinBatch(DogeMainNetParams, []*DogePinDetail{
+		{Pin: &DogeMetaIdPin{Path: "/file", ContentType: "image/png", Body: image}},
+		{
+			Pin:           &DogeMetaIdPin{Path: "/protocols/simplebuzz", Body: []byte(`{"content":"hi","attachments":["metafile://{{img}}i0"]}`)},
+			Refs:          map[string]int{"{{img}}": 0},
+			OutputAddress: receiver,
+			OutputValue:   200000,
+		},
+	}, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chains) != 2 {
+		t.Fatalf("交易链数量%d", len(chains))
+	}
+	for _, chain := range chains {
+		testSubmitAndMine(t, sim, chain.Txs...)
+		reveal := chain.Txs[len(chain.Txs)-1]
+		if reveal.TxHash().String() != chain.RevealTxId || chain.PinId != chain.RevealTxId+"i0" {
+			t.Errorf("RevealTxId=%s PinId=%s", chain.RevealTxId, chain.PinId)
+		}
+	}
+
+	// 第二个PIN用第一个PIN的找零
+	firstTxIds := make(map[string]bool)
+	for _, tx := range chains[0].Txs {
+		firstTxIds[tx.TxHash().String()] = true
+	}
+	if !firstTxIds[chains[1].Txs[0].TxIn[0].PreviousOutPoint.Hash.String()] {
+		t.Error("第二个PIN没有使用第一个PIN的找零")
+	}
+
+	image0, err := ParseInscriptionFromChain(testChainHexes(t, chains[0].Txs), InscriptionFormatMetaID)
+	if err != nil || image0.ContentType != "image/png" || !bytes.Equal(image0.Data, image) {
+		t.Fatalf("图片PIN: %v", err)
+	}
+	buzzTx := chains[1].Txs[len(chains[1].Txs)-1]
+	buzz, err := ParseInscriptionFromTx(testTxHex(t, buzzTx), InscriptionFormatMetaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `"metafile://` + chains[0].PinId + `"`; !strings.Contains(string(buzz.Data), expected) {
+		t.Errorf("buzz没有引用图片的PIN id: %s", buzz.Data)
+	}
+	receiverScript, _ := addressPkScriptHex(DogeMainNetParams, receiver)
+	if buzzTx.TxOut[0].Value != 200000 || hex.EncodeToString(buzzTx.TxOut[0].PkScript) != receiverScript {
+		t.Errorf("buzz的reveal输出: %d", buzzTx.TxOut[0].Value)
+	}
+
+	for name, pins := range map[string][]*DogePinDetail{
+		"PIN为空":    {{}},
+		"nil PIN":  {nil},
+		"空占位符":     {{Pin: &DogeMetaIdPin{Path: "/p"}}, {Pin: &DogeMetaIdPin{Path: "/p"}, Refs: map[string]int{"": 0}}},
+		"引用之后的PIN": {{Pin: &DogeMetaIdPin{Path: "/p"}, Refs: map[string]int{"{{x}}": 0}}},
+	} {
+		if _, err := BuildDogeMetaIdPinBatch(DogeMainNetParams, pins, utxos, address, 1000); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := BuildDogeMetaIdPinBatch(DogeMainNetParams, []*DogePinDetail{{Pin: &DogeMetaIdPin{Path: "/p"}}}, utxos, "", 1000); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("缺少地址: %v", err)
+	}
+}
+
+// TestDogeBroadcastBatches 每组都能在前一组确认后一次广播
+func TestDogeBroadcastBatches(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 100e8)
+	spend := func(prev *wire.MsgTx, index uint32, values ...int64) *wire.MsgTx {
+		return testSpendTx(t, &TxInputUtxo{TxId: prev.TxHash().String(), TxIndex: int64(index), PkScript: utxos[0].PkScript, PriHex: utxos[0].PriHex}, address, values...)
+	}
+	// 30笔的交易链，之后一笔交易有30个子交易
+	txs := []*wire.MsgTx{testSpendTx(t, utxos[0], address, 99e8)}
+	for len(txs) < 30 {
+		txs = append(txs, spend(txs[len(txs)-1], 0, txs[len(txs)-1].TxOut[0].Value-1e7))
+	}
+	values := make([]int64, 30)
+	for i := range values {
+		values[i] = 1e7
+	}
+	parent := spend(txs[len(txs)-1], 0, values...)
+	txs = append(txs, parent)
+	for i := range values {
+		txs = append(txs, spend(parent, uint32(i), 1e7-1e6))
+	}
+
+	batches := DogeBroadcastBatches(txs)
+	var sizes []int
+	for _, batch := range batches {
+		sizes = append(sizes, len(batch))
+	}
+	// 第二组的第一笔交易是之后所有交易的祖先，它的后代包含自身最多25笔
+	if len(sizes) != 3 || sizes[0] != 25 || sizes[1] != 25 || sizes[2] != 11 {
+		t.Fatalf("分组: %v", sizes)
+	}
+	if other, _, _, _ := testSimulatorWallet(t, 1, 100e8); other.SubmitTxs(txs) == nil {
+		t.Error("一次广播所有交易应该被拒绝")
+	}
+	for _, batch := range batches {
+		testSubmitAndMine(t, sim, batch...)
+	}
+	if DogeBroadcastBatches(nil) != nil {
+		t.Error("没有交易时应该没有分组")
+	}
+}
+
+func TestDogeExtraOutputs(t *testing.T) {
+	_, service := testDogeKey(t, 2)
+	_, other := testDogeKey(t, 3)
+	for _, position := range []DogeExtraOutputsPosition{DogeExtraOutputsOnReveal, DogeExtraOutputsOnCommit} {
+		for _, size := range []int{10, 3000} {
+			sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+			builder, err := NewInscriptionBuilder(
+				WithMetaIdPin(&DogeMetaIdPin{Path: "/x", Body: bytes.Repeat([]byte("a"), size)}),
+				WithUtxos(utxos),
+				WithChangeAddress(address),
+				WithFeeRate(1000),
+				WithExtraOutputs(&DogeExtraOutputs{
+					Service:  &TxOutput{Address: service, Amount: 1e7},
+					Outputs:  []*TxOutput{{Address: other, Amount: 5e7}},
+					Position: position,
+				}),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := builder.Build(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			testSubmitAndMine(t, sim, result.Txs...)
+
+			tx := result.Txs[len(result.Txs)-1]
+			if position == DogeExtraOutputsOnCommit {
+				tx = result.Txs[0]
+			}
+			// 服务费在前，紧随第0个输出
+			serviceScript, _ := addressPkScriptHex(DogeMainNetParams, service)
+			otherScript, _ := addressPkScriptHex(DogeMainNetParams, other)
+			if tx.TxOut[1].Value != 1e7 || hex.EncodeToString(tx.TxOut[1].PkScript) != serviceScript ||
+				tx.TxOut[2].Value != 5e7 || hex.EncodeToString(tx.TxOut[2].PkScript) != otherScript {
+				t.Errorf("position %d size %d: 额外输出不正确", position, size)
+			}
+		}
+	}
+
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	for _, amount := range []int64{0, 600, DogeDustLimit - 1} {
+		_, err := NewInscriptionBuilder(
+			WithMetaIdPin(&DogeMetaIdPin{Path: "/x", Body: []byte("a")}),
+			WithUtxos(utxos),
+			WithChangeAddress(address),
+			WithFeeRate(1000),
+			WithExtraOutputs(&DogeExtraOutputs{Service: &TxOutput{Address: service, Amount: amount}}),
+		)
+		var dustErr *ErrDustOutput
+		if !errors.As(err, &dustErr) || dustErr.Amount != amount || dustErr.Limit != DogeDustLimit {
+			t.Errorf("金额%d: %v", amount, err)
+		}
+	}
+}
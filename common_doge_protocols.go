@@ -0,0 +1,277 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// MetaID常用应用协议的路径
+const (
+	DogeProtocolPathBuzz    = "/protocols/simplebuzz"
+	DogeProtocolPathLike    = "/protocols/paylike"
+	DogeProtocolPathComment = "/protocols/paycomment"
+	DogeProtocolPathFollow  = "/follow"
+	DogeProtocolPathName    = "/info/name"
+	DogeProtocolPathAvatar  = "/info/avatar"
+	DogeProtocolPathBio     = "/info/bio"
+
+	// DogeProtocolTextContentType buzz和comment内容的contentType字段
+	DogeProtocolTextContentType = "text/plain;utf-8"
+)
+
+// ClassifyDogeMetaIdPin返回的协议类型
+const (
+	DogeProtocolBuzz     = "buzz"
+	DogeProtocolLike     = "like"
+	DogeProtocolComment  = "comment"
+	DogeProtocolFollow   = "follow"
+	DogeProtocolUnfollow = "unfollow"
+	DogeProtocolName     = "name"
+	DogeProtocolAvatar   = "avatar"
+	DogeProtocolBio      = "bio"
+)
+
+// MetaID协议字段的长度限制
+const (
+	dogeProtocolMaxNameLen = 64   // 用户名最多64个字符
+	dogeProtocolMaxBioLen  = 1024 // 简介最多1024个字符
+)
+
+// DogeBuzz /protocols/simplebuzz的内容
+type DogeBuzz struct {
+	Content     string   `json:"content"`
+	ContentType string   `json:"contentType"`           // 默认text/plain;utf-8
+	Attachments []string `json:"attachments,omitempty"` // metafile://<PIN id>
+	QuotePin    string   `json:"quotePin,omitempty"`    // 引用（转发）的PIN id
+}
+
+// DogeLike /protocols/paylike的内容，IsLike为"1"点赞，"0"取消
+type DogeLike struct {
+	IsLike string `json:"isLike"`
+	LikeTo string `json:"likeTo"`
+}
+
+// DogeComment /protocols/paycomment的内容
+type DogeComment struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"` // 默认text/plain;utf-8
+	CommentTo   string `json:"commentTo"`
+}
+
+// DogeProtocolPin ClassifyDogeMetaIdPin的结果，按Kind只有对应的字段有意义
+type DogeProtocolPin struct {
+	Kind     string
+	Buzz     *DogeBuzz
+	Like     *DogeLike
+	Comment  *DogeComment
+	FollowTo string // follow: 被关注的MetaID；unfollow: 被撤销的follow PIN id
+	Name     string
+	Bio      string
+	// Avatar 头像图片，ContentType为图片类型
+	Avatar            []byte
+	AvatarContentType string
+}
+
+// isDogeMetaId s是否是MetaID（64个小写十六进制字符）
+func isDogeMetaId(s string) bool {
+	return len(s) == 64 && isHexString(s)
+}
+
+// trimMetaIdHost 去掉路径的host前缀（host:/path）
+func trimMetaIdHost(path string) string {
+	if i := strings.Index(path, ":/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// newDogeProtocolJsonPin 把内容序列化为JSON并生成PIN
+func newDogeProtocolJsonPin(path string, body interface{}) (*DogeMetaIdPin, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("序列化%s的内容失败: %w", path, err)
+	}
+	return &DogeMetaIdPin{Path: path, ContentType: "application/json", Body: data}, nil
+}
+
+// Validate 校验buzz：内容和附件不能都为空，附件必须是metafile://<PIN id>
+func (b *DogeBuzz) Validate() error {
+	if b.Content == "" && len(b.Attachments) == 0 && b.QuotePin == "" {
+		return fmt.Errorf("%w: buzz内容为空", ErrInvalidOption)
+	}
+	for i, attachment := range b.Attachments {
+		if _, err := ParseDogeMetaFileURI(attachment); err != nil {
+			return fmt.Errorf("%w: 第%d个附件不是metafile://<PIN id>: %q", ErrInvalidOption, i, attachment)
+		}
+	}
+	if b.QuotePin != "" && !isDogePinId(b.QuotePin) {
+		return fmt.Errorf("%w: 无效的quotePin: %q", ErrInvalidOption, b.QuotePin)
+	}
+	return nil
+}
+
+// NewDogeBuzzPin 生成buzz PIN，attachments为附件的PIN id（不带metafile://前缀）
+func NewDogeBuzzPin(content string, attachments ...string) (*DogeMetaIdPin, error) {
+	buzz := &DogeBuzz{Content: content, ContentType: DogeProtocolTextContentType}
+	for _, pinId := range attachments {
+		buzz.Attachments = append(buzz.Attachments, DogeMetaFileScheme+pinId)
+	}
+	return buzz.Pin()
+}
+
+// Pin 校验后生成buzz PIN
+func (b *DogeBuzz) Pin() (*DogeMetaIdPin, error) {
+	if b.ContentType == "" {
+		b.ContentType = DogeProtocolTextContentType
+	}
+	if err := b.Validate(); err != nil {
+		return nil, err
+	}
+	return newDogeProtocolJsonPin(DogeProtocolPathBuzz, b)
+}
+
+// NewDogeLikePin 生成点赞PIN，isLike为false时取消点赞
+func NewDogeLikePin(pinId string, isLike bool) (*DogeMetaIdPin, error) {
+	if !isDogePinId(pinId) {
+		return nil, fmt.Errorf("%w: 无效的PIN id: %q", ErrInvalidOption, pinId)
+	}
+	like := &DogeLike{IsLike: "1", LikeTo: pinId}
+	if !isLike {
+		like.IsLike = "0"
+	}
+	return newDogeProtocolJsonPin(DogeProtocolPathLike, like)
+}
+
+// NewDogeCommentPin 生成评论PIN
+func NewDogeCommentPin(pinId, content string) (*DogeMetaIdPin, error) {
+	if !isDogePinId(pinId) {
+		return nil, fmt.Errorf("%w: 无效的PIN id: %q", ErrInvalidOption, pinId)
+	}
+	if content == "" {
+		return nil, fmt.Errorf("%w: 评论内容为空", ErrInvalidOption)
+	}
+	return newDogeProtocolJsonPin(DogeProtocolPathComment, &DogeComment{
+		Content:     content,
+		ContentType: DogeProtocolTextContentType,
+		CommentTo:   pinId,
+	})
+}
+
+// NewDogeFollowPin 生成关注PIN，内容为被关注用户的MetaID
+func NewDogeFollowPin(metaId string) (*DogeMetaIdPin, error) {
+	if !isDogeMetaId(metaId) {
+		return nil, fmt.Errorf("%w: 无效的MetaID: %q", ErrInvalidOption, metaId)
+	}
+	return &DogeMetaIdPin{Path: DogeProtocolPathFollow, ContentType: "text/plain", Body: []byte(metaId)}, nil
+}
+
+// NewDogeUnfollowPin 生成取消关注PIN：撤销（revoke）之前的follow PIN，路径为@<follow PIN id>
+func NewDogeUnfollowPin(followPinId string) (*DogeMetaIdPin, error) {
+	if !isDogePinId(followPinId) {
+		return nil, fmt.Errorf("%w: 无效的PIN id: %q", ErrInvalidOption, followPinId)
+	}
+	return &DogeMetaIdPin{Operation: "revoke", Path: "@" + followPinId, ContentType: "text/plain"}, nil
+}
+
+// NewDogeNamePin 生成用户名PIN
+func NewDogeNamePin(name string) (*DogeMetaIdPin, error) {
+	if n := utf8.RuneCountInString(name); name == "" || !utf8.ValidString(name) || n > dogeProtocolMaxNameLen {
+		return nil, fmt.Errorf("%w: 用户名必须是1到%d个字符: %q", ErrInvalidOption, dogeProtocolMaxNameLen, name)
+	}
+	return &DogeMetaIdPin{Path: DogeProtocolPathName, ContentType: "text/plain", Body: []byte(name)}, nil
+}
+
+// NewDogeBioPin 生成简介PIN
+func NewDogeBioPin(bio string) (*DogeMetaIdPin, error) {
+	if n := utf8.RuneCountInString(bio); !utf8.ValidString(bio) || n > dogeProtocolMaxBioLen {
+		return nil, fmt.Errorf("%w: 简介最多%d个字符", ErrInvalidOption, dogeProtocolMaxBioLen)
+	}
+	return &DogeMetaIdPin{Path: DogeProtocolPathBio, ContentType: "text/plain", Body: []byte(bio)}, nil
+}
+
+// NewDogeAvatarPin 生成头像PIN，内容为图片的二进制数据，contentType必须是image/*
+func NewDogeAvatarPin(image []byte, contentType string) (*DogeMetaIdPin, error) {
+	if len(image) == 0 {
+		return nil, fmt.Errorf("%w: 头像图片为空", ErrInvalidOption)
+	}
+	if !strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("%w: 头像的内容类型必须是image/*: %q", ErrInvalidOption, contentType)
+	}
+	return &DogeMetaIdPin{Path: DogeProtocolPathAvatar, ContentType: contentType, Body: image}, nil
+}
+
+// ClassifyDogeMetaIdPin 把解析出的MetaID PIN识别为常用的应用协议
+// 路径可以带host前缀；不是这些协议时返回nil和nil，字段不合法时返回error，加密的PIN需要先解密
+func ClassifyDogeMetaIdPin(inscription *InscriptionData) (*DogeProtocolPin, error) {
+	if inscription == nil || inscription.Format != InscriptionFormatMetaID {
+		return nil, fmt.Errorf("%w: 不是MetaID格式", ErrNotInscription)
+	}
+	if inscription.Encryption != "" && inscription.Encryption != PinEncryptionNone && !inscription.Decrypted {
+		return nil, fmt.Errorf("PIN已加密，需要先解密")
+	}
+	path := trimMetaIdHost(inscription.Path)
+
+	if inscription.Operation == "revoke" {
+		followPinId, ok := strings.CutPrefix(path, "@")
+		if !ok || !isDogePinId(followPinId) {
+			return nil, nil
+		}
+		// revoke的路径只有PIN id，无法区分被撤销的是否是follow PIN，由调用方按PIN id查询
+		return &DogeProtocolPin{Kind: DogeProtocolUnfollow, FollowTo: followPinId}, nil
+	}
+	if inscription.Operation != "create" {
+		return nil, nil
+	}
+
+	switch path {
+	case DogeProtocolPathBuzz:
+		buzz := &DogeBuzz{}
+		if err := json.Unmarshal(inscription.Data, buzz); err != nil {
+			return nil, fmt.Errorf("解析buzz失败: %w", err)
+		}
+		if err := buzz.Validate(); err != nil {
+			return nil, err
+		}
+		return &DogeProtocolPin{Kind: DogeProtocolBuzz, Buzz: buzz}, nil
+	case DogeProtocolPathLike:
+		like := &DogeLike{}
+		if err := json.Unmarshal(inscription.Data, like); err != nil {
+			return nil, fmt.Errorf("解析like失败: %w", err)
+		}
+		if (like.IsLike != "0" && like.IsLike != "1") || !isDogePinId(like.LikeTo) {
+			return nil, fmt.Errorf("无效的like: %s", inscription.Data)
+		}
+		return &DogeProtocolPin{Kind: DogeProtocolLike, Like: like}, nil
+	case DogeProtocolPathComment:
+		comment := &DogeComment{}
+		if err := json.Unmarshal(inscription.Data, comment); err != nil {
+			return nil, fmt.Errorf("解析comment失败: %w", err)
+		}
+		if comment.Content == "" || !isDogePinId(comment.CommentTo) {
+			return nil, fmt.Errorf("无效的comment: %s", inscription.Data)
+		}
+		return &DogeProtocolPin{Kind: DogeProtocolComment, Comment: comment}, nil
+	case DogeProtocolPathFollow:
+		metaId := strings.TrimSpace(string(inscription.Data))
+		if !isDogeMetaId(metaId) {
+			return nil, fmt.Errorf("无效的follow: %q", inscription.Data)
+		}
+		return &DogeProtocolPin{Kind: DogeProtocolFollow, FollowTo: metaId}, nil
+	case DogeProtocolPathName:
+		name := string(inscription.Data)
+		if name == "" || !utf8.ValidString(name) || utf8.RuneCountInString(name) > dogeProtocolMaxNameLen {
+			return nil, fmt.Errorf("无效的用户名: %q", name)
+		}
+		return &DogeProtocolPin{Kind: DogeProtocolName, Name: name}, nil
+	case DogeProtocolPathBio:
+		if !utf8.Valid(inscription.Data) {
+			return nil, fmt.Errorf("简介不是有效的UTF-8")
+		}
+		return &DogeProtocolPin{Kind: DogeProtocolBio, Bio: string(inscription.Data)}, nil
+	case DogeProtocolPathAvatar:
+		return &DogeProtocolPin{Kind: DogeProtocolAvatar, Avatar: inscription.Data, AvatarContentType: inscription.ContentType}, nil
+	}
+	return nil, nil
+}
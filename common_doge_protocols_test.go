@@ -0,0 +1,148 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDogeProtocolPins(t *testing.T) {
+	sim, priHex, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	pinId := testPinId(0xab)
+	metaId := strings.Repeat("cd", 32)
+	mustPin := func(pin *DogeMetaIdPin, err error) *DogeMetaIdPin {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pin
+	}
+	pins := []struct {
+		pin  *DogeMetaIdPin
+		kind string
+	}{
+		{mustPin(NewDogeBuzzPin("hello", pinId)), DogeProtocolBuzz},
+		{mustPin(NewDogeLikePin(pinId, true)), DogeProtocolLike},
+		{mustPin(NewDogeCommentPin(pinId, "nice")), DogeProtocolComment},
+		{mustPin(NewDogeFollowPin(metaId)), DogeProtocolFollow},
+		{mustPin(NewDogeUnfollowPin(pinId)), DogeProtocolUnfollow},
+		{mustPin(NewDogeNamePin("狗狗")), DogeProtocolName},
+		{mustPin(NewDogeBioPin("bio")), DogeProtocolBio},
+		{mustPin(NewDogeAvatarPin([]byte{0x89, 'P', 'N', 'G'}, "image/png")), DogeProtocolAvatar},
+	}
+	// 内容与插件发布的JSON一致
+	if body := string(pins[0].pin.Body); body != `{"content":"hello","contentType":"text/plain;utf-8","attachments":["metafile://`+pinId+`"]}` {
+		t.Errorf("buzz: %s", body)
+	}
+	if body := string(pins[1].pin.Body); body != `{"isLike":"1","likeTo":"`+pinId+`"}` {
+		t.Errorf("like: %s", body)
+	}
+
+	for _, p := range pins {
+		builder, err := NewInscriptionBuilder(WithMetaIdPin(p.pin), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := builder.Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		testSubmitAndMine(t, sim, result.Txs...)
+		if utxos, err = ListSpendableUtxos(context.Background(), sim, address, priHex); err != nil {
+			t.Fatal(err)
+		}
+		inscription, err := ParseInscriptionFromTx(testTxHex(t, result.Txs[len(result.Txs)-1]), InscriptionFormatMetaID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		classified, err := ClassifyDogeMetaIdPin(inscription)
+		if err != nil || classified == nil || classified.Kind != p.kind {
+			t.Errorf("%s: %v %+v", p.kind, err, classified)
+			continue
+		}
+		switch p.kind {
+		case DogeProtocolBuzz:
+			if classified.Buzz.Content != "hello" || classified.Buzz.Attachments[0] != "metafile://"+pinId {
+				t.Errorf("buzz: %+v", classified.Buzz)
+			}
+		case DogeProtocolFollow:
+			if classified.FollowTo != metaId {
+				t.Errorf("follow: %s", classified.FollowTo)
+			}
+		case DogeProtocolUnfollow:
+			if classified.FollowTo != pinId {
+				t.Errorf("unfollow: %s", classified.FollowTo)
+			}
+		case DogeProtocolName:
+			if classified.Name != "狗狗" {
+				t.Errorf("name: %s", classified.Name)
+			}
+		case DogeProtocolAvatar:
+			if classified.AvatarContentType != "image/png" || len(classified.Avatar) != 4 {
+				t.Errorf("avatar: %s", classified.AvatarContentType)
+			}
+		}
+	}
+}
+
+func TestDogeProtocolPinsInvalid(t *testing.T) {
+	for name, build := range map[string]func() (*DogeMetaIdPin, error){
+		"空buzz":            func() (*DogeMetaIdPin, error) { return NewDogeBuzzPin("") },
+		"无效quotePin":       func() (*DogeMetaIdPin, error) { return (&DogeBuzz{Content: "x", QuotePin: "bad"}).Pin() },
+		"comment无效PIN id":  func() (*DogeMetaIdPin, error) { return NewDogeCommentPin("x", "c") },
+		"空头像":              func() (*DogeMetaIdPin, error) { return NewDogeAvatarPin(nil, "image/png") },
+		"附件不是PIN id":       func() (*DogeMetaIdPin, error) { return NewDogeBuzzPin("x", "bad") },
+		"like无效PIN id":     func() (*DogeMetaIdPin, error) { return NewDogeLikePin("x", true) },
+		"空评论":              func() (*DogeMetaIdPin, error) { return NewDogeCommentPin(testPinId(1), "") },
+		"follow无效MetaID":   func() (*DogeMetaIdPin, error) { return NewDogeFollowPin("x") },
+		"unfollow无效PIN id": func() (*DogeMetaIdPin, error) { return NewDogeUnfollowPin("x") },
+		"空用户名":             func() (*DogeMetaIdPin, error) { return NewDogeNamePin("") },
+		"简介太长":             func() (*DogeMetaIdPin, error) { return NewDogeBioPin(strings.Repeat("a", 1025)) },
+		"头像不是图片":           func() (*DogeMetaIdPin, error) { return NewDogeAvatarPin([]byte{1}, "text/plain") },
+	} {
+		if _, err := build(); !errors.Is(err, ErrInvalidOption) || DogeErrorCode(err) != ErrCodeInvalidOption {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestClassifyDogeMetaIdPin(t *testing.T) {
+	pinId := testPinId(0xab)
+	like := `{"isLike":"1","likeTo":"` + pinId + `"}`
+	// 其他路径和修改操作不是这些协议
+	for _, inscription := range []*InscriptionData{
+		{Format: InscriptionFormatMetaID, Operation: "create", Path: "/other"},
+		{Format: InscriptionFormatMetaID, Operation: "modify", Path: DogeProtocolPathLike, Data: []byte(like)},
+		{Format: InscriptionFormatMetaID, Operation: "revoke", Path: "@x"},
+	} {
+		if classified, err := ClassifyDogeMetaIdPin(inscription); classified != nil || err != nil {
+			t.Errorf("%s %s: %+v %v", inscription.Operation, inscription.Path, classified, err)
+		}
+	}
+	// 路径可以带host前缀
+	classified, err := ClassifyDogeMetaIdPin(&InscriptionData{Format: InscriptionFormatMetaID, Operation: "create", Path: "host:" + DogeProtocolPathLike, Data: []byte(like)})
+	if err != nil || classified == nil || classified.Kind != DogeProtocolLike || classified.Like.LikeTo != pinId {
+		t.Errorf("host前缀: %+v %v", classified, err)
+	}
+
+	if _, err := ClassifyDogeMetaIdPin(&InscriptionData{Format: InscriptionFormatDoginal}); !errors.Is(err, ErrNotInscription) {
+		t.Errorf("Doginal: %v", err)
+	}
+	for _, inscription := range []*InscriptionData{
+		{Format: InscriptionFormatMetaID, Operation: "create", Path: DogeProtocolPathLike, Encryption: PinEncryptionEcies, Data: []byte(like)},
+		{Format: InscriptionFormatMetaID, Operation: "create", Path: DogeProtocolPathLike, Data: []byte(`{"isLike":"2","likeTo":"` + pinId + `"}`)},
+		{Format: InscriptionFormatMetaID, Operation: "create", Path: DogeProtocolPathBuzz, Data: []byte(`not json`)},
+		{Format: InscriptionFormatMetaID, Operation: "create", Path: DogeProtocolPathFollow, Data: []byte("x")},
+		{Format: InscriptionFormatMetaID, Operation: "create", Path: DogeProtocolPathBio, Data: []byte{0xff}},
+	} {
+		if _, err := ClassifyDogeMetaIdPin(inscription); err == nil {
+			t.Errorf("%s %s: 没有返回错误", inscription.Path, inscription.Data)
+		}
+	}
+	// 解密后的PIN可以识别
+	classified, err = ClassifyDogeMetaIdPin(&InscriptionData{Format: InscriptionFormatMetaID, Operation: "create", Path: DogeProtocolPathLike, Encryption: PinEncryptionEcies, Decrypted: true, Data: []byte(like)})
+	if err != nil || classified == nil || classified.Kind != DogeProtocolLike {
+		t.Errorf("解密后的PIN: %+v %v", classified, err)
+	}
+}
@@ -0,0 +1,142 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+// 交易在inscription交易链中的角色
+const (
+	DogeTxRoleCommit  = "commit"  // 第一笔交易，由钱包UTXO创建第一个P2SH输出
+	DogeTxRolePartial = "partial" // 中间交易，花费上一个P2SH并创建下一个P2SH
+	DogeTxRoleReveal  = "reveal"  // 最后一笔交易，花费最后一个P2SH，输出到接收地址
+)
+
+// DogeTxReport 交易链中一笔交易的明细，金额单位均为satoshis
+type DogeTxReport struct {
+	Role        string `json:"role"`
+	TxId        string `json:"txId"`
+	TxHex       string `json:"txHex"`
+	Size        int    `json:"size"`        // 序列化字节数
+	InputValue  int64  `json:"inputValue"`  // 输入总额（P2SH输入 + 钱包UTXO）
+	OutputValue int64  `json:"outputValue"` // 输出总额
+	Fee         int64  `json:"fee"`         // InputValue - OutputValue
+	Change      int64  `json:"change"`      // 找零金额，没有找零为0
+	LockedValue int64  `json:"lockedValue"` // P2SH或reveal输出锁定的金额，包含reveal交易上携带token的输出
+	ExtraValue  int64  `json:"extraValue"`  // 服务费和额外输出的金额
+}
+
+// DogeInscriptionResult inscription交易链的费用报告
+// JSON字段与createPin的CreatePinResult一致，可以直接返回给前端：
+// 先广播commitTxHex，再按顺序广播revealTxsHex（包含所有partial交易）
+type DogeInscriptionResult struct {
+	Txs []*wire.MsgTx `json:"-"` // 按广播顺序排列的交易
+
+	CommitTxId   string          `json:"commitTxId"`
+	RevealTxIds  []string        `json:"revealTxIds"`
+	CommitTxHex  string          `json:"commitTxHex"`
+	RevealTxsHex []string        `json:"revealTxsHex"`
+	Details      []*DogeTxReport `json:"details"`
+
+	CommitCost  int64 `json:"commitCost"`  // commit交易的手续费
+	RevealCost  int64 `json:"revealCost"`  // partial和reveal交易的手续费
+	ServiceCost int64 `json:"serviceCost"` // 服务费和额外输出
+	TotalCost   int64 `json:"totalCost"`   // CommitCost + RevealCost + ServiceCost
+}
+
+// newDogeInscriptionResult 创建空报告，列表字段序列化为[]而不是null
+func newDogeInscriptionResult() *DogeInscriptionResult {
+	return &DogeInscriptionResult{
+		Txs:          make([]*wire.MsgTx, 0),
+		RevealTxIds:  make([]string, 0),
+		RevealTxsHex: make([]string, 0),
+		Details:      make([]*DogeTxReport, 0),
+	}
+}
+
+// utxosValue 计算UTXO的总金额
+func utxosValue(utxos []*TxInputUtxo) int64 {
+	total := int64(0)
+	for _, utxo := range utxos {
+		total += int64(utxo.Amount)
+	}
+	return total
+}
+
+// addTx 把一笔交易及其明细加入报告
+// inputValue为交易输入总额，changeIndex为找零输出的索引（没有找零为-1），
+// 前lockedCount个输出为P2SH或reveal输出，extraCount为紧随其后的额外输出数量
+func (r *DogeInscriptionResult) addTx(tx *wire.MsgTx, role string, inputValue int64, changeIndex int, lockedCount int, extraCount int) error {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return fmt.Errorf("序列化交易失败: %w", err)
+	}
+	report := &DogeTxReport{
+		Role:       role,
+		TxId:       tx.TxHash().String(),
+		TxHex:      hex.EncodeToString(buf.Bytes()),
+		Size:       buf.Len(),
+		InputValue: inputValue,
+	}
+	for i, out := range tx.TxOut {
+		report.OutputValue += out.Value
+		switch {
+		case i == changeIndex:
+			report.Change = out.Value
+		case i < lockedCount:
+			report.LockedValue += out.Value
+		case i < lockedCount+extraCount:
+			report.ExtraValue += out.Value
+		}
+	}
+	report.Fee = report.InputValue - report.OutputValue
+
+	r.Txs = append(r.Txs, tx)
+	r.Details = append(r.Details, report)
+	if len(r.Txs) == 1 {
+		r.CommitTxId = report.TxId
+		r.CommitTxHex = report.TxHex
+	} else {
+		r.RevealTxIds = append(r.RevealTxIds, report.TxId)
+		r.RevealTxsHex = append(r.RevealTxsHex, report.TxHex)
+	}
+	if role == DogeTxRoleCommit {
+		r.CommitCost += report.Fee
+	} else {
+		r.RevealCost += report.Fee
+	}
+	r.ServiceCost += report.ExtraValue
+	r.TotalCost = r.CommitCost + r.RevealCost + r.ServiceCost
+	return nil
+}
+
+// MergeDogeInscriptionResults 合并多个交易链的报告（例如BuildDogeMetaIdPinBatch的每个PIN），
+// 交易按传入顺序排列，第一笔交易作为commitTxId，其余全部放入revealTxIds
+func MergeDogeInscriptionResults(results []*DogeInscriptionResult) *DogeInscriptionResult {
+	merged := newDogeInscriptionResult()
+	for _, result := range results {
+		if result == nil {
+			continue
+		}
+		for i, tx := range result.Txs {
+			report := result.Details[i]
+			merged.Txs = append(merged.Txs, tx)
+			merged.Details = append(merged.Details, report)
+			if len(merged.Txs) == 1 {
+				merged.CommitTxId = report.TxId
+				merged.CommitTxHex = report.TxHex
+			} else {
+				merged.RevealTxIds = append(merged.RevealTxIds, report.TxId)
+				merged.RevealTxsHex = append(merged.RevealTxsHex, report.TxHex)
+			}
+		}
+		merged.CommitCost += result.CommitCost
+		merged.RevealCost += result.RevealCost
+		merged.ServiceCost += result.ServiceCost
+	}
+	merged.TotalCost = merged.CommitCost + merged.RevealCost + merged.ServiceCost
+	return merged
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestDogeInscriptionResult(t *testing.T) {
+	_, service := testDogeKey(t, 2)
+	for _, position := range []DogeExtraOutputsPosition{DogeExtraOutputsOnReveal, DogeExtraOutputsOnCommit} {
+		sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+		builder, err := NewInscriptionBuilder(
+			WithMetaIdPin(&DogeMetaIdPin{Path: "/x", Body: bytes.Repeat([]byte("a"), 5000)}),
+			WithUtxos(utxos),
+			WithChangeAddress(address),
+			WithFeeRate(1000),
+			WithExtraOutputs(&DogeExtraOutputs{Service: &TxOutput{Address: service, Amount: 1e7}, Position: position}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := builder.Build(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		testSubmitAndMine(t, sim, result.Txs...)
+		if len(result.Details) != len(result.Txs) || len(result.Txs) < 3 {
+			t.Fatalf("%d笔交易, %d条明细", len(result.Txs), len(result.Details))
+		}
+
+		var commitCost, revealCost, serviceCost int64
+		for i, detail := range result.Details {
+			role := DogeTxRolePartial
+			switch i {
+			case 0:
+				role = DogeTxRoleCommit
+			case len(result.Details) - 1:
+				role = DogeTxRoleReveal
+			}
+			if detail.Role != role {
+				t.Errorf("交易%d的角色%s, 期望%s", i, detail.Role, role)
+			}
+			if detail.TxId != result.Txs[i].TxHash().String() || detail.TxHex != testTxHex(t, result.Txs[i]) || detail.Size != len(detail.TxHex)/2 {
+				t.Errorf("交易%d的txid、原始数据或大小不一致", i)
+			}
+			// 手续费与模拟账本计算的一致
+			if fee, err := sim.TxFee(detail.TxId); err != nil || fee != detail.Fee || detail.Fee != detail.InputValue-detail.OutputValue {
+				t.Errorf("交易%d的手续费%d, 账本%d, %v", i, detail.Fee, fee, err)
+			}
+			if detail.Fee < int64(detail.Size)*1000 {
+				t.Errorf("交易%d的手续费%d低于费率", i, detail.Fee)
+			}
+			if detail.LockedValue != 100000 {
+				t.Errorf("交易%d锁定金额%d", i, detail.LockedValue)
+			}
+			if detail.OutputValue != detail.LockedValue+detail.ExtraValue+detail.Change {
+				t.Errorf("交易%d的输出总额与明细不一致", i)
+			}
+			if i == 0 {
+				commitCost += detail.Fee
+			} else {
+				revealCost += detail.Fee
+			}
+			serviceCost += detail.ExtraValue
+		}
+		extraIndex := len(result.Details) - 1
+		if position == DogeExtraOutputsOnCommit {
+			extraIndex = 0
+		}
+		if result.Details[extraIndex].ExtraValue != 1e7 {
+			t.Errorf("position %d: 服务费不在第%d笔交易上", position, extraIndex)
+		}
+		if result.CommitCost != commitCost || result.RevealCost != revealCost || result.ServiceCost != 1e7 ||
+			serviceCost != 1e7 || result.TotalCost != commitCost+revealCost+1e7 {
+			t.Errorf("position %d: commit %d reveal %d service %d total %d", position, result.CommitCost, result.RevealCost, result.ServiceCost, result.TotalCost)
+		}
+
+		// JSON与createPin的CreatePinResult一致
+		var createPinResult struct {
+			CommitTxId   string   `json:"commitTxId"`
+			RevealTxIds  []string `json:"revealTxIds"`
+			CommitTxHex  string   `json:"commitTxHex"`
+			RevealTxsHex []string `json:"revealTxsHex"`
+			CommitCost   int64    `json:"commitCost"`
+			RevealCost   int64    `json:"revealCost"`
+			TotalCost    int64    `json:"totalCost"`
+		}
+		content, _ := json.Marshal(result)
+		if err := json.Unmarshal(content, &createPinResult); err != nil {
+			t.Fatal(err)
+		}
+		if createPinResult.CommitTxId != result.Details[0].TxId || createPinResult.CommitTxHex != result.Details[0].TxHex ||
+			len(createPinResult.RevealTxIds) != len(result.Txs)-1 || len(createPinResult.RevealTxsHex) != len(result.Txs)-1 ||
+			createPinResult.RevealTxIds[len(createPinResult.RevealTxIds)-1] != result.Details[len(result.Details)-1].TxId ||
+			createPinResult.TotalCost != result.TotalCost {
+			t.Errorf("JSON: %s", content[:200])
+		}
+	}
+}
+
+func TestMergeDogeInscriptionResults(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	chains, err := BuildDogeMetaIdPinBatch(DogeMainNetParams, []*DogePinDetail{
+		{Pin: &DogeMetaIdPin{Path: "/a", Body: bytes.Repeat([]byte("a"), 2000)}},
+		{Pin: &DogeMetaIdPin{Path: "/b", Body: []byte("b")}},
+	}, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := chains[0].Result, chains[1].Result
+	merged := MergeDogeInscriptionResults([]*DogeInscriptionResult{first, nil, second})
+
+	txCount := len(first.Txs) + len(second.Txs)
+	if len(merged.Txs) != txCount || len(merged.Details) != txCount || len(merged.RevealTxIds) != txCount-1 {
+		t.Fatalf("合并后%d笔交易, %d个reveal txid", len(merged.Txs), len(merged.RevealTxIds))
+	}
+	if merged.CommitTxId != first.CommitTxId || merged.RevealTxIds[len(first.Txs)-1] != second.CommitTxId ||
+		merged.RevealTxIds[txCount-2] != chains[1].RevealTxId {
+		t.Error("合并后的txid顺序不正确")
+	}
+	if merged.CommitCost != first.CommitCost+second.CommitCost || merged.RevealCost != first.RevealCost+second.RevealCost ||
+		merged.TotalCost != first.TotalCost+second.TotalCost {
+		t.Errorf("合并后的费用: %+v", merged)
+	}
+
+	// 空报告的列表序列化为[]
+	content, _ := json.Marshal(MergeDogeInscriptionResults(nil))
+	var empty map[string]json.RawMessage
+	if err := json.Unmarshal(content, &empty); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"revealTxIds", "revealTxsHex", "details"} {
+		if string(empty[key]) != "[]" {
+			t.Errorf("%s: %s", key, empty[key])
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DogeBuildProgress 交易链的构建进度，每构建完一笔交易报告一次
+type DogeBuildProgress struct {
+	TxIndex        int    // 刚构建的交易序号，从0开始
+	TxCount        int    // 交易总数，有reveal交易时为partial数量+1
+	PartialIndex   int    // 这笔交易写入unlock脚本的partial序号，commit交易没有写入数据，为-1
+	PartialCount   int    // partial总数
+	BytesInscribed int    // 已经写入unlock脚本的inscription字节数
+	TotalBytes     int    // inscription脚本的总字节数
+	TxId           string // 刚构建的交易
+	Role           string // 刚构建的交易的角色
+}
+
+// DogeInscriptionSession 一条inscription交易链的构建状态
+// 构建被ctx取消时通过ErrBuildCancelled返回，Result中是已经构建好的交易（可以先广播），
+// 调用Resume从下一笔交易继续构建，得到的交易链与不中断时相同。
+// session中保存了P2SH临时私钥，只在内存中使用，打印和日志中不包含私钥
+type DogeInscriptionSession struct {
+	cfg            dogeChainConfig
+	privateKey     *btcec.PrivateKey
+	publicKeyBytes []byte
+	partials       [][]byte
+	extraTxOuts    []*wire.TxOut
+	next           int  // 下一个要锁定的partial
+	done           bool // reveal交易已经构建，或者不需要reveal交易
+	result         *DogeInscriptionResult
+	availableUtxos []*TxInputUtxo // 用于跟踪可用的UTXO（模拟JavaScript中的wallet.utxos）
+	p2shInput      *wire.TxIn     // 花费上一笔交易P2SH输出的输入
+	lastLock       []byte
+	lastPartial    []byte
+	bytesInscribed int
+	totalBytes     int
+}
+
+// newDogeInscriptionSession 校验额外输出并拆分inscription脚本，创建还没有构建任何交易的session
+func newDogeInscriptionSession(
+	cfg *dogeChainConfig,
+	privateKey *btcec.PrivateKey,
+	inscriptionScript []byte,
+	ins []*TxInputUtxo,
+) (*DogeInscriptionSession, error) {
+	// 校验并解析额外输出
+	extraTxOuts, err := cfg.extra.txOuts(cfg.netParam)
+	if err != nil {
+		return nil, err
+	}
+	if len(extraTxOuts) > 0 && cfg.extra.Position == DogeExtraOutputsOnReveal && cfg.outputAddress == "" {
+		return nil, fmt.Errorf("%w: 额外输出需要加在reveal交易上，但没有指定reveal接收地址", ErrInvalidOption)
+	}
+
+	// ===== 第三步：处理inscription脚本分块 =====
+	// 对应JavaScript中的while (inscription.chunks.length)循环
+	// 按chunk边界拆分，保证每个partial都是完整的push序列
+	partials, err := splitInscriptionPartials(inscriptionScript, cfg.maxPayloadLen)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 拆分inscription脚本失败: %v", ErrInvalidOption, err)
+	}
+
+	// reveal输入和输出只在inscription完整地写在reveal交易中时有意义（例如MRC-20），
+	// 否则索引器不会把它们与PIN关联
+	if len(cfg.revealInputs) > 0 || len(cfg.revealOutputs) > 0 {
+		if cfg.outputAddress == "" {
+			return nil, fmt.Errorf("%w: reveal输入和输出需要reveal交易，但没有指定reveal接收地址", ErrInvalidOption)
+		}
+		if len(partials) != 1 {
+			return nil, fmt.Errorf("%w: 使用reveal输入和输出时inscription必须在一个partial中，实际为%d个", ErrInvalidOption, len(partials))
+		}
+	}
+
+	// reveal输入由reveal交易花费，不能再用于支付手续费
+	revealOutpoints := make(map[string]bool, len(cfg.revealInputs))
+	for _, utxo := range cfg.revealInputs {
+		revealOutpoints[fmt.Sprintf("%s:%d", utxo.TxId, utxo.TxIndex)] = true
+	}
+	availableUtxos := make([]*TxInputUtxo, 0, len(ins))
+	for _, utxo := range ins {
+		if !revealOutpoints[fmt.Sprintf("%s:%d", utxo.TxId, utxo.TxIndex)] {
+			availableUtxos = append(availableUtxos, utxo)
+		}
+	}
+
+	return &DogeInscriptionSession{
+		cfg:            *cfg,
+		privateKey:     privateKey,
+		publicKeyBytes: privateKey.PubKey().SerializeCompressed(),
+		partials:       partials,
+		extraTxOuts:    extraTxOuts,
+		result:         newDogeInscriptionResult(),
+		availableUtxos: availableUtxos,
+		totalBytes:     len(inscriptionScript),
+	}, nil
+}
+
+// hasReveal 是否需要构建reveal交易
+func (s *DogeInscriptionSession) hasReveal() bool {
+	return s.cfg.outputAddress != "" && len(s.partials) > 0
+}
+
+// run 从当前状态构建剩余的交易，每笔交易之前检查ctx
+func (s *DogeInscriptionSession) run(ctx context.Context) error {
+	for s.next < len(s.partials) {
+		if err := ctx.Err(); err != nil {
+			return &ErrBuildCancelled{Session: s, Err: err}
+		}
+		if err := s.buildPartialTx(ctx, s.partials[s.next]); err != nil {
+			return s.stepError(ctx, err)
+		}
+		s.next++
+		s.reportProgress()
+	}
+	if !s.done && s.hasReveal() {
+		if err := ctx.Err(); err != nil {
+			return &ErrBuildCancelled{Session: s, Err: err}
+		}
+		if err := s.buildRevealTx(ctx); err != nil {
+			return s.stepError(ctx, err)
+		}
+		s.reportProgress()
+	}
+	s.done = true
+	return nil
+}
+
+// stepError 构建一笔交易失败时，如果是ctx被取消导致的（例如签名器响应了取消），返回可以继续的session
+func (s *DogeInscriptionSession) stepError(ctx context.Context, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+	if !errors.Is(err, ctx.Err()) {
+		err = fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return &ErrBuildCancelled{Session: s, Err: err}
+}
+
+// reportProgress 报告刚构建的交易
+func (s *DogeInscriptionSession) reportProgress() {
+	if s.cfg.progress == nil {
+		return
+	}
+	txIndex := len(s.result.Details) - 1
+	detail := s.result.Details[txIndex]
+	txCount := len(s.partials)
+	if s.hasReveal() {
+		txCount++
+	}
+	s.cfg.progress(DogeBuildProgress{
+		TxIndex:        txIndex,
+		TxCount:        txCount,
+		PartialIndex:   txIndex - 1,
+		PartialCount:   len(s.partials),
+		BytesInscribed: s.bytesInscribed,
+		TotalBytes:     s.totalBytes,
+		TxId:           detail.TxId,
+		Role:           detail.Role,
+	})
+}
+
+// Resume 从中断的位置继续构建，返回完整交易链的报告（包含中断前构建的交易）
+// 再次被取消时同样返回ErrBuildCancelled
+func (s *DogeInscriptionSession) Resume(ctx context.Context) (*DogeInscriptionResult, error) {
+	if err := s.run(ctx); err != nil {
+		return nil, err
+	}
+	return s.result, nil
+}
+
+// Result 已经构建好的交易，可以在继续构建之前按顺序广播
+func (s *DogeInscriptionSession) Result() *DogeInscriptionResult {
+	return s.result
+}
+
+// Done 交易链是否已经全部构建
+func (s *DogeInscriptionSession) Done() bool {
+	return s.done
+}
+
+// String 输出构建进度，不包含私钥
+func (s *DogeInscriptionSession) String() string {
+	return fmt.Sprintf("{Txs:%d Partials:%d/%d BytesInscribed:%d/%d Done:%t}",
+		len(s.result.Txs), s.next, len(s.partials), s.bytesInscribed, s.totalBytes, s.done)
+}
+
+// LogValue 实现slog.LogValuer，不包含私钥
+func (s *DogeInscriptionSession) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("txs", len(s.result.Txs)),
+		slog.Int("partials", len(s.partials)),
+		slog.Int("next", s.next),
+		slog.Int("bytesInscribed", s.bytesInscribed),
+		slog.Int("totalBytes", s.totalBytes),
+		slog.Bool("done", s.done),
+	)
+}
+
+// ErrBuildCancelled 构建被ctx取消，errors.Is(err, context.Canceled)成立，
+// 用errors.As获取后调用Session.Resume继续构建
+type ErrBuildCancelled struct {
+	Session *DogeInscriptionSession
+	Err     error // ctx.Err()或签名器返回的错误
+}
+
+func (e *ErrBuildCancelled) Error() string {
+	return fmt.Sprintf("构建在第%d笔交易前中断: %v", len(e.Session.result.Txs)+1, e.Err)
+}
+
+func (e *ErrBuildCancelled) Unwrap() error {
+	return e.Err
+}
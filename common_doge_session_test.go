@@ -0,0 +1,198 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testSessionBuilder 用固定的临时密钥铸造6000字节的Doginal，两次构建得到相同的交易链
+func testSessionBuilder(t *testing.T, utxos []*TxInputUtxo, address string, opts ...InscriptionOption) *InscriptionBuilder {
+	key := testPrivateKey(7)
+	builder, err := NewInscriptionBuilder(append([]InscriptionOption{
+		WithDoginal(bytes.Repeat([]byte{0x42}, 6000), "text/plain"),
+		WithUtxos(utxos),
+		WithChangeAddress(address),
+		WithFeeRate(1000),
+		WithKeySource(func() (*btcec.PrivateKey, error) { return key, nil }),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return builder
+}
+
+func TestDogeInscriptionSessionResume(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	full, err := testSessionBuilder(t, utxos, address).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var progress []DogeBuildProgress
+	_, err = testSessionBuilder(t, utxos, address, WithProgress(func(p DogeBuildProgress) {
+		progress = append(progress, p)
+		if p.TxIndex == 2 {
+			cancel()
+		}
+	})).Build(ctx)
+	var cancelled *ErrBuildCancelled
+	if !errors.As(err, &cancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("取消构建: %v", err)
+	}
+	session := cancelled.Session
+	if len(session.Result().Txs) != 3 || session.Done() {
+		t.Fatalf("中断后的session: %v", session)
+	}
+	// 中断前的交易可以先广播
+	testSubmitAndMine(t, sim, session.Result().Txs...)
+
+	// 仍然是已取消的ctx，再次返回ErrBuildCancelled，session不变
+	if _, err := session.Resume(ctx); !errors.As(err, &cancelled) || len(session.Result().Txs) != 3 {
+		t.Fatalf("用已取消的ctx继续: %v", err)
+	}
+
+	result, err := session.Resume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !session.Done() || len(result.Txs) != len(full.Txs) {
+		t.Fatalf("继续构建得到%d笔交易, 不中断时%d笔", len(result.Txs), len(full.Txs))
+	}
+	for i := range result.Txs {
+		if result.Txs[i].TxHash() != full.Txs[i].TxHash() {
+			t.Errorf("交易%d与不中断时不同", i)
+		}
+	}
+	testSubmitAndMine(t, sim, result.Txs[3:]...)
+
+	if len(progress) != len(full.Txs) {
+		t.Fatalf("%d次进度回调, %d笔交易", len(progress), len(full.Txs))
+	}
+	for i, p := range progress {
+		if p.TxIndex != i || p.TxCount != len(full.Txs) || p.PartialIndex != i-1 || p.PartialCount != len(full.Txs)-1 ||
+			p.TotalBytes != progress[0].TotalBytes || p.TxId != full.Details[i].TxId || p.Role != full.Details[i].Role {
+			t.Errorf("进度%d: %+v", i, p)
+		}
+		if i > 0 && p.BytesInscribed <= progress[i-1].BytesInscribed {
+			t.Errorf("进度%d: 已写入%d字节, 上一次%d字节", i, p.BytesInscribed, progress[i-1].BytesInscribed)
+		}
+	}
+	if progress[0].BytesInscribed != 0 || progress[len(progress)-1].BytesInscribed != progress[0].TotalBytes {
+		t.Errorf("进度: %+v ... %+v", progress[0], progress[len(progress)-1])
+	}
+}
+
+// TestDogeInscriptionSessionSignerCancel 签名器响应取消时同样返回可以继续的session，失败的交易在继续时重新构建
+func TestDogeInscriptionSessionSignerCancel(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	full, err := testSessionBuilder(t, utxos, address).Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	signer := DogeSignerFunc(func(ctx context.Context, tx *wire.MsgTx, signUtxos []*TxInputUtxo, startIndex int) error {
+		calls++
+		if calls == 2 {
+			cancel()
+			return fmt.Errorf("签名服务: %w", ctx.Err())
+		}
+		return DogePriHexSigner.SignInputs(ctx, tx, signUtxos, startIndex)
+	})
+	_, err = testSessionBuilder(t, utxos, address, WithSigner(signer)).Build(ctx)
+	var cancelled *ErrBuildCancelled
+	if !errors.As(err, &cancelled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("签名器取消: %v", err)
+	}
+	built := len(cancelled.Session.Result().Txs)
+	if built == 0 || built >= len(full.Txs) {
+		t.Fatalf("中断前构建了%d笔交易", built)
+	}
+	result, err := cancelled.Session.Resume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range full.Txs {
+		if result.Txs[i].TxHash() != full.Txs[i].TxHash() {
+			t.Errorf("交易%d与不中断时不同", i)
+		}
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+
+	// 没有取消时签名器的错误直接返回
+	failing := DogeSignerFunc(func(context.Context, *wire.MsgTx, []*TxInputUtxo, int) error {
+		return errors.New("签名服务不可用")
+	})
+	_, err = testSessionBuilder(t, utxos, address, WithSigner(failing)).Build(context.Background())
+	if err == nil || errors.As(err, &cancelled) {
+		t.Errorf("签名失败: %v", err)
+	}
+}
+
+// TestDogeInscriptionSessionRedacted session保存了临时私钥，打印、日志和错误信息中不能包含
+func TestDogeInscriptionSessionRedacted(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Build之前已经取消时直接返回ctx的错误，没有session
+	_, err := testSessionBuilder(t, utxos, address).Build(ctx)
+	if !errors.Is(err, context.Canceled) || errors.As(err, new(*ErrBuildCancelled)) {
+		t.Fatalf("Build之前取消: %v", err)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	_, err = testSessionBuilder(t, utxos, address, WithProgress(func(DogeBuildProgress) { cancel() })).Build(ctx)
+	var cancelled *ErrBuildCancelled
+	if !errors.As(err, &cancelled) {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	slog.New(slog.NewTextHandler(&out, nil)).Info("session", "session", cancelled.Session)
+	if !strings.Contains(out.String(), "session.txs=1") {
+		t.Errorf("日志没有使用LogValue: %s", out.String())
+	}
+	keyHex := hex.EncodeToString(testPrivateKey(7).Serialize())
+	for _, text := range []string{cancelled.Session.String(), fmt.Sprintf("%v", cancelled.Session), out.String(), err.Error()} {
+		if strings.Contains(text, keyHex) {
+			t.Errorf("session的输出: %s", text)
+		}
+	}
+}
+
+// TestDogeInscriptionSessionInvalidOption 创建session时的参数错误都是ErrInvalidOption
+func TestDogeInscriptionSessionInvalidOption(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	extra := &DogeExtraOutputs{Service: &TxOutput{Address: address, Amount: 1e6}}
+	bigScript, err := buildDogeInscriptionScript(bytes.Repeat([]byte{0x42}, 6000), "text/plain", InscriptionFormatDoginal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withRevealInputs := newDogeChainConfig(DogeMainNetParams, address, 0, address, 1000, nil)
+	withRevealInputs.revealInputs = utxos[:1]
+	for name, c := range map[string]struct {
+		cfg    *dogeChainConfig
+		script []byte
+	}{
+		"额外输出没有reveal接收地址":    {newDogeChainConfig(DogeMainNetParams, "", 0, address, 1000, extra), []byte{0x01, 0x42}},
+		"inscription脚本被截断":    {newDogeChainConfig(DogeMainNetParams, address, 0, address, 1000, nil), []byte{txscript.OP_PUSHDATA1, 0x10}},
+		"reveal输入需要一个partial": {withRevealInputs, bigScript},
+	} {
+		if _, err := newDogeInscriptionSession(c.cfg, testPrivateKey(7), c.script, utxos); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
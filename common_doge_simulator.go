@@ -0,0 +1,526 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DogePolicy Dogecoin节点的标准交易策略（对应Dogecoin Core 1.14的policy.h）
+type DogePolicy struct {
+	MinRelayFeeRate   int64 // 最低转发费率 satoshis/KB
+	DustLimit         int64 // 输出金额下限（硬dust限制）
+	MaxScriptSigSize  int   // 单个输入签名脚本的最大长度
+	MaxStandardTxSize int   // 标准交易的最大长度
+
+	// 内存池交易链限制（-limitancestorcount等），数量和大小都包含交易本身
+	MaxAncestorCount   int // 未确认祖先数量上限
+	MaxAncestorSize    int // 未确认祖先的总大小上限
+	MaxDescendantCount int // 每个未确认祖先的后代数量上限
+	MaxDescendantSize  int // 每个未确认祖先的后代总大小上限
+}
+
+// DefaultDogePolicy Dogecoin Core 1.14的默认策略
+var DefaultDogePolicy = DogePolicy{
+	MinRelayFeeRate:   100000, // 0.001 DOGE/KB
+	DustLimit:         DogeDustLimit,
+	MaxScriptSigSize:  1650,   // MAX_STANDARD_SCRIPTSIG_SIZE
+	MaxStandardTxSize: 100000, // MAX_STANDARD_TX_SIZE
+
+	MaxAncestorCount:   25,     // DEFAULT_ANCESTOR_LIMIT
+	MaxAncestorSize:    101000, // DEFAULT_ANCESTOR_SIZE_LIMIT 101kB
+	MaxDescendantCount: 25,     // DEFAULT_DESCENDANT_LIMIT
+	MaxDescendantSize:  101000, // DEFAULT_DESCENDANT_SIZE_LIMIT 101kB
+}
+
+const (
+	// dogeMaxMoney Dogecoin的MAX_MONEY（100亿DOGE）
+	dogeMaxMoney int64 = 10000000000 * 1e8
+	// dogeGenesisTime Dogecoin创世区块时间，用于模拟区块时间
+	dogeGenesisTime int64 = 1386325540
+	// lockTimeThreshold 小于该值的LockTime表示区块高度，否则表示时间戳
+	lockTimeThreshold uint32 = 500000000
+)
+
+// DogeStandardVerifyFlags Dogecoin Core 1.14的STANDARD_SCRIPT_VERIFY_FLAGS
+// Dogecoin没有SegWit和Taproot，所以不包含见证相关的标志
+const DogeStandardVerifyFlags = txscript.ScriptBip16 |
+	txscript.ScriptVerifyDERSignatures |
+	txscript.ScriptVerifyStrictEncoding |
+	txscript.ScriptVerifyMinimalData |
+	txscript.ScriptStrictMultiSig |
+	txscript.ScriptDiscourageUpgradableNops |
+	txscript.ScriptVerifyCleanStack |
+	txscript.ScriptVerifyCheckLockTimeVerify |
+	txscript.ScriptVerifyCheckSequenceVerify |
+	txscript.ScriptVerifyLowS
+
+// DogeSimulator 进程内的模拟账本，用于离线测试交易链
+// 维护UTXO集合（包含内存池中的输出），接收交易时使用txscript验证脚本并检查标准策略和内存池交易链限制，
+// 调用MineBlock时将内存池中的交易打包确认
+// 同时实现了UtxoProvider接口，可以直接作为选币的数据来源
+type DogeSimulator struct {
+	NetParam *chaincfg.Params
+	Policy   DogePolicy
+
+	mu      sync.Mutex
+	height  int64
+	utxos   map[wire.OutPoint]*simUtxo
+	txs     map[chainhash.Hash]*simTx
+	mempool []chainhash.Hash
+	pending *dogeMempoolChain // 内存池中的交易链，打包后清空
+	fundSeq uint64
+}
+
+type simUtxo struct {
+	out    *wire.TxOut
+	height int64 // 0表示在内存池中
+}
+
+type simTx struct {
+	tx     *wire.MsgTx
+	fee    int64
+	height int64 // 0表示在内存池中
+}
+
+// dogeMempoolChain 内存池中未确认的交易及其祖先，用于检查交易链限制（对应CalculateMemPoolAncestors）
+type dogeMempoolChain struct {
+	policy  DogePolicy
+	entries map[chainhash.Hash]*dogeMempoolEntry
+}
+
+type dogeMempoolEntry struct {
+	size      int
+	ancestors map[chainhash.Hash]struct{} // 不包含自身
+}
+
+func newDogeMempoolChain(policy DogePolicy) *dogeMempoolChain {
+	return &dogeMempoolChain{policy: policy, entries: make(map[chainhash.Hash]*dogeMempoolEntry)}
+}
+
+// check 计算交易的未确认祖先，检查祖先和每个祖先的后代数量与大小，数量和大小都包含交易本身
+func (c *dogeMempoolChain) check(tx *wire.MsgTx) (map[chainhash.Hash]struct{}, error) {
+	ancestors := make(map[chainhash.Hash]struct{})
+	for _, in := range tx.TxIn {
+		parent, ok := c.entries[in.PreviousOutPoint.Hash]
+		if !ok {
+			continue
+		}
+		ancestors[in.PreviousOutPoint.Hash] = struct{}{}
+		for ancestor := range parent.ancestors {
+			ancestors[ancestor] = struct{}{}
+		}
+	}
+
+	txSize := tx.SerializeSize()
+	if count := len(ancestors) + 1; count > c.policy.MaxAncestorCount {
+		return nil, fmt.Errorf("未确认的祖先过多: %d > %d", count, c.policy.MaxAncestorCount)
+	}
+	ancestorSize := txSize
+	for ancestor := range ancestors {
+		ancestorSize += c.entries[ancestor].size
+	}
+	if ancestorSize > c.policy.MaxAncestorSize {
+		return nil, fmt.Errorf("未确认祖先的总大小超出限制: %d > %d", ancestorSize, c.policy.MaxAncestorSize)
+	}
+	for ancestor := range ancestors {
+		count, size := 2, c.entries[ancestor].size+txSize
+		for _, entry := range c.entries {
+			if _, ok := entry.ancestors[ancestor]; ok {
+				count++
+				size += entry.size
+			}
+		}
+		if count > c.policy.MaxDescendantCount {
+			return nil, fmt.Errorf("未确认交易%s的后代过多: %d > %d", ancestor, count, c.policy.MaxDescendantCount)
+		}
+		if size > c.policy.MaxDescendantSize {
+			return nil, fmt.Errorf("未确认交易%s的后代总大小超出限制: %d > %d", ancestor, size, c.policy.MaxDescendantSize)
+		}
+	}
+	return ancestors, nil
+}
+
+// add 把通过check的交易加入内存池
+func (c *dogeMempoolChain) add(tx *wire.MsgTx, ancestors map[chainhash.Hash]struct{}) {
+	c.entries[tx.TxHash()] = &dogeMempoolEntry{size: tx.SerializeSize(), ancestors: ancestors}
+}
+
+// NewDogeSimulator 创建模拟账本，初始高度为1
+func NewDogeSimulator(netParam *chaincfg.Params) *DogeSimulator {
+	return &DogeSimulator{
+		NetParam: netParam,
+		Policy:   DefaultDogePolicy,
+		height:   1,
+		utxos:    make(map[wire.OutPoint]*simUtxo),
+		txs:      make(map[chainhash.Hash]*simTx),
+	}
+}
+
+// Height 当前最新区块高度
+func (s *DogeSimulator) Height() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.height
+}
+
+// chain 内存池中的交易链，Policy在创建后仍然可以修改，所以在第一次使用时创建
+func (s *DogeSimulator) chain() *dogeMempoolChain {
+	if s.pending == nil {
+		s.pending = newDogeMempoolChain(s.Policy)
+	}
+	return s.pending
+}
+
+// blockTime 模拟的区块时间，每个区块1分钟
+func (s *DogeSimulator) blockTime(height int64) int64 {
+	return dogeGenesisTime + height*60
+}
+
+// Fund 直接给地址创建一个已确认的UTXO
+// 资金交易没有真实输入，不经过验证，其txid由内部计数器决定，所以结果是确定的
+func (s *DogeSimulator) Fund(address string, amount int64) (*TxInputUtxo, error) {
+	pkScriptHex, err := addressPkScriptHex(s.NetParam, address)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, _ := hex.DecodeString(pkScriptHex)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fundSeq++
+	var seed chainhash.Hash
+	binary.LittleEndian.PutUint64(seed[:], s.fundSeq)
+
+	tx := wire.NewMsgTx(1)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(&seed, 0), nil, nil))
+	tx.AddTxOut(wire.NewTxOut(amount, pkScript))
+	txHash := tx.TxHash()
+
+	s.txs[txHash] = &simTx{tx: tx, height: s.height}
+	s.utxos[*wire.NewOutPoint(&txHash, 0)] = &simUtxo{out: tx.TxOut[0], height: s.height}
+
+	return &TxInputUtxo{
+		TxId:     txHash.String(),
+		TxIndex:  0,
+		PkScript: pkScriptHex,
+		Amount:   uint64(amount),
+		SignMode: SignModeLegacy,
+	}, nil
+}
+
+// SubmitRawTx 提交十六进制格式的原始交易
+func (s *DogeSimulator) SubmitRawTx(txRaw string) (*chainhash.Hash, error) {
+	txBytes, err := hex.DecodeString(txRaw)
+	if err != nil {
+		return nil, fmt.Errorf("解码交易失败: %w", err)
+	}
+	var tx wire.MsgTx
+	if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+		return nil, fmt.Errorf("反序列化交易失败: %w", err)
+	}
+	return s.SubmitTx(&tx)
+}
+
+// SubmitTxs 按顺序提交交易链，返回第一个失败的交易
+func (s *DogeSimulator) SubmitTxs(txs []*wire.MsgTx) error {
+	for i, tx := range txs {
+		if _, err := s.SubmitTx(tx); err != nil {
+			return fmt.Errorf("交易 %d 被拒绝: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// SubmitTx 验证交易并加入内存池
+// 输入可以花费已确认或内存池中的输出
+func (s *DogeSimulator) SubmitTx(tx *wire.MsgTx) (*chainhash.Hash, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	txHash := tx.TxHash()
+	if _, ok := s.txs[txHash]; ok {
+		return nil, fmt.Errorf("交易已存在: %s", txHash)
+	}
+
+	if err := checkDogeTxSanity(tx); err != nil {
+		return nil, err
+	}
+	if err := s.checkFinal(tx); err != nil {
+		return nil, err
+	}
+
+	// 查找所有输入对应的输出
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	totalIn := int64(0)
+	for i, in := range tx.TxIn {
+		prev, ok := s.utxos[in.PreviousOutPoint]
+		if !ok {
+			return nil, fmt.Errorf("输入 %d 引用的输出不存在或已花费: %s", i, in.PreviousOutPoint)
+		}
+		prevOutFetcher.AddPrevOut(in.PreviousOutPoint, prev.out)
+		totalIn += prev.out.Value
+	}
+	totalOut := int64(0)
+	for _, out := range tx.TxOut {
+		totalOut += out.Value
+	}
+	if totalIn < totalOut {
+		return nil, &ErrInsufficientFunds{Needed: totalOut, Available: totalIn}
+	}
+	fee := totalIn - totalOut
+
+	if err := s.checkStandard(tx, fee); err != nil {
+		return nil, err
+	}
+	ancestors, err := s.chain().check(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 验证每个输入的脚本
+	sigHashes := txscript.NewTxSigHashes(tx, prevOutFetcher)
+	for i, in := range tx.TxIn {
+		prevOut := prevOutFetcher.FetchPrevOutput(in.PreviousOutPoint)
+		vm, err := txscript.NewEngine(prevOut.PkScript, tx, i, DogeStandardVerifyFlags,
+			nil, sigHashes, prevOut.Value, prevOutFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("输入 %d 创建脚本引擎失败: %w", i, err)
+		}
+		if err := vm.Execute(); err != nil {
+			return nil, fmt.Errorf("输入 %d 脚本验证失败: %w", i, err)
+		}
+	}
+
+	// 更新UTXO集合
+	for _, in := range tx.TxIn {
+		delete(s.utxos, in.PreviousOutPoint)
+	}
+	for i, out := range tx.TxOut {
+		if txscript.GetScriptClass(out.PkScript) == txscript.NullDataTy {
+			continue
+		}
+		s.utxos[*wire.NewOutPoint(&txHash, uint32(i))] = &simUtxo{out: out}
+	}
+	s.txs[txHash] = &simTx{tx: tx, fee: fee}
+	s.mempool = append(s.mempool, txHash)
+	s.chain().add(tx, ancestors)
+
+	return &txHash, nil
+}
+
+// MineBlock 打包内存池中的所有交易，区块高度加1，返回被确认的交易
+func (s *DogeSimulator) MineBlock() []chainhash.Hash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.height++
+	mined := s.mempool
+	s.mempool = nil
+	s.pending = nil
+	for _, txHash := range mined {
+		simTx := s.txs[txHash]
+		simTx.height = s.height
+		for i := range simTx.tx.TxOut {
+			if utxo, ok := s.utxos[*wire.NewOutPoint(&txHash, uint32(i))]; ok {
+				utxo.height = s.height
+			}
+		}
+	}
+	return mined
+}
+
+// MempoolSize 内存池中的交易数量
+func (s *DogeSimulator) MempoolSize() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.mempool)
+}
+
+// TxFee 返回已接收交易的手续费
+func (s *DogeSimulator) TxFee(txId string) (int64, error) {
+	hash, err := chainhash.NewHashFromStr(txId)
+	if err != nil {
+		return 0, fmt.Errorf("解析TxId失败: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	simTx, ok := s.txs[*hash]
+	if !ok {
+		return 0, fmt.Errorf("交易不存在: %s", txId)
+	}
+	return simTx.fee, nil
+}
+
+// checkDogeTxSanity 与共识相关的基本检查（对应CheckTransaction）
+func checkDogeTxSanity(tx *wire.MsgTx) error {
+	if len(tx.TxIn) == 0 {
+		return fmt.Errorf("交易没有输入")
+	}
+	if len(tx.TxOut) == 0 {
+		return fmt.Errorf("交易没有输出")
+	}
+	totalOut := int64(0)
+	for i, out := range tx.TxOut {
+		if out.Value < 0 || out.Value > dogeMaxMoney {
+			return fmt.Errorf("输出 %d 金额无效: %d", i, out.Value)
+		}
+		totalOut += out.Value
+		if totalOut > dogeMaxMoney {
+			return fmt.Errorf("输出总金额超出范围: %d", totalOut)
+		}
+	}
+	seen := make(map[wire.OutPoint]struct{}, len(tx.TxIn))
+	for i, in := range tx.TxIn {
+		if _, ok := seen[in.PreviousOutPoint]; ok {
+			return fmt.Errorf("输入 %d 重复花费: %s", i, in.PreviousOutPoint)
+		}
+		seen[in.PreviousOutPoint] = struct{}{}
+	}
+	return nil
+}
+
+// checkFinal 检查交易的LockTime是否已经生效（对应IsFinalTx）
+func (s *DogeSimulator) checkFinal(tx *wire.MsgTx) error {
+	if tx.LockTime == 0 {
+		return nil
+	}
+	nextHeight := s.height + 1
+	if tx.LockTime < lockTimeThreshold {
+		if int64(tx.LockTime) < nextHeight {
+			return nil
+		}
+	} else if int64(tx.LockTime) < s.blockTime(s.height) {
+		return nil
+	}
+	for _, in := range tx.TxIn {
+		if in.Sequence != wire.MaxTxInSequenceNum {
+			return fmt.Errorf("交易LockTime未到: lockTime=%d, 当前高度=%d", tx.LockTime, s.height)
+		}
+	}
+	return nil
+}
+
+// checkStandard 检查Dogecoin的标准交易策略（对应IsStandardTx和最低手续费检查）
+func (s *DogeSimulator) checkStandard(tx *wire.MsgTx, fee int64) error {
+	if tx.Version < 1 || tx.Version > 2 {
+		return fmt.Errorf("非标准交易版本: %d", tx.Version)
+	}
+	txSize := tx.SerializeSize()
+	if txSize > s.Policy.MaxStandardTxSize {
+		return fmt.Errorf("交易过大: %d > %d", txSize, s.Policy.MaxStandardTxSize)
+	}
+	for i, in := range tx.TxIn {
+		if len(in.SignatureScript) > s.Policy.MaxScriptSigSize {
+			return &ErrScriptSigTooLarge{Input: i, Size: len(in.SignatureScript), Limit: s.Policy.MaxScriptSigSize}
+		}
+		if !txscript.IsPushOnlyScript(in.SignatureScript) {
+			return fmt.Errorf("输入 %d 签名脚本不是纯push脚本", i)
+		}
+		if len(in.Witness) > 0 {
+			return fmt.Errorf("输入 %d 包含见证数据，Dogecoin不支持SegWit", i)
+		}
+	}
+	for i, out := range tx.TxOut {
+		scriptClass := txscript.GetScriptClass(out.PkScript)
+		switch scriptClass {
+		case txscript.PubKeyHashTy, txscript.ScriptHashTy, txscript.PubKeyTy, txscript.MultiSigTy:
+			if out.Value < s.Policy.DustLimit {
+				return fmt.Errorf("输出 %d: %w", i, &ErrDustOutput{Amount: out.Value, Limit: s.Policy.DustLimit})
+			}
+		case txscript.NullDataTy:
+		default:
+			return fmt.Errorf("输出 %d 脚本类型非标准: %s", i, scriptClass)
+		}
+	}
+	minFee := int64(txSize) * s.Policy.MinRelayFeeRate / 1000
+	if fee < minFee {
+		return fmt.Errorf("手续费过低: %d < %d (大小=%d)", fee, minFee, txSize)
+	}
+	return nil
+}
+
+// ListUnspent 列出地址的UTXO（包含内存池中未花费的输出）
+func (s *DogeSimulator) ListUnspent(ctx context.Context, address string) ([]*TxInputUtxo, error) {
+	pkScriptHex, err := addressPkScriptHex(s.NetParam, address)
+	if err != nil {
+		return nil, err
+	}
+	pkScript, _ := hex.DecodeString(pkScriptHex)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	utxos := make([]*TxInputUtxo, 0)
+	for outPoint, utxo := range s.utxos {
+		if !bytes.Equal(utxo.out.PkScript, pkScript) {
+			continue
+		}
+		utxos = append(utxos, &TxInputUtxo{
+			TxId:     outPoint.Hash.String(),
+			TxIndex:  int64(outPoint.Index),
+			PkScript: pkScriptHex,
+			Amount:   uint64(utxo.out.Value),
+			SignMode: SignModeLegacy,
+		})
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].TxId != utxos[j].TxId {
+			return utxos[i].TxId < utxos[j].TxId
+		}
+		return utxos[i].TxIndex < utxos[j].TxIndex
+	})
+	return utxos, nil
+}
+
+// GetRawTx 获取已接收交易的原始数据
+func (s *DogeSimulator) GetRawTx(ctx context.Context, txId string) (string, error) {
+	hash, err := chainhash.NewHashFromStr(txId)
+	if err != nil {
+		return "", fmt.Errorf("解析TxId失败: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	simTx, ok := s.txs[*hash]
+	if !ok {
+		return "", fmt.Errorf("交易不存在: %s", txId)
+	}
+	var buf bytes.Buffer
+	if err := simTx.tx.Serialize(&buf); err != nil {
+		return "", fmt.Errorf("序列化交易失败: %w", err)
+	}
+	return hex.EncodeToString(buf.Bytes()), nil
+}
+
+// GetTxStatus 获取交易的确认状态
+func (s *DogeSimulator) GetTxStatus(ctx context.Context, txId string) (*TxStatus, error) {
+	hash, err := chainhash.NewHashFromStr(txId)
+	if err != nil {
+		return nil, fmt.Errorf("解析TxId失败: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	simTx, ok := s.txs[*hash]
+	if !ok {
+		return nil, fmt.Errorf("交易不存在: %s", txId)
+	}
+	status := &TxStatus{TxId: txId}
+	if simTx.height > 0 {
+		status.Confirmed = true
+		status.Confirmations = s.height - simTx.height + 1
+		status.BlockHeight = simTx.height
+	}
+	return status, nil
+}
@@ -0,0 +1,253 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testSimulatorWallet 创建模拟账本，给seed对应的地址按amounts充值，返回填写了私钥的UTXO
+func testSimulatorWallet(t *testing.T, seed byte, amounts ...int64) (*DogeSimulator, string, string, []*TxInputUtxo) {
+	t.Helper()
+	priHex, address := testDogeKey(t, seed)
+	sim := NewDogeSimulator(DogeMainNetParams)
+	for _, amount := range amounts {
+		if _, err := sim.Fund(address, amount); err != nil {
+			t.Fatal(err)
+		}
+	}
+	utxos, err := ListSpendableUtxos(context.Background(), sim, address, priHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim, priHex, address, utxos
+}
+
+// testSpendTx 用P2PKH签名花费utxo，输出到address
+func testSpendTx(t *testing.T, utxo *TxInputUtxo, address string, values ...int64) *wire.MsgTx {
+	t.Helper()
+	hash, _ := chainhash.NewHashFromStr(utxo.TxId)
+	pkScriptHex, err := addressPkScriptHex(DogeMainNetParams, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, _ := hex.DecodeString(pkScriptHex)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, uint32(utxo.TxIndex)), nil, nil))
+	for _, value := range values {
+		tx.AddTxOut(wire.NewTxOut(value, pkScript))
+	}
+	testSignP2PKH(t, tx, 0, utxo)
+	return tx
+}
+
+// testSignP2PKH 重新签名tx的第i个输入
+func testSignP2PKH(t *testing.T, tx *wire.MsgTx, i int, utxo *TxInputUtxo) {
+	t.Helper()
+	privateKeyBytes, _ := hex.DecodeString(utxo.PriHex)
+	privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
+	prevPkScript, _ := hex.DecodeString(utxo.PkScript)
+	sigScript, err := txscript.SignatureScript(tx, i, prevPkScript, txscript.SigHashAll, privateKey, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.TxIn[i].SignatureScript = sigScript
+}
+
+// testSubmitAndMine 提交交易链并打包，所有交易都必须被模拟账本接受
+func testSubmitAndMine(t *testing.T, sim *DogeSimulator, txs ...*wire.MsgTx) {
+	t.Helper()
+	if err := sim.SubmitTxs(txs); err != nil {
+		t.Fatal(err)
+	}
+	if mined := sim.MineBlock(); len(mined) != len(txs) {
+		t.Fatalf("打包了%d笔交易, 期望%d", len(mined), len(txs))
+	}
+}
+
+func TestDogeSimulatorPolicy(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 1e8, 1e8, 1e8)
+
+	// dust输出
+	_, err := sim.SubmitTx(testSpendTx(t, utxos[0], address, 5e7, DogeDustLimit-1))
+	var dustErr *ErrDustOutput
+	if !errors.As(err, &dustErr) || dustErr.Limit != DogeDustLimit {
+		t.Errorf("dust输出: %v", err)
+	}
+
+	// 手续费低于最低转发费率
+	if _, err := sim.SubmitTx(testSpendTx(t, utxos[0], address, 1e8-1000)); err == nil {
+		t.Error("手续费过低的交易应该被拒绝")
+	}
+
+	// 签名错误
+	tx := testSpendTx(t, utxos[0], address, 9e7)
+	tx.TxOut[0].Value++
+	if _, err := sim.SubmitTx(tx); err == nil {
+		t.Error("签名错误的交易应该被拒绝")
+	}
+
+	// 正常交易，然后重复花费
+	tx = testSpendTx(t, utxos[0], address, 9e7)
+	txHash, err := sim.SubmitTx(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.SubmitTx(testSpendTx(t, utxos[0], address, 8e7)); err == nil {
+		t.Error("重复花费应该被拒绝")
+	}
+	if fee, err := sim.TxFee(txHash.String()); err != nil || fee != 1e7 {
+		t.Errorf("TxFee: %d, %v", fee, err)
+	}
+
+	// 内存池中的输出可以继续花费，打包后确认数增加
+	child := testSpendTx(t, &TxInputUtxo{TxId: txHash.String(), PkScript: utxos[0].PkScript, PriHex: utxos[0].PriHex}, address, 8e7)
+	if _, err := sim.SubmitTx(child); err != nil {
+		t.Fatal(err)
+	}
+	if sim.MempoolSize() != 2 {
+		t.Errorf("内存池交易数: %d", sim.MempoolSize())
+	}
+	sim.MineBlock()
+	sim.MineBlock()
+	status, err := sim.GetTxStatus(context.Background(), txHash.String())
+	if err != nil || !status.Confirmed || status.Confirmations != 2 {
+		t.Errorf("GetTxStatus: %+v, %v", status, err)
+	}
+
+	// LockTime未到且sequence不是final
+	tx = testSpendTx(t, utxos[1], address, 9e7)
+	tx.LockTime = uint32(sim.Height() + 10)
+	tx.TxIn[0].Sequence = wire.MaxTxInSequenceNum - 1
+	testSignP2PKH(t, tx, 0, utxos[1])
+	if _, err := sim.SubmitTx(tx); err == nil {
+		t.Error("LockTime未到的交易应该被拒绝")
+	}
+	for sim.Height() < int64(tx.LockTime) {
+		sim.MineBlock()
+	}
+	if _, err := sim.SubmitTx(tx); err != nil {
+		t.Errorf("LockTime到达后: %v", err)
+	}
+
+	// 签名脚本过大
+	tx = testSpendTx(t, utxos[2], address, 9e7)
+	tx.TxIn[0].SignatureScript = append(bytes.Repeat([]byte{txscript.OP_DATA_75}, 1), bytes.Repeat([]byte{0}, 75)...)
+	for len(tx.TxIn[0].SignatureScript) <= DefaultDogePolicy.MaxScriptSigSize {
+		tx.TxIn[0].SignatureScript = append(tx.TxIn[0].SignatureScript, tx.TxIn[0].SignatureScript[:76]...)
+	}
+	var sigErr *ErrScriptSigTooLarge
+	if _, err := sim.SubmitTx(tx); !errors.As(err, &sigErr) {
+		t.Errorf("签名脚本过大: %v", err)
+	}
+}
+
+// TestDogeSimulatorMempoolChain 未确认的交易链超过祖先或后代限制时被拒绝，前面的交易确认后可以继续广播
+func TestDogeSimulatorMempoolChain(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 100e8)
+	spend := func(prev *wire.MsgTx, index uint32, values ...int64) *wire.MsgTx {
+		return testSpendTx(t, &TxInputUtxo{TxId: prev.TxHash().String(), TxIndex: int64(index), PkScript: utxos[0].PkScript, PriHex: utxos[0].PriHex}, address, values...)
+	}
+
+	// 每笔交易花费上一笔的输出，包含自身最多25笔
+	chain := []*wire.MsgTx{testSpendTx(t, utxos[0], address, 99e8)}
+	for len(chain) < DefaultDogePolicy.MaxAncestorCount {
+		chain = append(chain, spend(chain[len(chain)-1], 0, chain[len(chain)-1].TxOut[0].Value-1e7))
+	}
+	if err := sim.SubmitTxs(chain); err != nil {
+		t.Fatal(err)
+	}
+	next := spend(chain[len(chain)-1], 0, chain[len(chain)-1].TxOut[0].Value-1e7)
+	if _, err := sim.SubmitTx(next); err == nil {
+		t.Error("第26笔未确认的交易链应该被拒绝")
+	}
+	sim.MineBlock()
+	if _, err := sim.SubmitTx(next); err != nil {
+		t.Errorf("前面的交易确认后: %v", err)
+	}
+	sim.MineBlock()
+
+	// 一笔交易的后代包含自身最多25笔
+	values := make([]int64, 30)
+	for i := range values {
+		values[i] = 1e8
+	}
+	parent := spend(next, 0, values...)
+	if _, err := sim.SubmitTx(parent); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < DefaultDogePolicy.MaxDescendantCount-1; i++ {
+		if _, err := sim.SubmitTx(spend(parent, uint32(i), 9e7)); err != nil {
+			t.Fatalf("第%d个子交易: %v", i, err)
+		}
+	}
+	if _, err := sim.SubmitTx(spend(parent, 29, 9e7)); err == nil {
+		t.Error("超过后代限制的子交易应该被拒绝")
+	}
+
+	// 大小限制同样包含祖先
+	sim, _, address, utxos = testSimulatorWallet(t, 1, 100e8)
+	sim.Policy.MaxAncestorSize = 500
+	first := testSpendTx(t, utxos[0], address, 99e8)
+	second := spend(first, 0, 98e8)
+	if err := sim.SubmitTxs([]*wire.MsgTx{first, second}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sim.SubmitTx(spend(second, 0, 97e8)); err == nil {
+		t.Error("超过祖先大小限制的交易应该被拒绝")
+	}
+}
+
+func TestDogeSimulatorInscriptionChain(t *testing.T) {
+	for _, format := range []InscriptionFormat{InscriptionFormatDoginal, InscriptionFormatMetaID} {
+		for _, size := range []int{10, 500, 3000} {
+			sim, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+			data := bytes.Repeat([]byte("x"), size)
+			txs, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, data, "text/plain", utxos, address, 100000, address, 1000, false, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := sim.SubmitTxs(txs); err != nil {
+				t.Errorf("format %d size %d: %v", format, size, err)
+			}
+		}
+	}
+}
+
+// TestDogeSimulatorMinFunding 用MinFunding附近的金额构建交易链，
+// 最后一笔交易的找零低于DogeDustLimit时必须并入手续费，而不是生成节点不转发的dust输出
+func TestDogeSimulatorMinFunding(t *testing.T) {
+	const feeRate = 1000
+	data := bytes.Repeat([]byte("m"), 1200)
+	for _, format := range []InscriptionFormat{InscriptionFormatDoginal, InscriptionFormatMetaID} {
+		estimate, err := EstimateInscription(data, "text/plain", format, feeRate, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, extra := range []int64{0, 700, 50000, 99999, 100000, DogeDustLimit + 34*feeRate, 1e8} {
+			sim, _, address, utxos := testSimulatorWallet(t, 1, estimate.MinFunding+extra)
+			txs, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, data, "text/plain", utxos, address, 100000, address, feeRate, false, format)
+			if err != nil {
+				t.Errorf("format %d MinFunding+%d: %v", format, extra, err)
+				continue
+			}
+			if err := sim.SubmitTxs(txs); err != nil {
+				t.Errorf("format %d MinFunding+%d: %v", format, extra, err)
+			}
+		}
+
+		_, _, address, utxos := testSimulatorWallet(t, 1, estimate.MinFunding-1)
+		_, err = BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, data, "text/plain", utxos, address, 100000, address, feeRate, false, format)
+		var fundsErr *ErrInsufficientFunds
+		if !errors.As(err, &fundsErr) {
+			t.Errorf("format %d MinFunding-1: %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// DogeTimeLock CLTV时间锁（BIP65），赎回脚本为 <locktime> OP_CHECKLOCKTIMEVERIFY OP_DROP <公钥> OP_CHECKSIG
+// LockTime小于500000000时表示区块高度，否则表示Unix时间戳；锁定的DOGE在LockTime之后才能由公钥对应的私钥花费
+type DogeTimeLock struct {
+	LockTime     uint32
+	PubKey       []byte
+	RedeemScript []byte
+}
+
+// NewDogeTimeLock 由锁定时间和公钥（十六进制）创建时间锁
+func NewDogeTimeLock(lockTime uint32, pubKeyHex string) (*DogeTimeLock, error) {
+	if lockTime == 0 {
+		return nil, fmt.Errorf("%w: 锁定时间不能为0", ErrInvalidOption)
+	}
+	pubKey, err := hex.DecodeString(pubKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("解码公钥失败: %w", err)
+	}
+	if _, err := btcec.ParsePubKey(pubKey); err != nil {
+		return nil, fmt.Errorf("解析公钥失败: %w", err)
+	}
+	redeemScript, err := txscript.NewScriptBuilder().
+		AddInt64(int64(lockTime)).
+		AddOp(txscript.OP_CHECKLOCKTIMEVERIFY).
+		AddOp(txscript.OP_DROP).
+		AddData(pubKey).
+		AddOp(txscript.OP_CHECKSIG).
+		Script()
+	if err != nil {
+		return nil, fmt.Errorf("构建赎回脚本失败: %w", err)
+	}
+	return &DogeTimeLock{LockTime: lockTime, PubKey: pubKey, RedeemScript: redeemScript}, nil
+}
+
+// ParseDogeTimeLock 解析赎回脚本（十六进制），只接受NewDogeTimeLock生成的写法
+func ParseDogeTimeLock(redeemScriptHex string) (*DogeTimeLock, error) {
+	redeemScript, err := hex.DecodeString(redeemScriptHex)
+	if err != nil {
+		return nil, fmt.Errorf("解码赎回脚本失败: %w", err)
+	}
+	tokenizer := txscript.MakeScriptTokenizer(0, redeemScript)
+	var lockTime int64
+	if tokenizer.Next() {
+		switch op := tokenizer.Opcode(); {
+		case op >= txscript.OP_1 && op <= txscript.OP_16:
+			lockTime = int64(op - (txscript.OP_1 - 1))
+		case len(tokenizer.Data()) > 0 && len(tokenizer.Data()) <= 5:
+			for i, b := range tokenizer.Data() {
+				lockTime |= int64(b) << (8 * i)
+			}
+		}
+	}
+	var pubKey []byte
+	for tokenizer.Next() {
+		if len(tokenizer.Data()) > 0 {
+			pubKey = tokenizer.Data()
+		}
+	}
+	if tokenizer.Err() != nil || lockTime <= 0 || lockTime > 0xffffffff || pubKey == nil {
+		return nil, fmt.Errorf("赎回脚本不是CLTV时间锁脚本")
+	}
+	lock, err := NewDogeTimeLock(uint32(lockTime), hex.EncodeToString(pubKey))
+	if err != nil || !bytes.Equal(lock.RedeemScript, redeemScript) {
+		return nil, fmt.Errorf("赎回脚本不是CLTV时间锁脚本")
+	}
+	return lock, nil
+}
+
+// IsHeight LockTime是否表示区块高度
+func (l *DogeTimeLock) IsHeight() bool {
+	return l.LockTime < lockTimeThreshold
+}
+
+// Address P2SH地址，前缀为netParam.ScriptHashAddrID，向该地址转账即锁定DOGE
+func (l *DogeTimeLock) Address(netParam *chaincfg.Params) (string, error) {
+	addr, err := btcutil.NewAddressScriptHash(l.RedeemScript, netParam)
+	if err != nil {
+		return "", fmt.Errorf("生成P2SH地址失败: %w", err)
+	}
+	return addr.EncodeAddress(), nil
+}
+
+// PkScript P2SH输出脚本
+func (l *DogeTimeLock) PkScript() []byte {
+	return p2shPkScript(l.RedeemScript)
+}
+
+// SigScriptSize 签名脚本 <签名> <赎回脚本> 的最大字节数
+func (l *DogeTimeLock) SigScriptSize() int {
+	return 1 + 72 + scriptPushSize(len(l.RedeemScript))
+}
+
+// BuildDogeTimeLockSpendTx 把时间锁的UTXO全部转到toAddress，手续费从转出金额中扣除
+// lockedUtxos必须属于lock，并且填写了公钥对应的PriHex。
+// 交易的LockTime设为lock.LockTime，输入的sequence为0xfffffffe（不是final，否则LockTime不生效），
+// 在LockTime之前广播会被节点以non-final拒绝
+func BuildDogeTimeLockSpendTx(
+	netParam *chaincfg.Params,
+	lock *DogeTimeLock,
+	lockedUtxos []*TxInputUtxo,
+	toAddress string,
+	feeRate int64, // satoshis/B
+) (*wire.MsgTx, error) {
+	if lock == nil {
+		return nil, fmt.Errorf("%w: 时间锁为空", ErrInvalidOption)
+	}
+	if len(lockedUtxos) == 0 {
+		return nil, fmt.Errorf("%w: 没有需要花费的时间锁UTXO", ErrInvalidOption)
+	}
+	addr, err := decodeDogeAddress(toAddress, netParam)
+	if err != nil {
+		return nil, fmt.Errorf("解码接收地址失败: %w", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("构建接收地址脚本失败: %w", err)
+	}
+
+	lockPkScript := lock.PkScript()
+	lockPkScriptHex := hex.EncodeToString(lockPkScript)
+	tx := wire.NewMsgTx(2)
+	tx.LockTime = lock.LockTime
+	totalAmount := int64(0)
+	for _, utxo := range lockedUtxos {
+		if utxo.PkScript != lockPkScriptHex {
+			return nil, fmt.Errorf("%w: UTXO %s:%d不属于时间锁", ErrInvalidUtxo, utxo.TxId, utxo.TxIndex)
+		}
+		txIn, err := utxoTxIn(utxo)
+		if err != nil {
+			return nil, err
+		}
+		txIn.Sequence = wire.MaxTxInSequenceNum - 1
+		tx.AddTxIn(txIn)
+		totalAmount += int64(utxo.Amount)
+	}
+	tx.AddTxOut(wire.NewTxOut(0, pkScript))
+
+	sigScriptSize := lock.SigScriptSize()
+	txSize := tx.SerializeSize() + len(lockedUtxos)*(sigScriptSize+wire.VarIntSerializeSize(uint64(sigScriptSize))-1)
+	fee := int64(txSize) * feeRate
+	value := totalAmount - fee
+	if value < DogeDustLimit {
+		return nil, &ErrInsufficientFunds{Needed: fee + DogeDustLimit, Available: totalAmount}
+	}
+	tx.TxOut[0].Value = value
+	dogeLog().Debug("构建时间锁花费交易", "lockTime", lock.LockTime, "txSize", txSize, "fee", fee, "value", value)
+
+	prevOutFetcher := txscript.NewMultiPrevOutFetcher(nil)
+	for i, utxo := range lockedUtxos {
+		prevOutFetcher.AddPrevOut(tx.TxIn[i].PreviousOutPoint, wire.NewTxOut(int64(utxo.Amount), lockPkScript))
+	}
+	for i, utxo := range lockedUtxos {
+		privateKeyBytes, err := hex.DecodeString(utxo.PriHex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s:%d的私钥无法解码: %v", ErrInvalidUtxo, utxo.TxId, utxo.TxIndex, err)
+		}
+		privateKey, publicKey := btcec.PrivKeyFromBytes(privateKeyBytes)
+		if !bytes.Equal(publicKey.SerializeCompressed(), lock.PubKey) && !bytes.Equal(publicKey.SerializeUncompressed(), lock.PubKey) {
+			return nil, fmt.Errorf("%w: UTXO %s:%d的私钥与时间锁的公钥不匹配", ErrInvalidUtxo, utxo.TxId, utxo.TxIndex)
+		}
+		sig, err := txscript.RawTxInSignature(tx, i, lock.RedeemScript, txscript.SigHashAll, privateKey)
+		if err != nil {
+			return nil, fmt.Errorf("UTXO签名失败: %w", err)
+		}
+		sigScript, err := txscript.NewScriptBuilder().AddData(sig).AddData(lock.RedeemScript).Script()
+		if err != nil {
+			return nil, fmt.Errorf("构建签名脚本失败: %w", err)
+		}
+		tx.TxIn[i].SignatureScript = sigScript
+	}
+
+	for i, utxo := range lockedUtxos {
+		vm, err := txscript.NewEngine(lockPkScript, tx, i, DogeStandardVerifyFlags,
+			nil, nil, int64(utxo.Amount), prevOutFetcher)
+		if err != nil {
+			return nil, fmt.Errorf("创建脚本引擎失败: %w", err)
+		}
+		if err := vm.Execute(); err != nil {
+			return nil, fmt.Errorf("第%d个输入签名验证失败: %w", i, err)
+		}
+	}
+	return tx, nil
+}
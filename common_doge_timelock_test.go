@@ -0,0 +1,92 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcd/wire"
+)
+
+func TestDogeTimeLock(t *testing.T) {
+	pubKeyHex := hex.EncodeToString(testPrivateKey(1).PubKey().SerializeCompressed())
+	// OP_1..OP_16、1字节、4字节的高度以及时间戳
+	for _, lockTime := range []uint32{5, 300, 1 << 24, 1700000000} {
+		lock, err := NewDogeTimeLock(lockTime, pubKeyHex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseDogeTimeLock(hex.EncodeToString(lock.RedeemScript))
+		if err != nil || parsed.LockTime != lockTime || lock.IsHeight() != (lockTime < 500000000) {
+			t.Errorf("%d: %v %+v", lockTime, err, parsed)
+		}
+	}
+
+	sim := NewDogeSimulator(DogeMainNetParams)
+	priHex := hex.EncodeToString(testPrivateKey(1).Serialize())
+	_, address := testDogeKey(t, 2)
+	lockHeight := uint32(sim.Height() + 5)
+	lock, err := NewDogeTimeLock(lockHeight, pubKeyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockAddress, err := lock.Address(DogeMainNetParams)
+	if err != nil || lockAddress[0] != 'A' && lockAddress[0] != '9' {
+		t.Fatalf("P2SH地址: %s %v", lockAddress, err)
+	}
+	utxo, err := sim.Fund(lockAddress, 10e8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	utxo.PriHex = priHex
+	tx, err := BuildDogeTimeLockSpendTx(DogeMainNetParams, lock, []*TxInputUtxo{utxo}, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.LockTime != lockHeight || tx.TxIn[0].Sequence != wire.MaxTxInSequenceNum-1 {
+		t.Fatalf("lockTime=%d sequence=%x", tx.LockTime, tx.TxIn[0].Sequence)
+	}
+	if fee := 10e8 - tx.TxOut[0].Value; fee < int64(tx.SerializeSize())*1000 || fee > int64(tx.SerializeSize()+5)*1000 {
+		t.Errorf("手续费%d, 交易%d字节", fee, tx.SerializeSize())
+	}
+	// LockTime之前被拒绝，到达后可以打包
+	if err := sim.SubmitTxs([]*wire.MsgTx{tx}); err == nil {
+		t.Fatal("LockTime之前的交易被接受")
+	}
+	for int64(lockHeight) >= sim.Height()+1 {
+		sim.MineBlock()
+	}
+	testSubmitAndMine(t, sim, tx)
+
+	wrongKey, err := sim.Fund(lockAddress, 10e8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrongKey.PriHex = hex.EncodeToString(testPrivateKey(3).Serialize())
+	p2pkhPriHex, p2pkh := testDogeKey(t, 3)
+	p2pkhUtxo, err := sim.Fund(p2pkh, 10e8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p2pkhUtxo.PriHex = p2pkhPriHex
+	for name, err := range map[string]error{
+		"锁定时间为0": func() error { _, err := NewDogeTimeLock(0, pubKeyHex); return err }(),
+		"时间锁为空": func() error {
+			_, err := BuildDogeTimeLockSpendTx(DogeMainNetParams, nil, []*TxInputUtxo{utxo}, address, 1000)
+			return err
+		}(),
+		"没有时间锁UTXO": func() error {
+			_, err := BuildDogeTimeLockSpendTx(DogeMainNetParams, lock, nil, address, 1000)
+			return err
+		}(),
+	} {
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	for name, utxo := range map[string]*TxInputUtxo{"私钥与公钥不匹配": wrongKey, "UTXO不属于时间锁": p2pkhUtxo} {
+		if _, err := BuildDogeTimeLockSpendTx(DogeMainNetParams, lock, []*TxInputUtxo{utxo}, address, 1000); !errors.Is(err, ErrInvalidUtxo) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
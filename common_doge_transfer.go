@@ -0,0 +1,92 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// BuildDogeInscriptionTransferTx 把inscription发送到toAddress
+// 交易结构固定为: 输入0为inscription UTXO，输出0为接收地址（金额为postage），
+// 其余输入只从ins中选取用于支付手续费，找零在输出0之后；inscription始终在输入0和输出0的第一个satoshi上。
+// postage为0时保持inscription UTXO原来的金额；postage不能低于inscription UTXO的金额，
+// 否则差额会进入找零或手续费，与inscription所在的金额混在一起。
+// ins中不能包含其他inscription的UTXO，与inscriptionUtxo相同的UTXO会被跳过
+func BuildDogeInscriptionTransferTx(
+	netParam *chaincfg.Params,
+	inscriptionUtxo *TxInputUtxo,
+	toAddress string,
+	postage int64,
+	ins []*TxInputUtxo,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+) (*wire.MsgTx, error) {
+	if inscriptionUtxo == nil {
+		return nil, fmt.Errorf("%w: inscription UTXO为空", ErrInvalidOption)
+	}
+	if changeAddress == "" {
+		return nil, fmt.Errorf("%w: 没有设置找零地址", ErrInvalidOption)
+	}
+	inscriptionValue := int64(inscriptionUtxo.Amount)
+	if postage == 0 {
+		postage = inscriptionValue
+	}
+	if postage < inscriptionValue {
+		return nil, fmt.Errorf("%w: postage不能低于inscription UTXO的金额: %d < %d", ErrInvalidOption, postage, inscriptionValue)
+	}
+	if postage < DogeDustLimit {
+		return nil, &ErrDustOutput{Address: toAddress, Amount: postage, Limit: DogeDustLimit}
+	}
+	if postage > dogeMaxMoney {
+		return nil, fmt.Errorf("%w: postage超出范围: %d", ErrInvalidOption, postage)
+	}
+
+	addr, err := decodeDogeAddress(toAddress, netParam)
+	if err != nil {
+		return nil, fmt.Errorf("解码接收地址失败: %w", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return nil, fmt.Errorf("构建接收地址脚本失败: %w", err)
+	}
+
+	tx := wire.NewMsgTx(2)
+	inscriptionTxIn, err := utxoTxIn(inscriptionUtxo)
+	if err != nil {
+		return nil, err
+	}
+	tx.AddTxIn(inscriptionTxIn)
+	tx.AddTxOut(wire.NewTxOut(postage, pkScript))
+
+	// 支付手续费的UTXO不能是inscription UTXO本身
+	fundingUtxos := make([]*TxInputUtxo, 0, len(ins))
+	for _, utxo := range ins {
+		if utxo.TxId == inscriptionUtxo.TxId && utxo.TxIndex == inscriptionUtxo.TxIndex {
+			continue
+		}
+		fundingUtxos = append(fundingUtxos, utxo)
+	}
+
+	// inscription输入的签名脚本约107字节，与钱包UTXO相同
+	usedUtxos, _, _, err := fundTransaction(
+		tx,
+		fundingUtxos,
+		changeAddress,
+		netParam,
+		feeRate,
+		inscriptionValue,
+		107,
+		dogeLog(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("fund inscription转账交易失败: %w", err)
+	}
+
+	signedUtxos := append([]*TxInputUtxo{inscriptionUtxo}, usedUtxos...)
+	if err := signTransactionInputs(tx, signedUtxos, 0); err != nil {
+		return nil, fmt.Errorf("签名inscription转账交易失败: %w", err)
+	}
+	return tx, nil
+}
@@ -0,0 +1,75 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestBuildDogeInscriptionTransferTx(t *testing.T) {
+	sim, priHex, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	_, receiver := testDogeKey(t, 2)
+	builder, err := NewInscriptionBuilder(WithDoginal([]byte("hi"), "text/plain"), WithUtxos(utxos), WithChangeAddress(address), WithFeeRate(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSubmitAndMine(t, sim, result.Txs...)
+	id, err := result.InscriptionId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if utxos, err = ListSpendableUtxos(context.Background(), sim, address, priHex); err != nil {
+		t.Fatal(err)
+	}
+	var inscriptionUtxo *TxInputUtxo
+	for _, utxo := range utxos {
+		if utxo.TxId == id.TxId && utxo.TxIndex == 0 {
+			inscriptionUtxo = utxo
+		}
+	}
+	if inscriptionUtxo == nil {
+		t.Fatal("没有找到inscription UTXO")
+	}
+
+	// 钱包UTXO中包含inscription UTXO，支付手续费时被跳过
+	tx, err := BuildDogeInscriptionTransferTx(DogeMainNetParams, inscriptionUtxo, receiver, 150000, utxos, address, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in := tx.TxIn[0].PreviousOutPoint; in.Hash.String() != id.TxId || in.Index != 0 || tx.TxOut[0].Value != 150000 || len(tx.TxIn) < 2 {
+		t.Fatal("输入0不是inscription UTXO或输出0不是postage")
+	}
+	for _, in := range tx.TxIn[1:] {
+		if in.PreviousOutPoint.Hash.String() == id.TxId && in.PreviousOutPoint.Index == 0 {
+			t.Error("inscription UTXO被重复花费")
+		}
+	}
+	testSubmitAndMine(t, sim, tx)
+
+	for name, build := range map[string]func() error{
+		"inscription UTXO为空": func() error {
+			_, err := BuildDogeInscriptionTransferTx(DogeMainNetParams, nil, receiver, 0, utxos, address, 1000)
+			return err
+		},
+		"没有找零地址": func() error {
+			_, err := BuildDogeInscriptionTransferTx(DogeMainNetParams, inscriptionUtxo, receiver, 0, utxos, "", 1000)
+			return err
+		},
+		"postage低于inscription UTXO": func() error {
+			_, err := BuildDogeInscriptionTransferTx(DogeMainNetParams, inscriptionUtxo, receiver, int64(inscriptionUtxo.Amount)-1, utxos, address, 1000)
+			return err
+		},
+		"postage超出范围": func() error {
+			_, err := BuildDogeInscriptionTransferTx(DogeMainNetParams, inscriptionUtxo, receiver, dogeMaxMoney+1, utxos, address, 1000)
+			return err
+		},
+	} {
+		if err := build(); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
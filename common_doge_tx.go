@@ -2,10 +2,13 @@ package common
 
 import (
 	"bytes"
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"log/slog"
 	"math"
+	"strings"
 
 	"github.com/btcsuite/btcd/blockchain"
 	"github.com/btcsuite/btcd/btcec/v2"
@@ -53,8 +56,14 @@ var DogeMainNetParams = &chaincfg.Params{
 const (
 	MAX_CHUNK_LEN   int64 = 240
 	MAX_PAYLOAD_LEN int64 = 1500
+	// MAX_DOGINAL_PARTS Doginal的parts数量和索引用numberToChunk编码，最大为OP_DATA_2能表示的65535
+	MAX_DOGINAL_PARTS int64 = 0xffff
 )
 
+// DogeDustLimit Dogecoin Core 1.14的硬dust限制（DEFAULT_HARD_DUST_LIMIT，0.001 DOGE）
+// 所有构建函数的输出和找零都以此为下限，低于该金额的找零并入手续费；模拟账本的DefaultDogePolicy同样使用该值
+const DogeDustLimit int64 = 100000
+
 // InscriptionFormat 定义inscription格式类型
 type InscriptionFormat int
 
@@ -79,257 +88,500 @@ type InscriptionData struct {
 	Data        []byte            // 实际数据
 
 	// Doginal格式专用字段
-	PartsCount int // 数据块数量
+	PartsCount int // 数据块数量，Continuation为true时为0
 	Index      int // 当前索引
 
+	// Continuation 交易中是Doginal交易链的后续partial，只有(索引 + 数据块)，没有'ord'、parts数量和contentType
+	// 完整的inscription用ParseInscriptionFromChain从整个交易链读出
+	Continuation bool
+
 	// MetaID格式专用字段
 	Operation  string // 操作类型
 	Path       string // 路径
 	Encryption string // 加密标志
 	Version    string // 版本
+	Decrypted  bool   // Data是否已经解密为明文
 }
 
 // ParseInscriptionFromTx 从交易中解析inscription数据
 // txRaw: 十六进制格式的原始交易数据
 // format: 指定解析的格式（Doginal或MetaID）
+// 交易数据来自链上，不可信：任何格式错误都返回error，不会panic
+// 多partial的inscription中，Doginal的后续partial返回Continuation为true的部分数据；
+// MetaID的后续partial只有payload，无法单独识别，返回ErrNotInscription，需要用ParseInscriptionFromChain
 func ParseInscriptionFromTx(txRaw string, format InscriptionFormat) (*InscriptionData, error) {
+	return ParseInscriptionFromTxWithDecrypter(txRaw, format, nil)
+}
+
+// ParseInscriptionFromTxWithKey 从交易中解析inscription数据，并用privateKey解密加密的MetaID PIN
+// 加密标志为"1"（ECIES）或"2"（ECDH）的PIN会被解密，Data为明文且Decrypted为true；
+// PIN不是发给privateKey的时候Data为原始密文，Decrypted为false，不返回错误；
+// privateKey为nil或PIN未加密时与ParseInscriptionFromTx相同
+func ParseInscriptionFromTxWithKey(txRaw string, format InscriptionFormat, privateKey *btcec.PrivateKey) (*InscriptionData, error) {
+	if privateKey == nil {
+		return ParseInscriptionFromTxWithDecrypter(txRaw, format, nil)
+	}
+	return ParseInscriptionFromTxWithDecrypter(txRaw, format, NewKeyDecrypter(privateKey))
+}
+
+// ParseInscriptionFromTxWithDecrypter 从交易中解析inscription数据，加密的MetaID PIN交给decrypter解密
+// decrypter为nil时不解密
+func ParseInscriptionFromTxWithDecrypter(txRaw string, format InscriptionFormat, decrypter InscriptionDecrypter) (*InscriptionData, error) {
+	_, tokens, err := decodeInscriptionPartial(txRaw)
+	if err != nil {
+		return nil, err
+	}
+	return parseInscriptionTokens(tokens, format, decrypter)
+}
+
+// ParseInscriptionFromChain 从整个交易链中解析inscription，txRaws按广播顺序排列
+// 开头不是inscription partial的交易（commit交易）会被跳过，之后每笔交易的输入0必须花费上一笔的输出0；
+// 所有partial拼接后按format解析，Doginal还要求数据块齐全（索引从parts数量-1到0）
+func ParseInscriptionFromChain(txRaws []string, format InscriptionFormat) (*InscriptionData, error) {
+	return ParseInscriptionFromChainWithDecrypter(txRaws, format, nil)
+}
+
+// ParseInscriptionFromChainWithDecrypter 与ParseInscriptionFromChain相同，加密的MetaID PIN交给decrypter解密
+func ParseInscriptionFromChainWithDecrypter(txRaws []string, format InscriptionFormat, decrypter InscriptionDecrypter) (*InscriptionData, error) {
+	var tokens []scriptToken
+	var prev *wire.OutPoint
+	for i, txRaw := range txRaws {
+		tx, partial, err := decodeInscriptionPartial(txRaw)
+		if err != nil {
+			if prev == nil && errors.Is(err, ErrNotInscription) {
+				continue
+			}
+			return nil, fmt.Errorf("交易%d: %w", i, err)
+		}
+		if prev != nil && tx.TxIn[0].PreviousOutPoint != *prev {
+			return nil, fmt.Errorf("%w: 交易%d的输入0没有花费上一个partial的P2SH输出", ErrNotInscription, i)
+		}
+		tokens = append(tokens, partial...)
+		txHash := tx.TxHash()
+		prev = wire.NewOutPoint(&txHash, 0)
+	}
+	if prev == nil {
+		return nil, fmt.Errorf("%w: 交易链中没有inscription partial", ErrNotInscription)
+	}
+
+	result, err := parseInscriptionTokens(tokens, format, decrypter)
+	if err != nil {
+		return nil, err
+	}
+	if format == InscriptionFormatDoginal {
+		if result.Continuation {
+			return nil, fmt.Errorf("%w: 交易链缺少第一个partial", ErrNotInscription)
+		}
+		if pieces := (len(tokens) - 3) / 2; pieces != result.PartsCount {
+			return nil, fmt.Errorf("%w: 交易链不完整: 数据块数量%d，parts数量%d", ErrNotInscription, pieces, result.PartsCount)
+		}
+	}
+	return result, nil
+}
+
+// decodeInscriptionPartial 解码交易，返回输入0的unlock脚本中的inscription partial token
+func decodeInscriptionPartial(txRaw string) (*wire.MsgTx, []scriptToken, error) {
 	// 解码交易
 	txBytes, err := hex.DecodeString(txRaw)
 	if err != nil {
-		return nil, fmt.Errorf("解码交易失败: %v", err)
+		return nil, nil, fmt.Errorf("%w: 解码交易失败: %v", ErrInvalidOption, err)
 	}
 
 	var tx wire.MsgTx
 	err = tx.Deserialize(bytes.NewReader(txBytes))
 	if err != nil {
-		return nil, fmt.Errorf("反序列化交易失败: %v", err)
+		return nil, nil, fmt.Errorf("%w: 反序列化交易失败: %v", ErrInvalidOption, err)
 	}
 
 	// 获取第一个输入的签名脚本（包含inscription数据）
 	if len(tx.TxIn) == 0 {
-		return nil, fmt.Errorf("交易没有输入")
+		return nil, nil, fmt.Errorf("%w: 交易没有输入", ErrNotInscription)
 	}
 
 	sigScript := tx.TxIn[0].SignatureScript
 	if len(sigScript) == 0 {
-		return nil, fmt.Errorf("第一个输入没有签名脚本")
+		return nil, nil, fmt.Errorf("%w: 第一个输入没有签名脚本", ErrNotInscription)
 	}
 
 	// 解析脚本
-	tokenizer := txscript.MakeScriptTokenizer(0, sigScript)
-	chunks := make([][]byte, 0)
-	opcodes := make([]byte, 0)
+	tokens, err := tokenizeScript(sigScript)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: 解析脚本失败: %v", ErrNotInscription, err)
+	}
 
-	for tokenizer.Next() {
-		data := tokenizer.Data()
-		opcode := tokenizer.Opcode()
+	// 去掉末尾的签名和lock脚本，剩下的是inscription partial
+	tokens, err = trimInscriptionUnlockTail(tokens)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrNotInscription, err)
+	}
+	return &tx, tokens, nil
+}
 
-		if len(data) > 0 {
-			chunks = append(chunks, data)
+// parseInscriptionTokens 根据格式解析inscription token
+func parseInscriptionTokens(tokens []scriptToken, format InscriptionFormat, decrypter InscriptionDecrypter) (*InscriptionData, error) {
+	switch format {
+	case InscriptionFormatDoginal:
+		return parseDoginalInscription(tokens)
+	case InscriptionFormatMetaID:
+		result, err := parseMetaIDInscription(tokens)
+		if err != nil || decrypter == nil {
+			return result, err
 		}
-		opcodes = append(opcodes, opcode)
+		return decryptMetaIDInscription(result, decrypter)
+	default:
+		return nil, fmt.Errorf("%w: 未知的inscription格式: %d", ErrInvalidOption, format)
 	}
+}
 
-	if err := tokenizer.Err(); err != nil {
-		return nil, fmt.Errorf("解析脚本失败: %v", err)
-	}
+// scriptToken 脚本中的一个操作码
+type scriptToken struct {
+	opcode byte
+	data   []byte // push类操作码压入栈的值，其他操作码为nil
+}
 
-	// 根据格式解析
-	if format == InscriptionFormatDoginal {
-		return parseDoginalInscription(chunks, opcodes)
-	} else {
-		return parseMetaIDInscription(chunks, opcodes)
-	}
+// isPush 是否为push类操作码（OP_0、OP_DATA_x、OP_PUSHDATAx、OP_1NEGATE、OP_1到OP_16）
+func (t scriptToken) isPush() bool {
+	return t.data != nil
 }
 
-// parseDoginalInscription 解析Doginal格式的inscription
-func parseDoginalInscription(chunks [][]byte, opcodes []byte) (*InscriptionData, error) {
-	if len(chunks) < 4 {
-		return nil, fmt.Errorf("Doginal格式至少需要4个chunks")
+// chunkData 数据块的内容
+// 构建时每个数据块都不为空，AddData按最小编码把1字节的0x00写成OP_0，所以数据块位置的OP_0表示0x00
+func (t scriptToken) chunkData() []byte {
+	if t.opcode == txscript.OP_0 {
+		return []byte{0}
 	}
+	return t.data
+}
 
-	result := &InscriptionData{
-		Format: InscriptionFormatDoginal,
+// tokenizeScript 把脚本拆分为token
+// OP_0、OP_1NEGATE、OP_1到OP_16按照压入栈的值还原为数据，
+// 与AddData的最小编码互逆，保证AddData写入的数据都能原样读出
+func tokenizeScript(script []byte) ([]scriptToken, error) {
+	tokens := make([]scriptToken, 0)
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	for tokenizer.Next() {
+		opcode := tokenizer.Opcode()
+		token := scriptToken{opcode: opcode}
+		switch {
+		case opcode == txscript.OP_0:
+			token.data = []byte{}
+		case opcode == txscript.OP_1NEGATE:
+			token.data = []byte{0x81}
+		case opcode >= txscript.OP_1 && opcode <= txscript.OP_16:
+			token.data = []byte{opcode - (txscript.OP_1 - 1)}
+		case opcode <= txscript.OP_PUSHDATA4:
+			// 复制数据，避免引用原始交易的内存
+			token.data = append([]byte{}, tokenizer.Data()...)
+		}
+		tokens = append(tokens, token)
 	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
 
-	chunkIdx := 0
-	opcodeIdx := 0
+// trimInscriptionUnlockTail 校验unlock脚本末尾的签名和lock脚本，返回前面的partial token
+// unlock脚本结构: partial + 签名 + lock脚本（见buildInscriptionUnlockScript），
+// lock脚本结构: 公钥 + OP_CHECKSIGVERIFY + (partial中每个chunk一个OP_DROP) + OP_TRUE
+func trimInscriptionUnlockTail(tokens []scriptToken) ([]scriptToken, error) {
+	if len(tokens) < 3 {
+		return nil, fmt.Errorf("签名脚本中没有inscription数据")
+	}
 
-	// 第一个chunk应该是 'ord'
-	if string(chunks[chunkIdx]) != "ord" {
-		return nil, fmt.Errorf("不是有效的Doginal格式，缺少'ord'标识符")
+	// 签名: DER编码（8到72字节）+ 1字节hashType
+	signature := tokens[len(tokens)-2]
+	if !signature.isPush() || len(signature.data) < 9 || len(signature.data) > 73 {
+		return nil, fmt.Errorf("签名脚本的倒数第二项不是签名")
 	}
-	chunkIdx++
 
-	// 第二个应该是parts数量（OP code）
-	// 跳过 'ord' chunk 对应的 opcode
-	for opcodeIdx < len(opcodes) {
-		if opcodes[opcodeIdx] == 3 { // 'ord' 长度是3
-			opcodeIdx++
-			break
+	lock := tokens[len(tokens)-1]
+	if !lock.isPush() {
+		return nil, fmt.Errorf("签名脚本的最后一项不是lock脚本")
+	}
+	lockTokens, err := tokenizeScript(lock.data)
+	if err != nil {
+		return nil, fmt.Errorf("解析lock脚本失败: %w", err)
+	}
+	if len(lockTokens) < 3 {
+		return nil, fmt.Errorf("lock脚本格式错误")
+	}
+	publicKey := lockTokens[0]
+	if !publicKey.isPush() || (len(publicKey.data) != 33 && len(publicKey.data) != 65) {
+		return nil, fmt.Errorf("lock脚本缺少公钥")
+	}
+	if lockTokens[1].opcode != txscript.OP_CHECKSIGVERIFY {
+		return nil, fmt.Errorf("lock脚本缺少OP_CHECKSIGVERIFY")
+	}
+	if lockTokens[len(lockTokens)-1].opcode != txscript.OP_TRUE {
+		return nil, fmt.Errorf("lock脚本缺少OP_TRUE")
+	}
+	dropCount := 0
+	for _, token := range lockTokens[2 : len(lockTokens)-1] {
+		if token.opcode != txscript.OP_DROP {
+			return nil, fmt.Errorf("lock脚本包含意外的操作码: 0x%x", token.opcode)
 		}
-		opcodeIdx++
+		dropCount++
 	}
 
-	if opcodeIdx >= len(opcodes) {
-		return nil, fmt.Errorf("无法找到parts数量")
+	partial := tokens[:len(tokens)-2]
+	if dropCount != len(partial) {
+		return nil, fmt.Errorf("lock脚本的OP_DROP数量(%d)与partial的chunk数量(%d)不一致", dropCount, len(partial))
 	}
+	return partial, nil
+}
 
-	// 解析parts数量
-	partsOpcode := opcodes[opcodeIdx]
-	if partsOpcode == 0x00 {
-		result.PartsCount = 0
-	} else if partsOpcode >= 0x51 && partsOpcode <= 0x60 { // OP_1 到 OP_16
-		result.PartsCount = int(partsOpcode - 0x50)
-	} else {
-		return nil, fmt.Errorf("无法解析parts数量，opcode: 0x%x", partsOpcode)
+// parseDoginalNumber 解析doginals.js的numberToChunk编码的数字，是addNumberToScript的逆过程
+// 0: OP_0，1-16: OP_1到OP_16，17-127: OP_DATA_1，128-65535: OP_DATA_2（小端序）
+// 不是numberToChunk能产生的编码（例如用OP_DATA_1表示5）视为错误
+func parseDoginalNumber(token scriptToken) (int, error) {
+	switch {
+	case token.opcode == txscript.OP_0:
+		return 0, nil
+	case token.opcode >= txscript.OP_1 && token.opcode <= txscript.OP_16:
+		return int(token.opcode - (txscript.OP_1 - 1)), nil
+	case token.opcode == txscript.OP_DATA_1 && len(token.data) == 1:
+		n := int(token.data[0])
+		if n <= 16 || n >= 128 {
+			return 0, fmt.Errorf("OP_DATA_1编码的数字%d不是最小编码", n)
+		}
+		return n, nil
+	case token.opcode == txscript.OP_DATA_2 && len(token.data) == 2:
+		n := int(token.data[0]) | int(token.data[1])<<8
+		if n < 128 {
+			return 0, fmt.Errorf("OP_DATA_2编码的数字%d不是最小编码", n)
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("opcode: 0x%x", token.opcode)
 	}
-	opcodeIdx++
+}
+
+// Doginal解析状态，依次期待的token
+const (
+	doginalStateTag         = iota // 'ord'标识符
+	doginalStatePartsCount         // parts数量
+	doginalStateContentType        // contentType
+	doginalStateIndex              // 数据块索引，或结束
+	doginalStateData               // 数据块
+)
 
-	// 第三个chunk是contentType
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少contentType")
+// parseDoginalInscription 解析Doginal格式的inscription
+// 结构: 'ord' + parts数量 + contentType + (索引 + 数据块)*，索引从parts数量-1递减到0
+// 一个partial中可能只包含部分数据块，此时Index为第一个数据块的索引，Data为这些数据块的拼接；
+// 交易链的后续partial只有(索引 + 数据块)*，此时Continuation为true
+func parseDoginalInscription(tokens []scriptToken) (*InscriptionData, error) {
+	result := &InscriptionData{
+		Format: InscriptionFormatDoginal,
+		Data:   []byte{},
 	}
-	result.ContentType = string(chunks[chunkIdx])
-	chunkIdx++
 
-	// 第四个应该是索引（OP code）
-	// 跳过 contentType chunk 对应的 opcode
-	for opcodeIdx < len(opcodes) && len(chunks) > chunkIdx-1 {
-		if int(opcodes[opcodeIdx]) == len(chunks[chunkIdx-1]) {
-			opcodeIdx++
-			break
+	state := doginalStateTag
+	pieces := 0
+	for _, token := range tokens {
+		if !token.isPush() {
+			return nil, fmt.Errorf("%w: Doginal inscription包含非push操作码: 0x%x", ErrNotInscription, token.opcode)
 		}
-		opcodeIdx++
-	}
 
-	if opcodeIdx >= len(opcodes) {
-		return nil, fmt.Errorf("无法找到索引")
-	}
+		switch state {
+		case doginalStateTag:
+			if string(token.data) == "ord" {
+				state = doginalStatePartsCount
+				break
+			}
+			// 后续partial以第一个数据块的索引开头
+			index, err := parseDoginalNumber(token)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 不是有效的Doginal格式，缺少'ord'标识符", ErrNotInscription)
+			}
+			result.Continuation = true
+			result.Index = index
+			state = doginalStateData
 
-	// 解析索引
-	indexOpcode := opcodes[opcodeIdx]
-	if indexOpcode == 0x00 {
-		result.Index = 0
-	} else if indexOpcode >= 0x51 && indexOpcode <= 0x60 { // OP_1 到 OP_16
-		result.Index = int(indexOpcode - 0x50)
-	} else {
-		return nil, fmt.Errorf("无法解析索引，opcode: 0x%x", indexOpcode)
-	}
+		case doginalStatePartsCount:
+			partsCount, err := parseDoginalNumber(token)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 无法解析parts数量，%v", ErrNotInscription, err)
+			}
+			result.PartsCount = partsCount
+			state = doginalStateContentType
 
-	// 第五个chunk是数据
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少数据")
+		case doginalStateContentType:
+			result.ContentType = string(token.data)
+			state = doginalStateIndex
+
+		case doginalStateIndex:
+			index, err := parseDoginalNumber(token)
+			if err != nil {
+				return nil, fmt.Errorf("%w: 无法解析索引，%v", ErrNotInscription, err)
+			}
+			if !result.Continuation && index >= result.PartsCount {
+				return nil, fmt.Errorf("%w: 索引%d超出parts数量%d", ErrNotInscription, index, result.PartsCount)
+			}
+			if pieces == 0 {
+				result.Index = index
+			} else if index != result.Index-pieces {
+				return nil, fmt.Errorf("%w: 索引不连续: 期望%d，实际%d", ErrNotInscription, result.Index-pieces, index)
+			}
+			state = doginalStateData
+
+		case doginalStateData:
+			result.Data = append(result.Data, token.chunkData()...)
+			pieces++
+			state = doginalStateIndex
+		}
 	}
-	result.Data = chunks[chunkIdx]
 
+	switch state {
+	case doginalStateTag:
+		return nil, fmt.Errorf("%w: 不是有效的Doginal格式，缺少'ord'标识符", ErrNotInscription)
+	case doginalStatePartsCount:
+		return nil, fmt.Errorf("%w: 无法找到parts数量", ErrNotInscription)
+	case doginalStateContentType:
+		return nil, fmt.Errorf("%w: 缺少contentType", ErrNotInscription)
+	case doginalStateData:
+		return nil, fmt.Errorf("%w: 缺少数据", ErrNotInscription)
+	}
+	if pieces == 0 && result.PartsCount > 0 {
+		return nil, fmt.Errorf("%w: 缺少数据", ErrNotInscription)
+	}
 	return result, nil
 }
 
-// parseMetaIDInscription 解析MetaID格式的inscription
-func parseMetaIDInscription(chunks [][]byte, opcodes []byte) (*InscriptionData, error) {
-	if len(chunks) < 7 {
-		return nil, fmt.Errorf("MetaID格式至少需要7个chunks")
-	}
+// MetaID解析状态，依次期待的字段
+const (
+	metaIDStateTag         = iota // 'metaid'标识符
+	metaIDStateOperation          // 操作类型
+	metaIDStatePath               // 路径
+	metaIDStateEncryption         // 加密标志
+	metaIDStateVersion            // 版本
+	metaIDStateContentType        // 内容类型
+	metaIDStatePayload            // payload，可能有多个chunk，也可能没有
+)
 
+// parseMetaIDInscription 解析MetaID格式的inscription
+// 结构: 'metaid' + operation + path + encryption + version + contentType + payload*
+// init操作可以只有'metaid' + 'init'
+func parseMetaIDInscription(tokens []scriptToken) (*InscriptionData, error) {
 	result := &InscriptionData{
 		Format: InscriptionFormatMetaID,
+		Data:   []byte{},
 	}
 
-	chunkIdx := 0
+	state := metaIDStateTag
+	for _, token := range tokens {
+		if !token.isPush() {
+			return nil, fmt.Errorf("%w: MetaID inscription包含非push操作码: 0x%x", ErrNotInscription, token.opcode)
+		}
 
-	// 第一个chunk应该是 'metaid'
-	if string(chunks[chunkIdx]) != "metaid" {
-		return nil, fmt.Errorf("不是有效的MetaID格式，缺少'metaid'标识符")
+		switch state {
+		case metaIDStateTag:
+			if string(token.data) != "metaid" {
+				return nil, fmt.Errorf("%w: 不是有效的MetaID格式，缺少'metaid'标识符", ErrNotInscription)
+			}
+		case metaIDStateOperation:
+			result.Operation = string(token.data)
+		case metaIDStatePath:
+			result.Path = string(token.data)
+		case metaIDStateEncryption:
+			result.Encryption = string(token.data)
+		case metaIDStateVersion:
+			result.Version = string(token.data)
+		case metaIDStateContentType:
+			result.ContentType = string(token.data)
+		case metaIDStatePayload:
+			result.Data = append(result.Data, token.chunkData()...)
+		}
+		if state != metaIDStatePayload {
+			state++
+		}
 	}
-	chunkIdx++
 
-	// 第二个chunk是操作类型
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少操作类型")
+	switch {
+	case state == metaIDStateTag:
+		return nil, fmt.Errorf("%w: 不是有效的MetaID格式，缺少'metaid'标识符", ErrNotInscription)
+	case state == metaIDStateOperation:
+		return nil, fmt.Errorf("%w: 缺少操作类型", ErrNotInscription)
+	case state == metaIDStatePath && result.Operation == "init":
+		return result, nil
+	case state == metaIDStatePath:
+		return nil, fmt.Errorf("%w: 缺少路径", ErrNotInscription)
+	case state == metaIDStateEncryption:
+		return nil, fmt.Errorf("%w: 缺少加密标志", ErrNotInscription)
+	case state == metaIDStateVersion:
+		return nil, fmt.Errorf("%w: 缺少版本", ErrNotInscription)
+	case state == metaIDStateContentType:
+		return nil, fmt.Errorf("%w: 缺少内容类型", ErrNotInscription)
 	}
-	result.Operation = string(chunks[chunkIdx])
-	chunkIdx++
 
-	// 第三个chunk是路径（也是contentType）
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少路径")
+	// 旧版插件（inscribe.ts）把contentType写在第3项、path写在第6项，链上已有这样的PIN，按内容识别后交换
+	if !isMetaIDPath(result.Path) && isMetaIDPath(result.ContentType) {
+		result.Path, result.ContentType = result.ContentType, result.Path
 	}
-	result.Path = string(chunks[chunkIdx])
-	result.ContentType = result.Path // path就是contentType
-	chunkIdx++
+	return result, nil
+}
 
-	// 第四个chunk是加密标志
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少加密标志")
-	}
-	result.Encryption = string(chunks[chunkIdx])
-	chunkIdx++
+// isMetaIDPath path以/开头，或者是 host:/path 的形式；MIME类型不会是这两种形式
+func isMetaIDPath(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.Contains(s, ":/")
+}
 
-	// 第五个chunk是版本
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少版本")
+// decryptMetaIDInscription 用decrypter解密加密的MetaID PIN的body
+// 链上的加密PIN大多不是发给当前密钥的，密钥不匹配时返回原始密文，Decrypted为false
+func decryptMetaIDInscription(result *InscriptionData, decrypter InscriptionDecrypter) (*InscriptionData, error) {
+	if result.Encryption == "" || result.Encryption == PinEncryptionNone {
+		return result, nil
 	}
-	result.Version = string(chunks[chunkIdx])
-	chunkIdx++
-
-	// 第六个chunk是contentType（实际的内容类型）
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少内容类型")
+	plain, err := decrypter(result.Encryption, result.Data)
+	if errors.Is(err, ErrPinKeyMismatch) {
+		return result, nil
 	}
-	result.ContentType = string(chunks[chunkIdx])
-	chunkIdx++
-
-	// 第七个及后续chunks是数据（可能有多个payload chunks）
-	if chunkIdx >= len(chunks) {
-		return nil, fmt.Errorf("缺少数据")
+	if err != nil {
+		return nil, fmt.Errorf("解密PIN失败: %w", err)
 	}
-
-	// 合并所有剩余的数据chunks
-	dataBuffer := make([]byte, 0)
-	for chunkIdx < len(chunks)-2 { // 最后两个是签名和lock脚本
-		dataBuffer = append(dataBuffer, chunks[chunkIdx]...)
-		chunkIdx++
+	if plain != nil {
+		result.Data = plain
+		result.Decrypted = true
 	}
-	result.Data = dataBuffer
-
 	return result, nil
 }
 
 // BuildDogeInscription 构建MetaID格式的inscription脚本
 func BuildDogeMetaIdInscription(data []byte, path string) ([]byte, error) {
-	var (
-		inscriptionBuilder          = txscript.NewScriptBuilder()
-		parts              [][]byte = make([][]byte, 0)
-	)
-
-	for i := int64(0); i < int64(len(data)); i += MAX_CHUNK_LEN {
-		end := int64(math.Min(float64(i+MAX_CHUNK_LEN), float64(len(data))))
-		parts = append(parts, data[i:end])
-	}
-
-	inscriptionBuilder.
-		AddData([]byte("metaid")). //<metaid_flag>
-		AddData([]byte("create"))  //<operation>
+	return buildDogeMetaIdInscription(data, path, PinEncryptionNone)
+}
 
-	inscriptionBuilder.AddData([]byte(path))    //<path>
-	inscriptionBuilder.AddData([]byte("0"))     //<Encryption>
-	inscriptionBuilder.AddData([]byte("0.0.1")) //<version>
-	//inscriptionBuilder.AddData([]byte("image/jpeg;binary"))    //<content-type>
-	inscriptionBuilder.AddData([]byte("application/json")) //<content-type>
-	for _, part := range parts {
-		inscriptionBuilder.AddData(part) //<payload>
+// BuildDogeMetaIdEciesInscription 构建body经ECIES加密给recipient的MetaID inscription脚本（加密标志"1"）
+// recipient用对应私钥通过ParseInscriptionFromTxWithKey即可读出明文
+func BuildDogeMetaIdEciesInscription(data []byte, path string, recipient *btcec.PublicKey) ([]byte, error) {
+	encrypted, err := EciesEncrypt(data, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("ECIES加密失败: %w", err)
 	}
+	return buildDogeMetaIdInscription(encrypted, path, PinEncryptionEcies)
+}
 
-	inscriptionScript, err := inscriptionBuilder.Script()
+// BuildDogeMetaIdEcdhInscription 构建body经ECDH协商密钥加密的MetaID inscription脚本（加密标志"2"）
+// sender为发送方私钥，recipientPubKey为接收方的P-256公钥（插件ECDH接口的ecdhPubKey）
+func BuildDogeMetaIdEcdhInscription(data []byte, path string, sender *btcec.PrivateKey, recipientPubKey []byte) ([]byte, error) {
+	encrypted, err := EcdhEncrypt(data, sender, recipientPubKey)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("ECDH加密失败: %w", err)
 	}
-	return inscriptionScript, nil
+	return buildDogeMetaIdInscription(encrypted, path, PinEncryptionEcdh)
+}
+
+// buildDogeMetaIdInscription 构建指定加密标志的MetaID inscription脚本，data为已经加密好的body
+func buildDogeMetaIdInscription(data []byte, path string, encryption string) ([]byte, error) {
+	return BuildDogeMetaIdPinInscription(&DogeMetaIdPin{
+		Path:       path,
+		Encryption: encryption,
+		Body:       data,
+	})
 }
 
 // addNumberToScript 按照JavaScript numberToChunk的逻辑添加数字到脚本
-// 对应doginals.js中的numberToChunk函数
+// 对应doginals.js中的numberToChunk函数，n不能超过MAX_DOGINAL_PARTS，解析见parseDoginalNumber
 func addNumberToScript(builder *txscript.ScriptBuilder, n int) *txscript.ScriptBuilder {
 	if n == 0 {
 		// OP_0
@@ -349,17 +601,28 @@ func addNumberToScript(builder *txscript.ScriptBuilder, n int) *txscript.ScriptB
 // BuildDoginalInscription 构建Doginal格式的inscription脚本
 // 完全按照doginals.js的逻辑实现
 func BuildDoginalInscription(data []byte, contentType string) ([]byte, error) {
+	return buildDoginalInscription(data, contentType, int(MAX_CHUNK_LEN))
+}
+
+// buildDoginalInscription 按chunkLen把数据分块构建Doginal格式的inscription脚本
+func buildDoginalInscription(data []byte, contentType string, chunkLen int) ([]byte, error) {
 	// 1. 将数据分块
 	parts := make([][]byte, 0)
 	remainingData := data
 	for len(remainingData) > 0 {
-		chunkSize := int(math.Min(float64(MAX_CHUNK_LEN), float64(len(remainingData))))
+		chunkSize := int(math.Min(float64(chunkLen), float64(len(remainingData))))
 		part := remainingData[:chunkSize]
 		remainingData = remainingData[chunkSize:]
 		parts = append(parts, part)
 	}
+	if int64(len(parts)) > MAX_DOGINAL_PARTS {
+		return nil, fmt.Errorf("数据过大，parts数量%d超过上限%d", len(parts), MAX_DOGINAL_PARTS)
+	}
 
 	// 2. 构建inscription脚本，对应JavaScript中的inscription构建
+	// ScriptBuilder限制脚本不超过txscript.MaxScriptSize（10000字节），
+	// 而inscription脚本不会整体执行，只会拆分成partial放入unlock脚本，
+	// 所以每个数据块单独编码后再拼接，否则超过约40个parts就无法构建
 	inscriptionBuilder := txscript.NewScriptBuilder()
 	inscriptionBuilder.AddData([]byte("ord")) // 'ord'标识符
 
@@ -368,17 +631,22 @@ func BuildDoginalInscription(data []byte, contentType string) ([]byte, error) {
 
 	inscriptionBuilder.AddData([]byte(contentType)) // content type
 
-	// 3. 按照JavaScript逻辑倒序添加数据块（索引从大到小）
-	for i := 0; i < len(parts); i++ {
-		// 使用 addNumberToScript 代替 AddInt64
-		addNumberToScript(inscriptionBuilder, len(parts)-i-1) // 索引（倒序）
-		inscriptionBuilder.AddData(parts[i])                  // 数据块
-	}
-
 	inscriptionScript, err := inscriptionBuilder.Script()
 	if err != nil {
 		return nil, err
 	}
+
+	// 3. 按照JavaScript逻辑倒序添加数据块（索引从大到小）
+	for i := 0; i < len(parts); i++ {
+		// 使用 addNumberToScript 代替 AddInt64
+		partBuilder := addNumberToScript(txscript.NewScriptBuilder(), len(parts)-i-1) // 索引（倒序）
+		partBuilder.AddData(parts[i])                                                 // 数据块
+		partScript, err := partBuilder.Script()
+		if err != nil {
+			return nil, err
+		}
+		inscriptionScript = append(inscriptionScript, partScript...)
+	}
 	return inscriptionScript, nil
 }
 
@@ -456,6 +724,101 @@ func countScriptChunks(script []byte) int {
 	return count
 }
 
+// splitScriptChunks 将脚本拆分为每个操作码（包括push的数据）对应的原始字节
+func splitScriptChunks(script []byte) ([][]byte, error) {
+	chunks := make([][]byte, 0)
+	tokenizer := txscript.MakeScriptTokenizer(0, script)
+	start := int32(0)
+	for tokenizer.Next() {
+		end := tokenizer.ByteIndex()
+		chunks = append(chunks, script[start:end])
+		start = end
+	}
+	if err := tokenizer.Err(); err != nil {
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// splitInscriptionPartials 按照doginals.js的逻辑将inscription脚本拆分为多个partial
+// 第一个partial先放入第一个chunk，之后每次放入两个chunk直到超过maxPayloadLen（默认MAX_PAYLOAD_LEN），
+// 超过时把最后放入的chunk退回，留给下一个partial
+func splitInscriptionPartials(inscriptionScript []byte, maxPayloadLen int) ([][]byte, error) {
+	chunks, err := splitScriptChunks(inscriptionScript)
+	if err != nil {
+		return nil, err
+	}
+
+	partials := make([][]byte, 0)
+	for len(chunks) > 0 {
+		partialChunks := make([][]byte, 0)
+		partialLen := 0
+
+		if len(partials) == 0 {
+			partialChunks = append(partialChunks, chunks[0])
+			partialLen += len(chunks[0])
+			chunks = chunks[1:]
+		}
+
+		lastAdded := 0
+		for partialLen <= maxPayloadLen && len(chunks) > 0 {
+			lastAdded = 2
+			if len(chunks) < lastAdded {
+				lastAdded = len(chunks)
+			}
+			for _, chunk := range chunks[:lastAdded] {
+				partialChunks = append(partialChunks, chunk)
+				partialLen += len(chunk)
+			}
+			chunks = chunks[lastAdded:]
+		}
+
+		// 超过长度限制时退回最后放入的chunk，但不能让partial为空
+		if partialLen > maxPayloadLen && len(partialChunks) > lastAdded {
+			keep := len(partialChunks) - lastAdded
+			chunks = append(append([][]byte{}, partialChunks[keep:]...), chunks...)
+			partialChunks = partialChunks[:keep]
+		}
+
+		partials = append(partials, bytes.Join(partialChunks, nil))
+	}
+	return partials, nil
+}
+
+// buildInscriptionLockScript 构建partial对应的lock脚本
+// 结构: 公钥 + OP_CHECKSIGVERIFY + (partial中每个chunk一个OP_DROP) + OP_TRUE
+func buildInscriptionLockScript(publicKeyBytes []byte, partialScript []byte) ([]byte, error) {
+	lockBuilder := txscript.NewScriptBuilder()
+	lockBuilder.AddData(publicKeyBytes)           // 添加公钥
+	lockBuilder.AddOp(txscript.OP_CHECKSIGVERIFY) // 添加签名验证
+
+	// 对应JavaScript: partial.chunks.forEach(() => { lock.chunks.push(opcodeToChunk(Opcode.OP_DROP)) })
+	partialChunkCount := countScriptChunks(partialScript)
+	for i := 0; i < partialChunkCount; i++ {
+		lockBuilder.AddOp(txscript.OP_DROP)
+	}
+	lockBuilder.AddOp(txscript.OP_TRUE)
+
+	return lockBuilder.Script()
+}
+
+// buildInscriptionUnlockScript 构建花费P2SH输出的unlock脚本: partial + 签名 + lock脚本
+// partial是原始脚本字节，直接拼接以保留其中的OP codes；签名和lock脚本按标准push编码
+func buildInscriptionUnlockScript(partialScript []byte, signature []byte, lockScript []byte) ([]byte, error) {
+	tail, err := txscript.NewScriptBuilder().AddData(signature).AddData(lockScript).Script()
+	if err != nil {
+		return nil, err
+	}
+	// P2SH输入总是交易的第一个输入
+	if size := len(partialScript) + len(tail); size > DefaultDogePolicy.MaxScriptSigSize {
+		return nil, &ErrScriptSigTooLarge{Input: 0, Size: size, Limit: DefaultDogePolicy.MaxScriptSigSize}
+	}
+	unlockScript := make([]byte, 0, len(partialScript)+len(tail))
+	unlockScript = append(unlockScript, partialScript...)
+	unlockScript = append(unlockScript, tail...)
+	return unlockScript, nil
+}
+
 // BuildDogeP2SHScript 构建P2SH脚本
 // 对应JavaScript中的p2sh脚本构建逻辑
 func BuildDogeP2SHScript(lockScript []byte) ([]byte, error) {
@@ -487,19 +850,19 @@ func BuildDogeP2SHInscriptionTx(data []byte, contentType string, publicKeyBytes
 	// 1. 构建inscription脚本
 	inscriptionScript, err := BuildDogeP2SHInscription(data, contentType, publicKeyBytes)
 	if err != nil {
-		return nil, fmt.Errorf("构建inscription脚本失败: %v", err)
+		return nil, fmt.Errorf("构建inscription脚本失败: %w", err)
 	}
 
 	// 2. 构建lock脚本
 	lockScript, err := BuildDogeP2SHLockScript(publicKeyBytes, inscriptionScript)
 	if err != nil {
-		return nil, fmt.Errorf("构建lock脚本失败: %v", err)
+		return nil, fmt.Errorf("构建lock脚本失败: %w", err)
 	}
 
 	// 3. 构建P2SH脚本
 	p2shScript, err := BuildDogeP2SHScript(lockScript)
 	if err != nil {
-		return nil, fmt.Errorf("构建P2SH脚本失败: %v", err)
+		return nil, fmt.Errorf("构建P2SH脚本失败: %w", err)
 	}
 
 	// 4. 构建交易
@@ -712,7 +1075,7 @@ func BuildDogeP2SHInscriptionWithChunking(data []byte, contentType string, publi
 // 	// 生成测试公钥
 // 	privateKey, err := btcec.NewPrivateKey()
 // 	if err != nil {
-// 		return fmt.Errorf("生成私钥失败: %v", err)
+// 		return fmt.Errorf("生成私钥失败: %w", err)
 // 	}
 // 	publicKeyBytes := privateKey.PubKey().SerializeCompressed()
 
@@ -724,28 +1087,28 @@ func BuildDogeP2SHInscriptionWithChunking(data []byte, contentType string, publi
 // 	// 测试基本inscription构建
 // 	inscriptionScript, err := BuildDogeP2SHInscription(testData, contentType, publicKeyBytes)
 // 	if err != nil {
-// 		return fmt.Errorf("构建inscription脚本失败: %v", err)
+// 		return fmt.Errorf("构建inscription脚本失败: %w", err)
 // 	}
 // 	fmt.Printf("Inscription脚本长度: %d 字节\n", len(inscriptionScript))
 
 // 	// 测试lock脚本构建
 // 	lockScript, err := BuildDogeP2SHLockScript(publicKeyBytes, inscriptionScript)
 // 	if err != nil {
-// 		return fmt.Errorf("构建lock脚本失败: %v", err)
+// 		return fmt.Errorf("构建lock脚本失败: %w", err)
 // 	}
 // 	fmt.Printf("Lock脚本长度: %d 字节\n", len(lockScript))
 
 // 	// 测试P2SH脚本构建
 // 	p2shScript, err := BuildDogeP2SHScript(lockScript)
 // 	if err != nil {
-// 		return fmt.Errorf("构建P2SH脚本失败: %v", err)
+// 		return fmt.Errorf("构建P2SH脚本失败: %w", err)
 // 	}
 // 	fmt.Printf("P2SH脚本长度: %d 字节\n", len(p2shScript))
 
 // 	// 测试完整交易构建
 // 	tx, err := BuildDogeP2SHInscriptionTx(testData, contentType, publicKeyBytes, "", 100000)
 // 	if err != nil {
-// 		return fmt.Errorf("构建交易失败: %v", err)
+// 		return fmt.Errorf("构建交易失败: %w", err)
 // 	}
 // 	fmt.Printf("交易输出数量: %d\n", len(tx.TxOut))
 // 	fmt.Printf("交易输出值: %d satoshis\n", tx.TxOut[0].Value)
@@ -753,7 +1116,7 @@ func BuildDogeP2SHInscriptionWithChunking(data []byte, contentType string, publi
 // 	// 测试分块处理
 // 	txs, err := BuildDogeP2SHInscriptionWithChunking(testData, contentType, publicKeyBytes)
 // 	if err != nil {
-// 		return fmt.Errorf("构建分块交易失败: %v", err)
+// 		return fmt.Errorf("构建分块交易失败: %w", err)
 // 	}
 // 	fmt.Printf("分块交易数量: %d\n", len(txs))
 
@@ -761,17 +1124,21 @@ func BuildDogeP2SHInscriptionWithChunking(data []byte, contentType string, publi
 // 	return nil
 // }
 
+// BuildDogeCommonTx 构建普通转账交易，outs的金额不能低于DogeDustLimit，
+// 找零低于DogeDustLimit时不添加找零输出
 func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*TxOutput, changeAddress string, feeRate int64, isUnSign bool) (*wire.MsgTx, error) {
 	tx := wire.NewMsgTx(2)
 	totalAmount := int64(0)
 	outAmount := int64(0)
 	emptySegwitWitenss := wire.TxWitness{make([]byte, 71), make([]byte, 33)}
 	emptyTaprootWitness := wire.TxWitness{make([]byte, 64)}
-	emptyNestSignature := make([]byte, 23)
 	emptylegacySignature := make([]byte, 107)
 
 	for _, out := range outs {
-		addr, err := btcutil.DecodeAddress(out.Address, netParam)
+		if out.Amount < DogeDustLimit {
+			return nil, &ErrDustOutput{Address: out.Address, Amount: out.Amount, Limit: DogeDustLimit}
+		}
+		addr, err := decodeDogeAddress(out.Address, netParam)
 		if err != nil {
 			return nil, err
 		}
@@ -783,7 +1150,7 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 		outAmount = outAmount + out.Amount
 	}
 	if changeAddress != "" {
-		addr, err := btcutil.DecodeAddress(changeAddress, netParam)
+		addr, err := decodeDogeAddress(changeAddress, netParam)
 		if err != nil {
 			return nil, err
 		}
@@ -798,7 +1165,7 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 	for _, in := range ins {
 		hash, err := chainhash.NewHashFromStr(in.TxId)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: txid %s: %v", ErrInvalidUtxo, in.TxId, err)
 		}
 		prevOut := wire.NewOutPoint(hash, uint32(in.TxIndex))
 		txIn := wire.NewTxIn(prevOut, nil, nil)
@@ -807,7 +1174,7 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 
 		pkScriptByte, err := hex.DecodeString(in.PkScript)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %s:%d的pkScript: %v", ErrInvalidUtxo, in.TxId, in.TxIndex, err)
 		}
 		outPoint := txIn.PreviousOutPoint
 		txOut := wire.NewTxOut(int64(in.Amount), pkScriptByte)
@@ -818,21 +1185,18 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 	txBaseSize := tx.SerializeSizeStripped()
 
 	for _, in := range ins {
-		pkScriptByte, err := hex.DecodeString(in.PkScript)
-		if err != nil {
-			return nil, err
-		}
+		pkScriptByte, _ := hex.DecodeString(in.PkScript)
 		_, addrs, _, err := txscript.ExtractPkScriptAddrs(pkScriptByte, netParam)
 		if err != nil {
-			return nil, errors.New("extract address from pkScript")
+			return nil, fmt.Errorf("%w: 无法从%s:%d的pkScript中解析地址: %v", ErrInvalidUtxo, in.TxId, in.TxIndex, err)
 		}
 		if len(addrs) == 0 {
-			return nil, errors.New("extract address from pkScript")
+			return nil, fmt.Errorf("%w: 无法从%s:%d的pkScript中解析地址", ErrInvalidUtxo, in.TxId, in.TxIndex)
 		}
 		address := addrs[0].EncodeAddress()
 		addressClass, err := CheckAddressClass(netParam, address)
 		if err != nil {
-			fmt.Printf("CheckAddressClass err:%s\n", err.Error())
+			dogeLog().Warn("CheckAddressClass失败", "address", address, "err", err)
 			continue
 		}
 		if addressClass == txscript.WitnessV1TaprootTy {
@@ -840,8 +1204,8 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 		} else if addressClass == txscript.PubKeyHashTy {
 			txBaseSize += 40 + wire.VarIntSerializeSize(uint64(len(emptylegacySignature))) + len(emptylegacySignature)
 		} else if addressClass == txscript.ScriptHashTy {
-			txBaseSize += 40 + wire.VarIntSerializeSize(uint64(len(emptyNestSignature))) + len(emptyNestSignature)
-			txTotalSize += emptySegwitWitenss.SerializeSize()
+			// Dogecoin没有SegWit，P2SH不是嵌套SegWit，签名脚本取决于赎回脚本，这里既无法估算也无法签名
+			return nil, fmt.Errorf("%w: 输入地址%s是P2SH，多签UTXO请使用BuildDogeMultisigTx", ErrInvalidUtxo, address)
 		} else {
 			txTotalSize += emptySegwitWitenss.SerializeSize()
 		}
@@ -852,30 +1216,30 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 	vSize := (weight + (blockchain.WitnessScaleFactor - 1)) / blockchain.WitnessScaleFactor
 	txFee := vSize * feeRate
 
-	fmt.Printf("vSize:%d, txFee:%d, feeRate:%d, totalAmount:%d, outAmount:%d\n", vSize, txFee, feeRate, totalAmount, outAmount)
+	dogeLog().Debug("计算交易手续费", "vSize", vSize, "txFee", txFee, "feeRate", feeRate, "totalAmount", totalAmount, "outAmount", outAmount)
 	if totalAmount-outAmount < int64(txFee) {
-		return nil, errors.New("insufficient fee")
+		return nil, &ErrInsufficientFunds{Needed: outAmount + txFee, Available: totalAmount}
 	}
 
+	// 找零低于dust限制，移除找零输出，这部分金额并入手续费；没有找零地址时没有找零输出
 	changeVal := totalAmount - outAmount - int64(txFee)
-	if changeVal >= 600 && changeAddress != "" {
-		tx.TxOut[len(tx.TxOut)-1].Value = changeVal
-	} else {
-		tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
+	if changeAddress != "" {
+		if changeVal >= DogeDustLimit {
+			tx.TxOut[len(tx.TxOut)-1].Value = changeVal
+		} else {
+			tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
+		}
 	}
 
 	if !isUnSign {
 		for i, in := range ins {
 			privateKeyBytes, err := hex.DecodeString(in.PriHex)
-			if err != nil {
-				return nil, err
+			if err != nil || len(privateKeyBytes) != btcec.PrivKeyBytesLen {
+				return nil, fmt.Errorf("%w: %s:%d的私钥无法解码", ErrInvalidUtxo, in.TxId, in.TxIndex)
 			}
 			privateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
 
-			pkScriptByte, err := hex.DecodeString(in.PkScript)
-			if err != nil {
-				return nil, err
-			}
+			pkScriptByte, _ := hex.DecodeString(in.PkScript)
 
 			var witnessScript wire.TxWitness
 			var sigScript []byte
@@ -885,14 +1249,15 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 					tx, sigHashes, i, int64(in.Amount), pkScriptByte,
 					txscript.SigHashDefault, privateKey)
 				if err != nil {
-					fmt.Println(err)
-					return nil, err
+					dogeLog().Error("签名输入失败", "input", i, "signMode", in.SignMode, "err", err)
+					return nil, fmt.Errorf("签名输入%d失败: %w", i, err)
 				}
 			} else if in.SignMode == SignModeLegacy {
-				sigScript, err = txscript.RawTxInSignature(tx, i, pkScriptByte, txscript.SigHashAll, privateKey)
+				// P2PKH签名脚本: <签名> <压缩公钥>
+				sigScript, err = txscript.SignatureScript(tx, i, pkScriptByte, txscript.SigHashAll, privateKey, true)
 				if err != nil {
-					fmt.Println(err)
-					return nil, err
+					dogeLog().Error("签名输入失败", "input", i, "signMode", in.SignMode, "err", err)
+					return nil, fmt.Errorf("签名输入%d失败: %w", i, err)
 				}
 			} else {
 				prevOutputFetcher := NewPrevOutputFetcher(pkScriptByte, int64(in.Amount))
@@ -902,8 +1267,8 @@ func BuildDogeCommonTx(netParam *chaincfg.Params, ins []*TxInputUtxo, outs []*Tx
 					txscript.SigHashAll|txscript.SigHashAnyOneCanPay, privateKey, true,
 				)
 				if err != nil {
-					fmt.Println(err)
-					return nil, err
+					dogeLog().Error("签名输入失败", "input", i, "signMode", in.SignMode, "err", err)
+					return nil, fmt.Errorf("签名输入%d失败: %w", i, err)
 				}
 
 			}
@@ -927,6 +1292,24 @@ func fundTransaction(
 	feeRate int64,
 	existingInputAmount int64,
 	estimatedSigSize int,
+	log *slog.Logger,
+) (usedUtxos []*TxInputUtxo, changeOutputIndex int, remainingUtxos []*TxInputUtxo, err error) {
+	return fundTransactionWithInputSize(tx, availableUtxos, changeAddress, netParam, feeRate,
+		existingInputAmount, estimatedSigSize, 107, log) // Legacy签名约107字节
+}
+
+// fundTransactionWithInputSize 与fundTransaction相同，inputSigSize为每个新增UTXO输入的签名脚本大小，
+// 例如多签UTXO的签名脚本远大于P2PKH
+func fundTransactionWithInputSize(
+	tx *wire.MsgTx,
+	availableUtxos []*TxInputUtxo,
+	changeAddress string,
+	netParam *chaincfg.Params,
+	feeRate int64,
+	existingInputAmount int64,
+	estimatedSigSize int,
+	inputSigSize int,
+	log *slog.Logger,
 ) (usedUtxos []*TxInputUtxo, changeOutputIndex int, remainingUtxos []*TxInputUtxo, err error) {
 	changeOutputIndex = -1
 	remainingUtxos = make([]*TxInputUtxo, len(availableUtxos))
@@ -941,13 +1324,13 @@ func fundTransaction(
 
 	// 添加找零输出占位符（如果需要）
 	if changeAddress != "" {
-		changeAddr, err := btcutil.DecodeAddress(changeAddress, netParam)
+		changeAddr, err := decodeDogeAddress(changeAddress, netParam)
 		if err != nil {
-			return nil, -1, nil, fmt.Errorf("解码找零地址失败: %v", err)
+			return nil, -1, nil, fmt.Errorf("解码找零地址失败: %w", err)
 		}
 		changePkScript, err := txscript.PayToAddrScript(changeAddr)
 		if err != nil {
-			return nil, -1, nil, fmt.Errorf("构建找零地址脚本失败: %v", err)
+			return nil, -1, nil, fmt.Errorf("构建找零地址脚本失败: %w", err)
 		}
 		changeTxOut := wire.NewTxOut(0, changePkScript)
 		tx.AddTxOut(changeTxOut)
@@ -959,8 +1342,8 @@ func fundTransaction(
 	// 添加UTXO直到有足够的资金
 	for len(remainingUtxos) > 0 {
 		// 计算当前需要的总金额（输出 + 手续费）
-		tempTxSize := tx.SerializeSize() + estimatedSigSize + len(usedUtxos)*107 // Legacy签名约107字节
-		estimatedFee := int64(tempTxSize) * feeRate                              // feeRate 单位是 satoshis/Byte
+		tempTxSize := tx.SerializeSize() + estimatedSigSize + len(usedUtxos)*inputSigSize
+		estimatedFee := int64(tempTxSize) * feeRate // feeRate 单位是 satoshis/Byte
 		requiredAmount := totalOutputAmount + estimatedFee
 
 		// 如果已经有足够的资金，停止添加
@@ -970,7 +1353,7 @@ func fundTransaction(
 
 		// 添加下一个UTXO
 		if len(remainingUtxos) == 0 {
-			return nil, -1, nil, fmt.Errorf("没有足够的UTXO来支付交易")
+			return nil, -1, nil, &ErrInsufficientFunds{Needed: requiredAmount, Available: totalInputAmount}
 		}
 
 		utxo := remainingUtxos[0]
@@ -978,59 +1361,78 @@ func fundTransaction(
 		usedUtxos = append(usedUtxos, utxo)
 
 		// 添加UTXO输入到交易
-		hash, err := chainhash.NewHashFromStr(utxo.TxId)
+		txIn, err := utxoTxIn(utxo)
 		if err != nil {
-			return nil, -1, nil, fmt.Errorf("解析TxId失败: %v", err)
+			return nil, -1, nil, err
 		}
-		prevOut := wire.NewOutPoint(hash, uint32(utxo.TxIndex))
-		txIn := wire.NewTxIn(prevOut, nil, nil)
 		tx.AddTxIn(txIn)
 
 		totalInputAmount += int64(utxo.Amount)
 	}
 
 	// 重新计算手续费
-	tempTxSize := tx.SerializeSize() + estimatedSigSize + len(usedUtxos)*107
+	tempTxSize := tx.SerializeSize() + estimatedSigSize + len(usedUtxos)*inputSigSize
 	finalFee := int64(tempTxSize) * feeRate // feeRate 单位是 satoshis/Byte
 
 	// 计算找零金额
 	changeAmount := totalInputAmount - totalOutputAmount - finalFee
 	if changeAmount < 0 {
-		return nil, -1, nil, fmt.Errorf("资金不足: 输入=%d, 输出=%d, 手续费=%d", totalInputAmount, totalOutputAmount, finalFee)
+		return nil, -1, nil, &ErrInsufficientFunds{Needed: totalOutputAmount + finalFee, Available: totalInputAmount}
 	}
 
 	// 更新找零输出金额
 	if changeOutputIndex >= 0 {
-		if changeAmount >= 600 {
+		if changeAmount >= DogeDustLimit {
 			tx.TxOut[changeOutputIndex].Value = changeAmount
 		} else {
-			// 找零太少，移除找零输出
+			// 找零低于dust限制，移除找零输出，这部分金额并入手续费
 			tx.TxOut = tx.TxOut[:changeOutputIndex]
 			changeOutputIndex = -1
 		}
 	}
 
-	//打印用了哪个utxo，找回哪个utxo
-	fmt.Printf("tempTxSize:%d, estimatedSigSize:%d, len(usedUtxos):%d, feeRate:%d, finalFee:%d, changeAmount:%d\n", tempTxSize, estimatedSigSize, len(usedUtxos), feeRate, finalFee, changeAmount)
-	fmt.Printf("usedUtxos:%d\n", len(usedUtxos))
-	for _, utxo := range usedUtxos {
-		fmt.Printf("usedUtxo:%+v\n", utxo)
-	}
-	fmt.Printf("remainingUtxos:%d\n", len(remainingUtxos))
-	for _, utxo := range remainingUtxos {
-		fmt.Printf("remainingUtxo:%+v\n", utxo)
-	}
-	fmt.Printf("changeOutputIndex:%d\n", changeOutputIndex)
+	//记录用了哪个utxo，找回哪个utxo
+	log.Debug("fund交易",
+		"tempTxSize", tempTxSize,
+		"estimatedSigSize", estimatedSigSize,
+		"inputSigSize", inputSigSize,
+		"feeRate", feeRate,
+		"finalFee", finalFee,
+		"changeAmount", changeAmount,
+		"changeOutputIndex", changeOutputIndex,
+		"usedUtxos", utxosLogValue(usedUtxos),
+		"remainingUtxos", utxosLogValue(remainingUtxos),
+	)
 
 	return usedUtxos, changeOutputIndex, remainingUtxos, nil
 }
 
+// utxoTxIn 创建花费utxo的输入，签名脚本为空
+func utxoTxIn(utxo *TxInputUtxo) (*wire.TxIn, error) {
+	hash, err := chainhash.NewHashFromStr(utxo.TxId)
+	if err != nil {
+		return nil, fmt.Errorf("解析TxId失败: %w", err)
+	}
+	return wire.NewTxIn(wire.NewOutPoint(hash, uint32(utxo.TxIndex)), nil, nil), nil
+}
+
 // signTransactionInputs 为交易的UTXO输入签名
 // 对应JavaScript中fund函数里的签名逻辑
 func signTransactionInputs(
 	tx *wire.MsgTx,
 	usedUtxos []*TxInputUtxo,
 	startIndex int,
+) error {
+	return signTransactionInputsWithHashType(tx, usedUtxos, startIndex, txscript.SigHashAll)
+}
+
+// signTransactionInputsWithHashType 使用指定的签名类型为交易的UTXO输入签名，
+// 例如挂单时卖家使用SigHashSingle|SigHashAnyOneCanPay，只承诺自己的输入和对应的价格输出
+func signTransactionInputsWithHashType(
+	tx *wire.MsgTx,
+	usedUtxos []*TxInputUtxo,
+	startIndex int,
+	hashType txscript.SigHashType,
 ) error {
 	for i, utxo := range usedUtxos {
 		inputIndex := startIndex + i
@@ -1038,20 +1440,20 @@ func signTransactionInputs(
 		// 解码私钥
 		privateKeyBytes, err := hex.DecodeString(utxo.PriHex)
 		if err != nil {
-			return fmt.Errorf("解码私钥失败: %v", err)
+			return fmt.Errorf("解码私钥失败: %w", err)
 		}
 		utxoPrivateKey, _ := btcec.PrivKeyFromBytes(privateKeyBytes)
 
 		// 解码pkScript
 		pkScriptBytes, err := hex.DecodeString(utxo.PkScript)
 		if err != nil {
-			return fmt.Errorf("解码pkScript失败: %v", err)
+			return fmt.Errorf("解码pkScript失败: %w", err)
 		}
 
 		// 使用 RawTxInSignature 进行签名
-		signature, err := txscript.RawTxInSignature(tx, inputIndex, pkScriptBytes, txscript.SigHashAll, utxoPrivateKey)
+		signature, err := txscript.RawTxInSignature(tx, inputIndex, pkScriptBytes, hashType, utxoPrivateKey)
 		if err != nil {
-			return fmt.Errorf("UTXO签名失败: %v", err)
+			return fmt.Errorf("UTXO签名失败: %w", err)
 		}
 
 		// 构建完整的签名脚本：签名 + 公钥
@@ -1060,7 +1462,7 @@ func signTransactionInputs(
 		sigBuilder.AddData(utxoPrivateKey.PubKey().SerializeCompressed())
 		sigScript, err := sigBuilder.Script()
 		if err != nil {
-			return fmt.Errorf("构建签名脚本失败: %v", err)
+			return fmt.Errorf("构建签名脚本失败: %w", err)
 		}
 
 		// 设置签名脚本
@@ -1072,14 +1474,18 @@ func signTransactionInputs(
 
 // updateWalletUtxos 更新可用的UTXO列表
 // 对应JavaScript中的updateWallet(wallet, tx)函数
-// 将交易的找零输出添加到可用UTXO列表中
+// 将交易的找零输出添加到可用UTXO列表中；
+// 交易没有使用钱包UTXO时（例如lock金额足以支付reveal交易）无法得到找零的私钥，找零不加入列表
 func updateWalletUtxos(
 	tx *wire.MsgTx,
 	availableUtxos []*TxInputUtxo,
 	changeOutputIndex int,
 	usedUtxos []*TxInputUtxo,
 ) []*TxInputUtxo {
-	if changeOutputIndex >= 0 && changeOutputIndex < len(tx.TxOut) && tx.TxOut[changeOutputIndex].Value >= 600 {
+	if len(usedUtxos) == 0 {
+		return availableUtxos
+	}
+	if changeOutputIndex >= 0 && changeOutputIndex < len(tx.TxOut) && tx.TxOut[changeOutputIndex].Value >= DogeDustLimit {
 		// 添加找零输出作为新的可用UTXO
 		txHash := tx.TxHash()
 		newUtxo := &TxInputUtxo{
@@ -1098,6 +1504,8 @@ func updateWalletUtxos(
 // BuildDogeMetaIdInscriptionTxs 构建Dogecoin inscription交易
 // 完全按照doginals.js的逻辑实现，支持Doginal和MetaID两种格式
 // format: InscriptionFormatDoginal 或 InscriptionFormatMetaID
+// outputValue为0时为100000；交易链中后一笔交易引用前一笔的txid，必须签名，所以isUnSign不起作用。
+// 新的参数（签名器、选币、lock金额等）请使用InscriptionBuilder
 func BuildDogeMetaIdInscriptionTxs(
 	netParam *chaincfg.Params,
 	inscriptionData []byte,
@@ -1115,16 +1523,74 @@ func BuildDogeMetaIdInscriptionTxs(
 	// 生成临时密钥对用于P2SH inscription
 	privateKey, err := btcec.NewPrivateKey()
 	if err != nil {
-		return nil, fmt.Errorf("生成私钥失败: %v", err)
+		return nil, fmt.Errorf("生成私钥失败: %w", err)
+	}
+	result, err := buildDogeMetaIdInscriptionTxsWithKey(privateKey, netParam, inscriptionData, contentType, ins,
+		outputAddress, outputValue, changeAddress, feeRate, isUnSign, format)
+	if err != nil {
+		return nil, err
 	}
+	return result.Txs, nil
+}
+
+// BuildDogeMetaIdInscriptionResult 与BuildDogeMetaIdInscriptionTxs相同，
+// 返回包含每笔交易明细和commitCost/revealCost/totalCost的报告，可以直接序列化为CreatePinResult；
+// 服务费和额外输出使用InscriptionBuilder的WithExtraOutputs
+func BuildDogeMetaIdInscriptionResult(
+	netParam *chaincfg.Params,
+	inscriptionData []byte,
+	contentType string,
+	ins []*TxInputUtxo,
+	outputAddress string,
+	outputValue int64,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+	isUnSign bool,
+	format InscriptionFormat,
+) (*DogeInscriptionResult, error) {
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("生成私钥失败: %w", err)
+	}
+	return buildDogeMetaIdInscriptionTxsWithKey(privateKey, netParam, inscriptionData, contentType, ins,
+		outputAddress, outputValue, changeAddress, feeRate, isUnSign, format)
+}
+
+// buildDogeMetaIdInscriptionTxsWithKey 使用指定的临时密钥构建inscription交易
+// 签名使用RFC6979确定性nonce，所以相同的密钥和输入总是得到相同的交易
+func buildDogeMetaIdInscriptionTxsWithKey(
+	privateKey *btcec.PrivateKey,
+	netParam *chaincfg.Params,
+	inscriptionData []byte,
+	contentType string,
+	ins []*TxInputUtxo,
+	outputAddress string,
+	outputValue int64,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+	isUnSign bool,
+	format InscriptionFormat,
+) (*DogeInscriptionResult, error) {
 	publicKeyBytes := privateKey.PubKey().SerializeCompressed()
 
-	fmt.Printf("\n=== P2SH Inscription 临时密钥对 ===\n")
-	fmt.Printf("私钥: %x\n", privateKey.Serialize())
-	fmt.Printf("公钥: %x\n", publicKeyBytes)
+	// 临时私钥不写入日志，只记录公钥
+	dogeLog().Debug("P2SH Inscription临时密钥对", "publicKey", hex.EncodeToString(publicKeyBytes))
 
 	// ===== 第二步：构建inscription脚本 =====
+	inscriptionScript, err := buildDogeInscriptionScript(inscriptionData, contentType, format)
+	if err != nil {
+		return nil, err
+	}
+
+	return buildDogeInscriptionScriptTxsWithKey(privateKey, netParam, inscriptionScript, ins,
+		outputAddress, outputValue, changeAddress, feeRate, isUnSign)
+}
+
+// buildDogeInscriptionScript 按格式构建inscription脚本
+// MetaID格式沿用BuildDogeMetaIdInscriptionTxs的约定，contentType参数作为path
+func buildDogeInscriptionScript(inscriptionData []byte, contentType string, format InscriptionFormat) ([]byte, error) {
 	var inscriptionScript []byte
+	var err error
 	if format == InscriptionFormatDoginal {
 		// Doginal格式: ord + parts.length + contentType + data
 		inscriptionScript, err = BuildDoginalInscription(inscriptionData, contentType)
@@ -1133,257 +1599,332 @@ func BuildDogeMetaIdInscriptionTxs(
 		inscriptionScript, err = BuildDogeMetaIdInscription(inscriptionData, contentType)
 	}
 	if err != nil {
-		return nil, fmt.Errorf("构建inscription脚本失败: %v", err)
+		return nil, fmt.Errorf("构建inscription脚本失败: %w", err)
 	}
+	return inscriptionScript, nil
+}
 
-	var txs []*wire.MsgTx
-	var p2shInput *wire.TxIn
-	var lastLock []byte
-	var lastPartial []byte
-
-	// 用于跟踪可用的UTXO（模拟JavaScript中的wallet.utxos）
-	availableUtxos := make([]*TxInputUtxo, len(ins))
-	copy(availableUtxos, ins)
-
-	// ===== 第三步：处理inscription脚本分块 =====
-	// 简化版本：如果inscription脚本小于MAX_PAYLOAD_LEN，直接作为一个partial
-	// 对于大文件，需要实现更复杂的分块逻辑
-	remainingInscription := inscriptionScript
+// BuildDogeInscriptionScriptTxs 把已经构建好的inscription脚本（例如BuildDogeMetaIdEciesInscription的结果）
+// 拆分为partial并构建P2SH交易链，参数含义同BuildDogeMetaIdInscriptionTxs；
+// 服务费和额外输出使用InscriptionBuilder的WithInscriptionScript和WithExtraOutputs
+func BuildDogeInscriptionScriptTxs(
+	netParam *chaincfg.Params,
+	inscriptionScript []byte,
+	ins []*TxInputUtxo,
+	outputAddress string,
+	outputValue int64,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+	isUnSign bool,
+) ([]*wire.MsgTx, error) {
+	result, err := BuildDogeInscriptionScriptResult(netParam, inscriptionScript, ins,
+		outputAddress, outputValue, changeAddress, feeRate, isUnSign)
+	if err != nil {
+		return nil, err
+	}
+	return result.Txs, nil
+}
 
-	for len(remainingInscription) > 0 {
-		// 确定当前partial的大小
-		partialSize := int(math.Min(float64(MAX_PAYLOAD_LEN), float64(len(remainingInscription))))
-		partialScript := remainingInscription[:partialSize]
-		remainingInscription = remainingInscription[partialSize:]
+// BuildDogeInscriptionScriptResult 与BuildDogeInscriptionScriptTxs相同，返回包含费用明细的报告
+func BuildDogeInscriptionScriptResult(
+	netParam *chaincfg.Params,
+	inscriptionScript []byte,
+	ins []*TxInputUtxo,
+	outputAddress string,
+	outputValue int64,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+	isUnSign bool,
+) (*DogeInscriptionResult, error) {
+	privateKey, err := btcec.NewPrivateKey()
+	if err != nil {
+		return nil, fmt.Errorf("生成私钥失败: %w", err)
+	}
+	return buildDogeInscriptionScriptTxsWithKey(privateKey, netParam, inscriptionScript, ins,
+		outputAddress, outputValue, changeAddress, feeRate, isUnSign)
+}
 
-		// ===== 第五步：构建lock脚本 =====
-		// 对应JavaScript中的lock脚本构建
-		// 结构: 公钥 + OP_CHECKSIGVERIFY + (N个OP_DROP) + OP_TRUE
-		lockBuilder := txscript.NewScriptBuilder()
-		lockBuilder.AddData(publicKeyBytes)           // 添加公钥
-		lockBuilder.AddOp(txscript.OP_CHECKSIGVERIFY) // 添加签名验证
-
-		// 为partial脚本中的每个chunk添加OP_DROP
-		// 对应JavaScript: partial.chunks.forEach(() => { lock.chunks.push(opcodeToChunk(Opcode.OP_DROP)) })
-		partialChunkCount := countScriptChunks(partialScript)
-		for i := 0; i < partialChunkCount; i++ {
-			lockBuilder.AddOp(txscript.OP_DROP) // 为每个数据项添加OP_DROP
-		}
-		lockBuilder.AddOp(txscript.OP_TRUE) // 添加OP_TRUE
+// buildDogeInscriptionScriptTxsWithKey 使用指定的临时密钥为inscription脚本构建交易链
+func buildDogeInscriptionScriptTxsWithKey(
+	privateKey *btcec.PrivateKey,
+	netParam *chaincfg.Params,
+	inscriptionScript []byte,
+	ins []*TxInputUtxo,
+	outputAddress string,
+	outputValue int64,
+	changeAddress string,
+	feeRate int64, // satoshis/B
+	isUnSign bool,
+) (*DogeInscriptionResult, error) {
+	cfg := newDogeChainConfig(netParam, outputAddress, outputValue, changeAddress, feeRate, nil)
+	result, _, err := buildDogeInscriptionChain(context.Background(), cfg, privateKey, inscriptionScript, ins)
+	return result, err
+}
 
-		lockScript, err := lockBuilder.Script()
-		if err != nil {
-			return nil, err
-		}
+// buildDogeInscriptionChain 构建交易链，并返回交易链之后仍然可用的UTXO（未使用的输入和各交易的找零）
+// ctx取消时返回*ErrBuildCancelled，其中的Session可以继续构建
+func buildDogeInscriptionChain(
+	ctx context.Context,
+	cfg *dogeChainConfig,
+	privateKey *btcec.PrivateKey,
+	inscriptionScript []byte,
+	ins []*TxInputUtxo,
+) (*DogeInscriptionResult, []*TxInputUtxo, error) {
+	session, err := newDogeInscriptionSession(cfg, privateKey, inscriptionScript, ins)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := session.run(ctx); err != nil {
+		return nil, nil, err
+	}
+	return session.result, session.availableUtxos, nil
+}
 
-		// ===== 第六步：构建P2SH脚本 =====
-		// 对应JavaScript中的p2sh脚本构建
-		lockHash := hash160(lockScript)
-		p2shBuilder := txscript.NewScriptBuilder()
-		p2shBuilder.AddOp(txscript.OP_HASH160)
-		p2shBuilder.AddData(lockHash)
-		p2shBuilder.AddOp(txscript.OP_EQUAL)
+// buildPartialTx 构建锁定partialScript的交易：第一笔为commit交易，之后每笔同时花费上一个P2SH
+// 交易构建成功后才更新session的状态，失败时session保持不变，可以重试
+func (s *DogeInscriptionSession) buildPartialTx(ctx context.Context, partialScript []byte) error {
+	cfg := &s.cfg
+	p2shInput := s.p2shInput
 
-		p2shScript, err := p2shBuilder.Script()
-		if err != nil {
-			return nil, err
-		}
+	// ===== 第五步：构建lock脚本 =====
+	// 对应JavaScript中的lock脚本构建
+	// 结构: 公钥 + OP_CHECKSIGVERIFY + (N个OP_DROP) + OP_TRUE
+	lockScript, err := buildInscriptionLockScript(s.publicKeyBytes, partialScript)
+	if err != nil {
+		return err
+	}
 
-		// ===== 第七步：构建交易 =====
-		// 对应JavaScript中的交易构建逻辑
-		tx := wire.NewMsgTx(2)
+	// ===== 第六步：构建P2SH脚本 =====
+	// 对应JavaScript中的p2sh脚本构建
+	lockHash := hash160(lockScript)
+	p2shBuilder := txscript.NewScriptBuilder()
+	p2shBuilder.AddOp(txscript.OP_HASH160)
+	p2shBuilder.AddData(lockHash)
+	p2shBuilder.AddOp(txscript.OP_EQUAL)
 
-		// 添加P2SH输入（如果有）
-		if p2shInput != nil {
-			tx.AddTxIn(p2shInput)
-		}
+	p2shScript, err := p2shBuilder.Script()
+	if err != nil {
+		return err
+	}
 
-		// 添加P2SH输出（固定为100000 satoshis，对应JavaScript中的100000）
-		p2shOutputAmount := int64(100000)
-		txOut := wire.NewTxOut(p2shOutputAmount, p2shScript)
-		tx.AddTxOut(txOut)
+	// ===== 第七步：构建交易 =====
+	// 对应JavaScript中的交易构建逻辑
+	tx := wire.NewMsgTx(2)
 
-		// fund函数：添加足够的UTXO输入来支付输出和手续费
-		// 对应JavaScript中的fund(wallet, tx)
-		existingInputAmount := int64(0)
-		if p2shInput != nil {
-			existingInputAmount = 100000 // P2SH输入的金额
-		}
+	// 添加P2SH输入（如果有）
+	if p2shInput != nil {
+		tx.AddTxIn(p2shInput)
+	}
 
-		// 估算P2SH输入的unlock脚本大小
-		estimatedSigSize := 0
-		if p2shInput != nil {
-			estimatedSigSize = len(partialScript) + 72 + len(lockScript) + 10
-		}
+	// 添加P2SH输出（默认100000 satoshis，对应JavaScript中的100000）
+	txOut := wire.NewTxOut(cfg.lockValue, p2shScript)
+	tx.AddTxOut(txOut)
 
-		// 调用fund函数为交易添加UTXO输入
-		usedUtxos, changeOutputIndex, remainingUtxos, err := fundTransaction(
-			tx,
-			availableUtxos,
-			changeAddress,
-			netParam,
-			feeRate,
-			existingInputAmount,
-			estimatedSigSize,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("fund交易 %d 失败: %v", len(txs)+1, err)
+	// 额外输出加在第一笔交易（commit）上，位于P2SH输出之后、找零之前
+	if p2shInput == nil && cfg.extra != nil && cfg.extra.Position == DogeExtraOutputsOnCommit {
+		for _, extraTxOut := range s.extraTxOuts {
+			tx.AddTxOut(extraTxOut)
 		}
-		availableUtxos = remainingUtxos
+	}
 
-		// ===== 第八步：为UTXO输入签名 =====
-		// 注意：必须先签名UTXO输入，再签名P2SH输入
-		// 因为P2SH签名需要完整的交易状态（包括已签名的UTXO输入）
-		// P2SH输入的索引是0，UTXO输入从索引1开始（如果有P2SH输入）
-		utxoStartIndex := 0
-		if p2shInput != nil {
-			utxoStartIndex = 1
-		}
+	// fund函数：添加足够的UTXO输入来支付输出和手续费
+	// 对应JavaScript中的fund(wallet, tx)
+	existingInputAmount := int64(0)
+	if p2shInput != nil {
+		existingInputAmount = cfg.lockValue // P2SH输入的金额
+	}
 
-		err = signTransactionInputs(tx, usedUtxos, utxoStartIndex)
-		if err != nil {
-			return nil, fmt.Errorf("签名交易 %d 的UTXO输入失败: %v", len(txs)+1, err)
-		}
+	// 估算P2SH输入的unlock脚本大小，unlock脚本中是上一个partial和lock，不是本交易创建的
+	estimatedSigSize := 0
+	if p2shInput != nil {
+		estimatedSigSize = len(s.lastPartial) + 72 + len(s.lastLock) + 10
+	}
+
+	// 调用fund函数为交易添加UTXO输入
+	usedUtxos, changeOutputIndex, remainingUtxos, err := fundTransaction(
+		tx,
+		s.availableUtxos,
+		cfg.changeAddress,
+		cfg.netParam,
+		cfg.feeRate,
+		existingInputAmount,
+		estimatedSigSize,
+		cfg.log(),
+	)
+	if err != nil {
+		return fmt.Errorf("fund交易 %d 失败: %w", len(s.result.Txs)+1, err)
+	}
 
-		// ===== 第九步：构建P2SH unlock脚本 =====
-		// 对应JavaScript中的unlock脚本构建
-		// 结构: partial数据 + 签名 + lock脚本
-		// 重要：必须在UTXO签名之后再签名P2SH输入
-		if p2shInput != nil {
-			fmt.Printf("\n=== 签名P2SH输入（交易%d） ===\n", len(txs)+1)
-			fmt.Printf("交易输入数: %d\n", len(tx.TxIn))
-			for i, in := range tx.TxIn {
-				fmt.Printf("  输入%d: 签名脚本长度=%d\n", i, len(in.SignatureScript))
-			}
-			fmt.Printf("交易输出数: %d\n", len(tx.TxOut))
-			for i, out := range tx.TxOut {
-				fmt.Printf("  输出%d: 金额=%d\n", i, out.Value)
-			}
+	// ===== 第八步：为UTXO输入签名 =====
+	// 注意：必须先签名UTXO输入，再签名P2SH输入
+	// 因为P2SH签名需要完整的交易状态（包括已签名的UTXO输入）
+	// P2SH输入的索引是0，UTXO输入从索引1开始（如果有P2SH输入）
+	utxoStartIndex := 0
+	if p2shInput != nil {
+		utxoStartIndex = 1
+	}
 
-			// 对P2SH输入进行签名
-			// 注意：RawTxInSignature 函数会自动处理签名哈希的计算
-			// 第三个参数 subScript 就是用于签名哈希计算的脚本（即 lastLock）
-			signature, err := txscript.RawTxInSignature(tx, 0, lastLock, txscript.SigHashAll, privateKey)
-			if err != nil {
-				return nil, fmt.Errorf("P2SH签名失败: %v", err)
-			}
+	err = cfg.signer.SignInputs(ctx, tx, usedUtxos, utxoStartIndex)
+	if err != nil {
+		return fmt.Errorf("签名交易 %d 的UTXO输入失败: %w", len(s.result.Txs)+1, err)
+	}
 
-			// 构建完整的unlock脚本
-			// 对应JavaScript: unlock.chunks = unlock.chunks.concat(lastPartial.chunks).push(sig).push(lock)
-			// 重要：不能用ScriptBuilder逐个AddData，因为会丢失OP codes
-			// 应该直接拼接原始字节
-			unlockScript := make([]byte, 0)
+	// ===== 第九步：构建P2SH unlock脚本 =====
+	// 对应JavaScript中的unlock脚本构建
+	// 结构: partial数据 + 签名 + lock脚本
+	// 重要：必须在UTXO签名之后再签名P2SH输入
+	if p2shInput != nil {
+		cfg.log().Debug("签名P2SH输入", "tx", len(s.result.Txs)+1, "inputs", len(tx.TxIn), "outputs", len(tx.TxOut))
 
-			// 1. 添加partial inscription数据（原始脚本字节）
-			unlockScript = append(unlockScript, lastPartial...)
+		// 对P2SH输入进行签名
+		// 注意：RawTxInSignature 函数会自动处理签名哈希的计算
+		// 第三个参数 subScript 就是用于签名哈希计算的脚本（即 lastLock）
+		signature, err := txscript.RawTxInSignature(tx, 0, s.lastLock, txscript.SigHashAll, s.privateKey)
+		if err != nil {
+			return fmt.Errorf("P2SH签名失败: %w", err)
+		}
 
-			// 2. 添加签名（需要添加长度前缀）
-			unlockScript = append(unlockScript, byte(len(signature)))
-			unlockScript = append(unlockScript, signature...)
+		// 构建完整的unlock脚本
+		// 对应JavaScript: unlock.chunks = unlock.chunks.concat(lastPartial.chunks).push(sig).push(lock)
+		unlockScript, err := buildInscriptionUnlockScript(s.lastPartial, signature, s.lastLock)
+		if err != nil {
+			return err
+		}
 
-			// 3. 添加lock脚本（需要添加长度前缀）
-			unlockScript = append(unlockScript, byte(len(lastLock)))
-			unlockScript = append(unlockScript, lastLock...)
+		// 设置input的签名脚本
+		tx.TxIn[0].SignatureScript = unlockScript
+	}
 
-			// 设置input的签名脚本
-			tx.TxIn[0].SignatureScript = unlockScript
+	role, extraCount := DogeTxRolePartial, 0
+	if p2shInput == nil {
+		role = DogeTxRoleCommit
+		if cfg.extra != nil && cfg.extra.Position == DogeExtraOutputsOnCommit {
+			extraCount = len(s.extraTxOuts)
 		}
+	}
+	if err := s.result.addTx(tx, role, existingInputAmount+utxosValue(usedUtxos), changeOutputIndex, 1, extraCount); err != nil {
+		return err
+	}
+	if p2shInput != nil {
+		s.bytesInscribed += len(s.lastPartial)
+	}
 
-		txs = append(txs, tx)
+	// ===== 第九步：准备下一个交易的输入 =====
+	// 对应JavaScript中的p2shInput构建
+	txHash := tx.TxHash()
+	s.p2shInput = wire.NewTxIn(
+		wire.NewOutPoint(&txHash, 0),
+		nil,
+		nil,
+	)
 
-		// ===== 第九步：准备下一个交易的输入 =====
-		// 对应JavaScript中的p2shInput构建
-		txHash := tx.TxHash()
-		p2shInput = wire.NewTxIn(
-			wire.NewOutPoint(&txHash, 0),
-			nil,
-			nil,
-		)
+	// 保存当前状态用于下一个交易
+	s.lastLock = lockScript
+	s.lastPartial = partialScript
 
-		// 保存当前状态用于下一个交易
-		lastLock = lockScript
-		lastPartial = partialScript
+	// updateWallet: 更新可用UTXO列表
+	// 对应JavaScript中的updateWallet(wallet, tx)
+	s.availableUtxos = updateWalletUtxos(tx, remainingUtxos, changeOutputIndex, usedUtxos)
+	cfg.log().Debug("更新可用UTXO", "availableUtxos", utxosLogValue(s.availableUtxos))
+	return nil
+}
 
-		// updateWallet: 更新可用UTXO列表
-		// 对应JavaScript中的updateWallet(wallet, tx)
-		availableUtxos = updateWalletUtxos(tx, availableUtxos, changeOutputIndex, usedUtxos)
-		// fmt.Printf("next availableUtxos:%+v\n", availableUtxos)
-		fmt.Printf("next availableUtxos:%d\n", len(availableUtxos))
-		for _, utxo := range availableUtxos {
-			fmt.Printf("next availableUtxo:%+v\n", utxo)
-		}
-	}
+// buildRevealTx 构建花费最后一个P2SH的reveal交易，输出到接收地址
+// 交易构建成功后才更新session的状态，失败时session保持不变，可以重试
+func (s *DogeInscriptionSession) buildRevealTx(ctx context.Context) error {
+	cfg := &s.cfg
 
 	// ===== 第十步：构建最终交易（reveal交易） =====
 	// 对应JavaScript中的最终交易构建
-	if p2shInput != nil && outputAddress != "" {
-		finalTx := wire.NewMsgTx(2)
-		finalTx.AddTxIn(p2shInput)
+	finalTx := wire.NewMsgTx(2)
+	finalTx.AddTxIn(s.p2shInput)
 
-		// 解码目标地址
-		addr, err := btcutil.DecodeAddress(outputAddress, netParam)
-		if err != nil {
-			return nil, fmt.Errorf("解码目标地址失败: %v", err)
-		}
-		pkScript, err := txscript.PayToAddrScript(addr)
-		if err != nil {
-			return nil, fmt.Errorf("构建目标地址脚本失败: %v", err)
-		}
-
-		// 添加输出到目标地址（使用用户指定的金额或默认100000）
-		if outputValue == 0 {
-			outputValue = 100000
-		}
-		finalTxOut := wire.NewTxOut(outputValue, pkScript)
-		finalTx.AddTxOut(finalTxOut)
+	// 解码目标地址
+	addr, err := decodeDogeAddress(cfg.outputAddress, cfg.netParam)
+	if err != nil {
+		return fmt.Errorf("解码目标地址失败: %w", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return fmt.Errorf("构建目标地址脚本失败: %w", err)
+	}
 
-		// fund最终交易：添加UTXO输入来支付手续费
-		estimatedFinalSigSize := len(lastPartial) + 72 + len(lastLock) + 10
-		usedFinalUtxos, _, _, err := fundTransaction(
-			finalTx,
-			availableUtxos,
-			changeAddress,
-			netParam,
-			feeRate,
-			100000, // P2SH输入的金额
-			estimatedFinalSigSize,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("fund最终交易失败: %v", err)
-		}
+	// 添加输出到目标地址（使用用户指定的金额或默认100000）
+	outputValue := cfg.outputValue
+	if outputValue == 0 {
+		outputValue = 100000
+	}
+	finalTxOut := wire.NewTxOut(outputValue, pkScript)
+	finalTx.AddTxOut(finalTxOut)
 
-		// 先为最终交易的UTXO输入签名
-		err = signTransactionInputs(finalTx, usedFinalUtxos, 1) // P2SH输入在索引0，UTXO从索引1开始
+	// 必须由reveal交易花费的UTXO（例如MRC-20携带token的UTXO）紧随P2SH输入，
+	// 对应的输出紧随reveal输出，输出索引从1开始
+	for _, utxo := range cfg.revealInputs {
+		txIn, err := utxoTxIn(utxo)
 		if err != nil {
-			return nil, fmt.Errorf("签名最终交易的UTXO输入失败: %v", err)
+			return err
 		}
+		finalTx.AddTxIn(txIn)
+	}
+	for _, revealTxOut := range cfg.revealOutputs {
+		finalTx.AddTxOut(revealTxOut)
+	}
 
-		// 再对最终交易的P2SH输入进行签名（必须在UTXO签名之后）
-		// 注意：RawTxInSignature 函数会自动处理签名哈希的计算
-		signature, err := txscript.RawTxInSignature(finalTx, 0, lastLock, txscript.SigHashAll, privateKey)
-		if err != nil {
-			return nil, fmt.Errorf("最终交易P2SH签名失败: %v", err)
+	// 额外输出加在reveal交易上，位于reveal输出之后、找零之前
+	if cfg.extra != nil && cfg.extra.Position == DogeExtraOutputsOnReveal {
+		for _, extraTxOut := range s.extraTxOuts {
+			finalTx.AddTxOut(extraTxOut)
 		}
+	}
 
-		// 构建完整的unlock脚本
-		// 直接拼接原始字节，保持OP codes
-		finalUnlockScript := make([]byte, 0)
+	// fund最终交易：添加UTXO输入来支付手续费
+	estimatedFinalSigSize := len(s.lastPartial) + 72 + len(s.lastLock) + 10 + len(cfg.revealInputs)*107
+	revealInputValue := cfg.lockValue + utxosValue(cfg.revealInputs) // P2SH输入和reveal输入的金额
+	usedFinalUtxos, finalChangeIndex, remainingUtxos, err := fundTransaction(
+		finalTx,
+		s.availableUtxos,
+		cfg.changeAddress,
+		cfg.netParam,
+		cfg.feeRate,
+		revealInputValue,
+		estimatedFinalSigSize,
+		cfg.log(),
+	)
+	if err != nil {
+		return fmt.Errorf("fund最终交易失败: %w", err)
+	}
 
-		// 1. 添加partial inscription数据（原始脚本字节）
-		finalUnlockScript = append(finalUnlockScript, lastPartial...)
+	// 先为最终交易的UTXO输入签名，P2SH输入在索引0，reveal输入和钱包UTXO从索引1开始
+	signedUtxos := append(append([]*TxInputUtxo{}, cfg.revealInputs...), usedFinalUtxos...)
+	err = cfg.signer.SignInputs(ctx, finalTx, signedUtxos, 1)
+	if err != nil {
+		return fmt.Errorf("签名最终交易的UTXO输入失败: %w", err)
+	}
 
-		// 2. 添加签名（需要添加长度前缀）
-		finalUnlockScript = append(finalUnlockScript, byte(len(signature)))
-		finalUnlockScript = append(finalUnlockScript, signature...)
+	// 再对最终交易的P2SH输入进行签名（必须在UTXO签名之后）
+	// 注意：RawTxInSignature 函数会自动处理签名哈希的计算
+	signature, err := txscript.RawTxInSignature(finalTx, 0, s.lastLock, txscript.SigHashAll, s.privateKey)
+	if err != nil {
+		return fmt.Errorf("最终交易P2SH签名失败: %w", err)
+	}
 
-		// 3. 添加lock脚本（需要添加长度前缀）
-		finalUnlockScript = append(finalUnlockScript, byte(len(lastLock)))
-		finalUnlockScript = append(finalUnlockScript, lastLock...)
+	// 构建完整的unlock脚本
+	finalUnlockScript, err := buildInscriptionUnlockScript(s.lastPartial, signature, s.lastLock)
+	if err != nil {
+		return err
+	}
 
-		finalTx.TxIn[0].SignatureScript = finalUnlockScript
+	finalTx.TxIn[0].SignatureScript = finalUnlockScript
 
-		txs = append(txs, finalTx)
+	extraCount := 0
+	if cfg.extra != nil && cfg.extra.Position == DogeExtraOutputsOnReveal {
+		extraCount = len(s.extraTxOuts)
 	}
-
-	return txs, nil
+	if err := s.result.addTx(finalTx, DogeTxRoleReveal, revealInputValue+utxosValue(usedFinalUtxos), finalChangeIndex, 1+len(cfg.revealOutputs), extraCount); err != nil {
+		return err
+	}
+	s.bytesInscribed += len(s.lastPartial)
+	s.p2shInput = nil
+	s.availableUtxos = updateWalletUtxos(finalTx, remainingUtxos, finalChangeIndex, signedUtxos)
+	return nil
 }
@@ -0,0 +1,412 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// readInscriptionFuzzSeeds 读取testdata/fuzz/FuzzParseInscriptionFromTx中的种子，key为文件名
+func readInscriptionFuzzSeeds(t *testing.T) map[string]struct {
+	txRaw  string
+	format InscriptionFormat
+} {
+	t.Helper()
+	files, err := filepath.Glob("testdata/fuzz/FuzzParseInscriptionFromTx/*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("没有fuzz种子: %v", err)
+	}
+	seeds := make(map[string]struct {
+		txRaw  string
+		format InscriptionFormat
+	})
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(lines) != 3 || lines[0] != "go test fuzz v1" {
+			t.Fatalf("%s: 种子格式错误", file)
+		}
+		txRaw, err := strconv.Unquote(strings.TrimSuffix(strings.TrimPrefix(lines[1], "string("), ")"))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		format, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(lines[2], "int("), ")"))
+		if err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		seeds[filepath.Base(file)] = struct {
+			txRaw  string
+			format InscriptionFormat
+		}{txRaw, InscriptionFormat(format)}
+	}
+	return seeds
+}
+
+// FuzzParseInscriptionFromTx 链上数据不可信，解析任何输入都不能panic，并且结果是确定的
+func FuzzParseInscriptionFromTx(f *testing.F) {
+	f.Add("", 0)
+	f.Add("00", 1)
+	f.Fuzz(func(t *testing.T, txRaw string, format int) {
+		first, err1 := ParseInscriptionFromTx(txRaw, InscriptionFormat(format))
+		second, err2 := ParseInscriptionFromTx(txRaw, InscriptionFormat(format))
+		if (err1 == nil) != (err2 == nil) || (err1 != nil && err1.Error() != err2.Error()) {
+			t.Fatalf("两次解析的错误不一致: %v, %v", err1, err2)
+		}
+		if !reflect.DeepEqual(first, second) {
+			t.Fatalf("两次解析的结果不一致")
+		}
+		if err1 != nil {
+			if first != nil {
+				t.Fatal("返回错误时结果应为nil")
+			}
+			return
+		}
+		if first.Format != InscriptionFormat(format) {
+			t.Errorf("Format %d, 期望%d", first.Format, format)
+		}
+		if first.Format == InscriptionFormatDoginal && !first.Continuation && first.PartsCount > 0 && first.Index >= first.PartsCount {
+			t.Errorf("Index %d超出PartsCount %d", first.Index, first.PartsCount)
+		}
+	})
+}
+
+func TestParseInscriptionFromTxSeeds(t *testing.T) {
+	seeds := readInscriptionFuzzSeeds(t)
+	tests := []struct {
+		seed         string
+		notIns       bool
+		contentType  string
+		path         string
+		version      string
+		partsCount   int
+		index        int
+		continuation bool
+		dataLen      int
+		dataPrefix   string
+	}{
+		{seed: "chain-doginal-multipart-tx0", notIns: true},
+		{seed: "chain-doginal-multipart-tx1", contentType: "application/octet-stream", partsCount: 13, index: 12, dataLen: 6 * 240, dataPrefix: "\x00\x01\x02"},
+		{seed: "chain-doginal-multipart-tx2", index: 6, continuation: true, dataLen: 6 * 240},
+		{seed: "chain-doginal-multipart-tx3", index: 0, continuation: true, dataLen: 120},
+		{seed: "chain-metaid-buzz-tx0", notIns: true},
+		{seed: "chain-metaid-buzz-tx1", contentType: "application/json", path: "/protocols/simplebuzz", version: "0.0.1", dataLen: 75, dataPrefix: `{"content":"Hello Doge!"`},
+		// 旧版插件写入的PIN，contentType和path的位置互换
+		{seed: "mainnet-metaid-json-reveal", contentType: "application/json", path: "bc1p20k3x2c4mglfxr5wa5sgtgechwstpld80kru2cg4gmm4urvuaqqsvapxu0:/protocols/simplegroupchat", version: "1.0.0", dataLen: 242, dataPrefix: `{"groupID":`},
+		{seed: "mainnet-metaid-reveal", contentType: "application/json", path: "bc1p20k3x2c4mglfxr5wa5sgtgechwstpld80kru2cg4gmm4urvuaqqsvapxu0:/protocols/simplefilegroupchat", version: "1.0.0", dataLen: 233, dataPrefix: `{"timestamp":`},
+		{seed: "mainnet-metaid-simplebuzz-commit", notIns: true},
+		{seed: "mainnet-metaid-simplebuzz-reveal", contentType: "text/plain", path: "/protocols/simplebuzz", version: "1.0.0", dataLen: 18, dataPrefix: "Hello DOGE MetaID!"},
+		{seed: "mainnet-p2pkh-transfer", notIns: true},
+	}
+	if len(tests) != len(seeds) {
+		t.Fatalf("种子数量%d，表格%d", len(seeds), len(tests))
+	}
+	for _, tt := range tests {
+		t.Run(tt.seed, func(t *testing.T) {
+			seed, ok := seeds[tt.seed]
+			if !ok {
+				t.Fatal("种子不存在")
+			}
+			result, err := ParseInscriptionFromTx(seed.txRaw, seed.format)
+			if tt.notIns {
+				if !errors.Is(err, ErrNotInscription) {
+					t.Fatalf("期望ErrNotInscription, 实际%v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.ContentType != tt.contentType || result.Path != tt.path || result.Version != tt.version {
+				t.Errorf("contentType=%q path=%q version=%q", result.ContentType, result.Path, result.Version)
+			}
+			if result.PartsCount != tt.partsCount || result.Index != tt.index || result.Continuation != tt.continuation {
+				t.Errorf("partsCount=%d index=%d continuation=%v", result.PartsCount, result.Index, result.Continuation)
+			}
+			if len(result.Data) != tt.dataLen || !bytes.HasPrefix(result.Data, []byte(tt.dataPrefix)) {
+				t.Errorf("data长度%d: %q", len(result.Data), result.Data[:min(len(result.Data), 32)])
+			}
+
+			// 用另一种格式解析不是该格式的inscription
+			other := InscriptionFormatMetaID
+			if seed.format == InscriptionFormatMetaID {
+				other = InscriptionFormatDoginal
+			}
+			if _, err := ParseInscriptionFromTx(seed.txRaw, other); !errors.Is(err, ErrNotInscription) {
+				t.Errorf("用另一种格式解析: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseInscriptionFromTxMalformed(t *testing.T) {
+	seeds := readInscriptionFuzzSeeds(t)
+	reveal := seeds["chain-metaid-buzz-tx1"].txRaw
+	for name, txRaw := range map[string]string{
+		"非十六进制": "zz",
+		"空交易":   "",
+		"截断":    reveal[:len(reveal)/2],
+		"奇数长度":  reveal[:len(reveal)-1],
+	} {
+		if _, err := ParseInscriptionFromTx(txRaw, InscriptionFormatMetaID); !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := ParseInscriptionFromTx(reveal, InscriptionFormat(7)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("未知格式: %v", err)
+	}
+}
+
+// TestParseInscriptionFromTxMetaIDContinuation MetaID的后续partial只有payload，单独解析时返回ErrNotInscription
+func TestParseInscriptionFromTxMetaIDContinuation(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	data := bytes.Repeat([]byte("p"), 3000)
+	txs, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, data, "/file", utxos, address, 100000, address, 1000, false, InscriptionFormatMetaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) < 3 {
+		t.Fatalf("交易数量%d, 期望多个partial", len(txs))
+	}
+	head, err := ParseInscriptionFromTx(testTxHex(t, txs[1]), InscriptionFormatMetaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Path != "/file" || len(head.Data) == 0 || len(head.Data) >= len(data) || !bytes.HasPrefix(data, head.Data) {
+		t.Errorf("第一个partial: path=%q data长度%d", head.Path, len(head.Data))
+	}
+	for i, tx := range txs[2:] {
+		if _, err := ParseInscriptionFromTx(testTxHex(t, tx), InscriptionFormatMetaID); !errors.Is(err, ErrNotInscription) {
+			t.Errorf("partial %d: 期望ErrNotInscription, 实际%v", i+1, err)
+		}
+	}
+}
+
+// testChainHexes 把交易链转换为十六进制原始交易
+func testChainHexes(t *testing.T, txs []*wire.MsgTx) []string {
+	t.Helper()
+	txRaws := make([]string, 0, len(txs))
+	for _, tx := range txs {
+		txRaws = append(txRaws, testTxHex(t, tx))
+	}
+	return txRaws
+}
+
+// TestParseDoginalNumber addNumberToScript编码的每个数字都能解析回来，非最小编码被拒绝
+func TestParseDoginalNumber(t *testing.T) {
+	for n := 0; n <= int(MAX_DOGINAL_PARTS); n++ {
+		script, err := addNumberToScript(txscript.NewScriptBuilder(), n).Script()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens, err := tokenizeScript(script)
+		if err != nil || len(tokens) != 1 {
+			t.Fatalf("%d: %v %d个token", n, err, len(tokens))
+		}
+		if got, err := parseDoginalNumber(tokens[0]); err != nil || got != n {
+			t.Fatalf("%d: 解析得到%d %v", n, got, err)
+		}
+	}
+	for _, script := range [][]byte{
+		{txscript.OP_DATA_1, 0x00},
+		{txscript.OP_DATA_1, 0x05},
+		{txscript.OP_DATA_1, 0x80},
+		{txscript.OP_DATA_2, 0x10, 0x00},
+		{txscript.OP_1NEGATE},
+	} {
+		tokens, err := tokenizeScript(script)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseDoginalNumber(tokens[0]); err == nil {
+			t.Errorf("%x: 没有返回错误", script)
+		}
+	}
+}
+
+// TestParseInscriptionFromChainDoginalBoundaries 在parts数量编码变化的位置构建再解析Doginal交易链：
+// 16/17是OP_16和OP_DATA_1的分界，127/128是OP_DATA_1和OP_DATA_2的分界，MAX_DOGINAL_PARTS是上限
+func TestParseInscriptionFromChainDoginalBoundaries(t *testing.T) {
+	tests := []struct {
+		parts         int
+		chunkLen      int
+		maxPayloadLen int
+	}{
+		{16, int(MAX_CHUNK_LEN), int(MAX_PAYLOAD_LEN)},
+		{17, int(MAX_CHUNK_LEN), int(MAX_PAYLOAD_LEN)},
+		{127, int(MAX_CHUNK_LEN), int(MAX_PAYLOAD_LEN)},
+		{128, int(MAX_CHUNK_LEN), int(MAX_PAYLOAD_LEN)},
+		// 1字节的数据块，lock脚本中OP_DROP的数量不超过520字节的push限制
+		{int(MAX_DOGINAL_PARTS), 1, 500},
+	}
+	for _, tt := range tests {
+		if testing.Short() && tt.parts == int(MAX_DOGINAL_PARTS) {
+			continue
+		}
+		_, _, address, utxos := testSimulatorWallet(t, 1, 1000e8)
+		data := make([]byte, tt.parts*tt.chunkLen)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		builder, err := NewInscriptionBuilder(
+			WithDoginal(data, "application/octet-stream"),
+			WithChunkSizes(tt.chunkLen, tt.maxPayloadLen),
+			WithUtxos(utxos),
+			WithChangeAddress(address),
+			WithFeeRate(100),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := builder.Build(context.Background())
+		if err != nil {
+			t.Fatalf("parts %d: %v", tt.parts, err)
+		}
+		txRaws := testChainHexes(t, result.Txs)
+
+		parsed, err := ParseInscriptionFromChain(txRaws, InscriptionFormatDoginal)
+		if err != nil {
+			t.Fatalf("parts %d: %v", tt.parts, err)
+		}
+		if parsed.PartsCount != tt.parts || parsed.Index != tt.parts-1 || parsed.Continuation ||
+			parsed.ContentType != "application/octet-stream" || !bytes.Equal(parsed.Data, data) {
+			t.Errorf("parts %d: partsCount=%d index=%d contentType=%q data长度%d",
+				tt.parts, parsed.PartsCount, parsed.Index, parsed.ContentType, len(parsed.Data))
+		}
+
+		// 每个partial单独解析，索引依次衔接
+		next := tt.parts - 1
+		for i, txRaw := range txRaws[1:] {
+			partial, err := ParseInscriptionFromTx(txRaw, InscriptionFormatDoginal)
+			if err != nil {
+				t.Fatalf("parts %d partial %d: %v", tt.parts, i, err)
+			}
+			if partial.Continuation != (i > 0) || partial.Index != next {
+				t.Errorf("parts %d partial %d: continuation=%v index=%d, 期望index %d",
+					tt.parts, i, partial.Continuation, partial.Index, next)
+			}
+			next -= len(partial.Data) / tt.chunkLen
+		}
+		if next != -1 {
+			t.Errorf("parts %d: partial的数据块数量不一致，剩余索引%d", tt.parts, next)
+		}
+	}
+
+	if _, err := BuildDoginalInscription(make([]byte, (MAX_DOGINAL_PARTS+1)*MAX_CHUNK_LEN), "text/plain"); err == nil {
+		t.Error("parts数量超过MAX_DOGINAL_PARTS应该返回错误")
+	}
+}
+
+func TestParseInscriptionFromChainMetaID(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	// 最后一个数据块是1字节的0x00，按最小编码写成OP_0
+	data := append(bytes.Repeat([]byte("metaid"), 1000), 0)
+	txs, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, data, "/protocols/file", utxos, address, 100000, address, 1000, false, InscriptionFormatMetaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) < 4 {
+		t.Fatalf("交易数量%d, 期望多个partial", len(txs))
+	}
+	parsed, err := ParseInscriptionFromChain(testChainHexes(t, txs), InscriptionFormatMetaID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Path != "/protocols/file" || parsed.Operation != "create" || !bytes.Equal(parsed.Data, data) {
+		t.Errorf("path=%q operation=%q data长度%d", parsed.Path, parsed.Operation, len(parsed.Data))
+	}
+}
+
+func TestParseInscriptionFromChainErrors(t *testing.T) {
+	_, _, address, utxos := testSimulatorWallet(t, 1, 50e8)
+	data := bytes.Repeat([]byte("d"), 3000)
+	txs, err := BuildDogeMetaIdInscriptionTxs(DogeMainNetParams, data, "text/plain", utxos, address, 100000, address, 1000, false, InscriptionFormatDoginal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txRaws := testChainHexes(t, txs)
+	if len(txRaws) < 4 {
+		t.Fatalf("交易数量%d, 期望多个partial", len(txRaws))
+	}
+
+	// 没有commit交易也可以解析
+	if _, err := ParseInscriptionFromChain(txRaws[1:], InscriptionFormatDoginal); err != nil {
+		t.Errorf("没有commit交易: %v", err)
+	}
+	if _, err := ParseInscriptionFromChain(append([]string{txRaws[0]}, txRaws[2:]...), InscriptionFormatDoginal); !errors.Is(err, ErrNotInscription) {
+		t.Errorf("缺少第一个partial: %v", err)
+	}
+	if _, err := ParseInscriptionFromChain(txRaws[:len(txRaws)-1], InscriptionFormatDoginal); !errors.Is(err, ErrNotInscription) {
+		t.Errorf("缺少最后的partial: %v", err)
+	}
+	if _, err := ParseInscriptionFromChain(append(append([]string{}, txRaws[:2]...), txRaws[3:]...), InscriptionFormatDoginal); !errors.Is(err, ErrNotInscription) {
+		t.Errorf("交易没有花费上一个partial: %v", err)
+	}
+	if _, err := ParseInscriptionFromChain(txRaws[:1], InscriptionFormatDoginal); !errors.Is(err, ErrNotInscription) {
+		t.Errorf("只有commit交易: %v", err)
+	}
+	if _, err := ParseInscriptionFromChain(nil, InscriptionFormatDoginal); !errors.Is(err, ErrNotInscription) {
+		t.Errorf("空交易链: %v", err)
+	}
+}
+
+func TestBuildDogeCommonTx(t *testing.T) {
+	sim, _, address, utxos := testSimulatorWallet(t, 1, 2e8, 2e8)
+	_, receiver := testDogeKey(t, 2)
+
+	// P2PKH签名脚本可以被节点接受
+	tx, err := BuildDogeCommonTx(DogeMainNetParams, utxos[:1], []*TxOutput{{Address: receiver, Amount: 1e8}}, address, 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxOut) != 2 {
+		t.Fatalf("输出数量%d", len(tx.TxOut))
+	}
+	testSubmitAndMine(t, sim, tx)
+	fee := 2e8 - 1e8 - tx.TxOut[1].Value
+
+	// 找零低于dust限制时并入手续费
+	amount := 1e8 + tx.TxOut[1].Value - (DogeDustLimit - 1)
+	tx, err = BuildDogeCommonTx(DogeMainNetParams, utxos[1:], []*TxOutput{{Address: receiver, Amount: amount}}, address, 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxOut) != 1 || tx.TxOut[0].Value != amount {
+		t.Fatalf("找零低于dust限制: %d个输出", len(tx.TxOut))
+	}
+	if txFee := 2e8 - amount; txFee != fee+DogeDustLimit-1 {
+		t.Errorf("手续费%d, 期望%d", txFee, fee+DogeDustLimit-1)
+	}
+	testSubmitAndMine(t, sim, tx)
+
+	// 没有找零地址时保留所有输出
+	_, _, _, utxos = testSimulatorWallet(t, 1, 2e8)
+	tx, err = BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: receiver, Amount: 5e7}, {Address: address, Amount: 5e7}}, "", 1000, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tx.TxOut) != 2 {
+		t.Errorf("没有找零地址: %d个输出", len(tx.TxOut))
+	}
+
+	// 低于dust限制的输出
+	_, err = BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: receiver, Amount: DogeDustLimit - 1}}, address, 1000, false)
+	var dustErr *ErrDustOutput
+	if !errors.As(err, &dustErr) || dustErr.Address != receiver || dustErr.Limit != DogeDustLimit {
+		t.Errorf("dust输出: %v", err)
+	}
+	if _, err := BuildDogeCommonTx(DogeMainNetParams, utxos, []*TxOutput{{Address: receiver, Amount: DogeDustLimit}}, address, 1000, false); err != nil {
+		t.Errorf("等于dust限制的输出: %v", err)
+	}
+}
@@ -0,0 +1,520 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// TxStatus 交易的链上状态
+type TxStatus struct {
+	TxId          string `json:"txId"`
+	Confirmed     bool   `json:"confirmed"`     // 是否已打包
+	Confirmations int64  `json:"confirmations"` // 确认数，未确认为0
+	BlockHash     string `json:"blockHash"`     // 所在区块hash，未确认为空
+	BlockHeight   int64  `json:"blockHeight"`   // 所在区块高度，未确认为0
+}
+
+// UtxoProvider UTXO数据来源
+// 返回的TxInputUtxo可以直接交给fundTransaction等选币逻辑使用，
+// 但不包含私钥，签名前需要通过ListSpendableUtxos或自行填充PriHex
+type UtxoProvider interface {
+	// ListUnspent 列出地址下所有未花费输出
+	ListUnspent(ctx context.Context, address string) ([]*TxInputUtxo, error)
+	// GetRawTx 获取十六进制格式的原始交易
+	GetRawTx(ctx context.Context, txId string) (string, error)
+	// GetTxStatus 获取交易的确认状态
+	GetTxStatus(ctx context.Context, txId string) (*TxStatus, error)
+}
+
+// ListSpendableUtxos 从provider获取地址的UTXO，并填充签名所需的私钥
+// 结果可以直接作为BuildDogeMetaIdInscriptionTxs的ins参数
+func ListSpendableUtxos(ctx context.Context, provider UtxoProvider, address string, priHex string) ([]*TxInputUtxo, error) {
+	utxos, err := provider.ListUnspent(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	for _, utxo := range utxos {
+		utxo.PriHex = priHex
+		utxo.SignMode = SignModeLegacy
+	}
+	return utxos, nil
+}
+
+// addressPkScriptHex 根据地址生成pkScript的十六进制字符串
+func addressPkScriptHex(netParam *chaincfg.Params, address string) (string, error) {
+	addr, err := decodeDogeAddress(address, netParam)
+	if err != nil {
+		return "", fmt.Errorf("解码地址失败: %w", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		return "", fmt.Errorf("构建地址脚本失败: %w", err)
+	}
+	return hex.EncodeToString(pkScript), nil
+}
+
+// dogeToSatoshis 将RPC返回的DOGE金额转换为satoshis
+func dogeToSatoshis(amount float64) uint64 {
+	return uint64(math.Round(amount * 1e8))
+}
+
+// ===== dogecoind RPC实现 =====
+
+// RpcUtxoProvider 通过dogecoind的JSON-RPC获取UTXO
+// listunspent要求地址已经导入节点钱包（importaddress）
+type RpcUtxoProvider struct {
+	Host     string // 例如 http://127.0.0.1:22555
+	User     string
+	Password string
+	NetParam *chaincfg.Params
+	Client   *http.Client // 为空时使用http.DefaultClient
+}
+
+// NewRpcUtxoProvider 创建dogecoind RPC provider
+func NewRpcUtxoProvider(netParam *chaincfg.Params, host, user, password string) *RpcUtxoProvider {
+	return &RpcUtxoProvider{
+		Host:     host,
+		User:     user,
+		Password: password,
+		NetParam: netParam,
+	}
+}
+
+type rpcRequest struct {
+	JsonRpc string        `json:"jsonrpc"`
+	Id      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// call 调用dogecoind RPC方法并将结果解码到result
+func (p *RpcUtxoProvider) call(ctx context.Context, method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(&rpcRequest{JsonRpc: "1.0", Id: 1, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.Host, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.User != "" {
+		req.SetBasicAuth(p.User, p.Password)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("RPC %s 请求失败: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("RPC %s 读取响应失败: %w", method, err)
+	}
+
+	// dogecoind在RPC错误时也会返回500和JSON body，所以先尝试解析
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+		return fmt.Errorf("RPC %s 响应异常: status=%d body=%s", method, resp.StatusCode, string(respBody))
+	}
+	if rpcResp.Error != nil {
+		return fmt.Errorf("RPC %s 返回错误: code=%d message=%s", method, rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("RPC %s 解析结果失败: %w", method, err)
+	}
+	return nil
+}
+
+type rpcUnspent struct {
+	TxId          string  `json:"txid"`
+	Vout          int64   `json:"vout"`
+	Address       string  `json:"address"`
+	ScriptPubKey  string  `json:"scriptPubKey"`
+	Amount        float64 `json:"amount"`
+	Confirmations int64   `json:"confirmations"`
+}
+
+// ListUnspent 调用listunspent列出地址的UTXO（包含未确认的）
+func (p *RpcUtxoProvider) ListUnspent(ctx context.Context, address string) ([]*TxInputUtxo, error) {
+	var unspents []rpcUnspent
+	err := p.call(ctx, "listunspent", []interface{}{0, 9999999, []string{address}}, &unspents)
+	if err != nil {
+		return nil, err
+	}
+
+	utxos := make([]*TxInputUtxo, 0, len(unspents))
+	for _, u := range unspents {
+		utxos = append(utxos, &TxInputUtxo{
+			TxId:     u.TxId,
+			TxIndex:  u.Vout,
+			PkScript: u.ScriptPubKey,
+			Amount:   dogeToSatoshis(u.Amount),
+			SignMode: SignModeLegacy,
+		})
+	}
+	return utxos, nil
+}
+
+// GetRawTx 调用getrawtransaction获取原始交易
+func (p *RpcUtxoProvider) GetRawTx(ctx context.Context, txId string) (string, error) {
+	var rawTx string
+	err := p.call(ctx, "getrawtransaction", []interface{}{txId, 0}, &rawTx)
+	if err != nil {
+		return "", err
+	}
+	return rawTx, nil
+}
+
+// GetTxStatus 调用getrawtransaction(verbose)获取确认数，再通过getblockheader获取区块高度
+func (p *RpcUtxoProvider) GetTxStatus(ctx context.Context, txId string) (*TxStatus, error) {
+	var verboseTx struct {
+		Confirmations int64  `json:"confirmations"`
+		BlockHash     string `json:"blockhash"`
+	}
+	err := p.call(ctx, "getrawtransaction", []interface{}{txId, 1}, &verboseTx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &TxStatus{
+		TxId:          txId,
+		Confirmed:     verboseTx.BlockHash != "" && verboseTx.Confirmations > 0,
+		Confirmations: verboseTx.Confirmations,
+		BlockHash:     verboseTx.BlockHash,
+	}
+	if status.Confirmed {
+		var header struct {
+			Height int64 `json:"height"`
+		}
+		err = p.call(ctx, "getblockheader", []interface{}{verboseTx.BlockHash}, &header)
+		if err != nil {
+			return nil, err
+		}
+		status.BlockHeight = header.Height
+	}
+	return status, nil
+}
+
+// ===== 索引器REST实现 =====
+
+// DefaultDogeIndexerHost 插件使用的钱包API地址（对应src/queries/request.ts中的metaletApiV4）
+const DefaultDogeIndexerHost = "https://www.metalet.space/wallet-api/v4"
+
+// RestUtxoProvider 通过插件使用的索引器API获取UTXO
+// 对应src/queries/doge/utxos.ts中的fetchAllDogeUtxos和fetchDogeTxHex
+type RestUtxoProvider struct {
+	Host      string // 默认DefaultDogeIndexerHost
+	Net       string // livenet 或 testnet
+	NetParam  *chaincfg.Params
+	MinAmount uint64       // 过滤掉小于该金额的UTXO，插件中为1000000
+	Client    *http.Client // 为空时使用http.DefaultClient
+}
+
+// NewRestUtxoProvider 创建索引器REST provider
+func NewRestUtxoProvider(netParam *chaincfg.Params, net string) *RestUtxoProvider {
+	return &RestUtxoProvider{
+		Host:     DefaultDogeIndexerHost,
+		Net:      net,
+		NetParam: netParam,
+	}
+}
+
+// restResult 索引器统一的返回结构，对应MetaletV3Result
+type restResult struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+type restUtxoItem struct {
+	Address  string `json:"address"`
+	TxId     string `json:"txid"`
+	OutIndex int64  `json:"outIndex"`
+	Value    uint64 `json:"value"`
+	Height   int64  `json:"height"`
+}
+
+// get 请求索引器接口并将data字段解码到result
+func (p *RestUtxoProvider) get(ctx context.Context, path string, params url.Values, result interface{}) error {
+	host := p.Host
+	if host == "" {
+		host = DefaultDogeIndexerHost
+	}
+	params.Set("net", p.Net)
+	reqUrl := strings.TrimRight(host, "/") + path + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
+	if err != nil {
+		return err
+	}
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求%s失败: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("请求%s失败: status=%d", path, resp.StatusCode)
+	}
+
+	var res restResult
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("解析%s响应失败: %w", path, err)
+	}
+	if res.Code != 0 {
+		return fmt.Errorf("请求%s返回错误: %s", path, res.Message)
+	}
+	if err := json.Unmarshal(res.Data, result); err != nil {
+		return fmt.Errorf("解析%s数据失败: %w", path, err)
+	}
+	return nil
+}
+
+// ListUnspent 调用/doge/address/utxo-list列出地址的UTXO
+func (p *RestUtxoProvider) ListUnspent(ctx context.Context, address string) ([]*TxInputUtxo, error) {
+	var data struct {
+		List  []restUtxoItem `json:"list"`
+		Total int64          `json:"total"`
+	}
+	err := p.get(ctx, "/doge/address/utxo-list", url.Values{"address": {address}}, &data)
+	if err != nil {
+		return nil, err
+	}
+
+	// 索引器不返回pkScript，使用地址生成
+	pkScripts := make(map[string]string)
+	utxos := make([]*TxInputUtxo, 0, len(data.List))
+	for _, item := range data.List {
+		if item.Value < p.MinAmount {
+			continue
+		}
+		pkScript, ok := pkScripts[item.Address]
+		if !ok {
+			pkScript, err = addressPkScriptHex(p.NetParam, item.Address)
+			if err != nil {
+				return nil, err
+			}
+			pkScripts[item.Address] = pkScript
+		}
+		utxos = append(utxos, &TxInputUtxo{
+			TxId:     item.TxId,
+			TxIndex:  item.OutIndex,
+			PkScript: pkScript,
+			Amount:   item.Value,
+			SignMode: SignModeLegacy,
+		})
+	}
+	return utxos, nil
+}
+
+// GetRawTx 调用/doge/tx/raw获取原始交易
+func (p *RestUtxoProvider) GetRawTx(ctx context.Context, txId string) (string, error) {
+	var data struct {
+		Hex string `json:"hex"`
+	}
+	err := p.get(ctx, "/doge/tx/raw", url.Values{"txId": {txId}}, &data)
+	if err != nil {
+		return "", err
+	}
+	return data.Hex, nil
+}
+
+// GetTxStatus 索引器API没有交易状态接口，/doge/tx/raw也不返回确认数和区块信息，
+// 返回errors.ErrUnsupported而不是一个永远未确认的状态；需要等待确认时使用RpcUtxoProvider
+func (p *RestUtxoProvider) GetTxStatus(ctx context.Context, txId string) (*TxStatus, error) {
+	return nil, fmt.Errorf("索引器API不支持查询交易%s的确认状态: %w", txId, errors.ErrUnsupported)
+}
+
+// ===== 内存实现（用于测试） =====
+
+// MemoryUtxoProvider 内存中的UTXO集合，用于测试
+// 通过ApplyTx可以模拟交易上链后UTXO集合的变化
+type MemoryUtxoProvider struct {
+	NetParam *chaincfg.Params
+
+	mu       sync.RWMutex
+	utxos    map[wire.OutPoint]*memoryUtxo
+	rawTxs   map[string]string
+	statuses map[string]*TxStatus
+}
+
+type memoryUtxo struct {
+	address string
+	utxo    TxInputUtxo
+}
+
+// NewMemoryUtxoProvider 创建内存provider
+func NewMemoryUtxoProvider(netParam *chaincfg.Params) *MemoryUtxoProvider {
+	return &MemoryUtxoProvider{
+		NetParam: netParam,
+		utxos:    make(map[wire.OutPoint]*memoryUtxo),
+		rawTxs:   make(map[string]string),
+		statuses: make(map[string]*TxStatus),
+	}
+}
+
+// AddUtxo 添加一个UTXO，pkScript为空时根据地址生成
+func (p *MemoryUtxoProvider) AddUtxo(address string, utxo *TxInputUtxo) error {
+	outPoint, err := utxoOutPoint(utxo)
+	if err != nil {
+		return err
+	}
+	stored := *utxo
+	if stored.PkScript == "" {
+		stored.PkScript, err = addressPkScriptHex(p.NetParam, address)
+		if err != nil {
+			return err
+		}
+	}
+	// Dogecoin只有legacy签名
+	stored.SignMode = SignModeLegacy
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.utxos[outPoint] = &memoryUtxo{address: address, utxo: stored}
+	return nil
+}
+
+// ApplyTx 将交易应用到UTXO集合：移除被花费的输入，添加可识别地址的输出
+// confirmations为0表示交易在内存池中
+func (p *MemoryUtxoProvider) ApplyTx(tx *wire.MsgTx, confirmations int64) error {
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		return fmt.Errorf("序列化交易失败: %w", err)
+	}
+	txHash := tx.TxHash()
+	txId := txHash.String()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, in := range tx.TxIn {
+		delete(p.utxos, in.PreviousOutPoint)
+	}
+	for i, out := range tx.TxOut {
+		_, addrs, _, err := txscript.ExtractPkScriptAddrs(out.PkScript, p.NetParam)
+		if err != nil || len(addrs) != 1 {
+			continue
+		}
+		p.utxos[*wire.NewOutPoint(&txHash, uint32(i))] = &memoryUtxo{
+			address: addrs[0].EncodeAddress(),
+			utxo: TxInputUtxo{
+				TxId:     txId,
+				TxIndex:  int64(i),
+				PkScript: hex.EncodeToString(out.PkScript),
+				Amount:   uint64(out.Value),
+				SignMode: SignModeLegacy,
+			},
+		}
+	}
+
+	p.rawTxs[txId] = hex.EncodeToString(buf.Bytes())
+	p.statuses[txId] = &TxStatus{
+		TxId:          txId,
+		Confirmed:     confirmations > 0,
+		Confirmations: confirmations,
+	}
+	return nil
+}
+
+// SetTxStatus 设置交易状态
+func (p *MemoryUtxoProvider) SetTxStatus(status *TxStatus) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.statuses[status.TxId] = status
+}
+
+// ListUnspent 列出地址的UTXO，按txid和输出索引排序以保证选币结果确定
+func (p *MemoryUtxoProvider) ListUnspent(ctx context.Context, address string) ([]*TxInputUtxo, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	utxos := make([]*TxInputUtxo, 0)
+	for _, u := range p.utxos {
+		if u.address != address {
+			continue
+		}
+		utxo := u.utxo
+		utxos = append(utxos, &utxo)
+	}
+	sort.Slice(utxos, func(i, j int) bool {
+		if utxos[i].TxId != utxos[j].TxId {
+			return utxos[i].TxId < utxos[j].TxId
+		}
+		return utxos[i].TxIndex < utxos[j].TxIndex
+	})
+	return utxos, nil
+}
+
+// GetRawTx 获取通过ApplyTx添加的原始交易
+func (p *MemoryUtxoProvider) GetRawTx(ctx context.Context, txId string) (string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	rawTx, ok := p.rawTxs[txId]
+	if !ok {
+		return "", fmt.Errorf("交易不存在: %s", txId)
+	}
+	return rawTx, nil
+}
+
+// GetTxStatus 获取交易状态
+func (p *MemoryUtxoProvider) GetTxStatus(ctx context.Context, txId string) (*TxStatus, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	status, ok := p.statuses[txId]
+	if !ok {
+		return nil, fmt.Errorf("交易不存在: %s", txId)
+	}
+	statusCopy := *status
+	return &statusCopy, nil
+}
+
+// utxoOutPoint 根据UTXO的TxId和TxIndex生成OutPoint
+func utxoOutPoint(utxo *TxInputUtxo) (wire.OutPoint, error) {
+	hash, err := chainhash.NewHashFromStr(utxo.TxId)
+	if err != nil {
+		return wire.OutPoint{}, fmt.Errorf("解析TxId失败: %w", err)
+	}
+	return *wire.NewOutPoint(hash, uint32(utxo.TxIndex)), nil
+}
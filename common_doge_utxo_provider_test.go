@@ -0,0 +1,217 @@
+package common
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcutil"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// testDogeKey 由seed生成确定的私钥，返回私钥十六进制和主网P2PKH地址
+func testDogeKey(t *testing.T, seed byte) (string, string) {
+	t.Helper()
+	privateKey, _ := btcec.PrivKeyFromBytes(bytes.Repeat([]byte{seed}, 32))
+	addr, err := btcutil.NewAddressPubKeyHash(btcutil.Hash160(privateKey.PubKey().SerializeCompressed()), DogeMainNetParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(privateKey.Serialize()), addr.EncodeAddress()
+}
+
+// testTxHex 序列化交易为十六进制
+func testTxHex(t *testing.T, tx *wire.MsgTx) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := tx.Serialize(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return hex.EncodeToString(buf.Bytes())
+}
+
+func TestMemoryUtxoProvider(t *testing.T) {
+	priHex, address := testDogeKey(t, 1)
+	_, other := testDogeKey(t, 2)
+	provider := NewMemoryUtxoProvider(DogeMainNetParams)
+	txId := strings.Repeat("ab", 32)
+	for _, vout := range []int64{2, 0, 1} {
+		if err := provider.AddUtxo(address, &TxInputUtxo{TxId: txId, TxIndex: vout, Amount: uint64(vout+1) * 1e8}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	utxos, err := ListSpendableUtxos(context.Background(), provider, address, priHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 3 {
+		t.Fatalf("UTXO数量 %d, 期望3", len(utxos))
+	}
+	pkScript, _ := addressPkScriptHex(DogeMainNetParams, address)
+	for i, utxo := range utxos {
+		if utxo.TxIndex != int64(i) || utxo.PkScript != pkScript || utxo.PriHex != priHex || utxo.SignMode != SignModeLegacy {
+			t.Errorf("UTXO %d: %+v", i, *utxo)
+		}
+	}
+
+	// 花费vout 0，输出给other和address
+	hash, _ := chainhash.NewHashFromStr(txId)
+	tx := wire.NewMsgTx(2)
+	tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, 0), nil, nil))
+	otherScript, _ := addressPkScriptHex(DogeMainNetParams, other)
+	otherBytes, _ := hex.DecodeString(otherScript)
+	pkBytes, _ := hex.DecodeString(pkScript)
+	tx.AddTxOut(wire.NewTxOut(4e7, otherBytes))
+	tx.AddTxOut(wire.NewTxOut(5e7, pkBytes))
+	if err := provider.ApplyTx(tx, 0); err != nil {
+		t.Fatal(err)
+	}
+	utxos, _ = provider.ListUnspent(context.Background(), address)
+	if len(utxos) != 3 {
+		t.Fatalf("ApplyTx后UTXO数量 %d, 期望3", len(utxos))
+	}
+	for _, utxo := range utxos {
+		if utxo.TxId == txId && utxo.TxIndex == 0 {
+			t.Error("被花费的UTXO仍然存在")
+		}
+	}
+	utxos, _ = provider.ListUnspent(context.Background(), other)
+	if len(utxos) != 1 || utxos[0].Amount != 4e7 {
+		t.Errorf("other的UTXO: %v", utxos)
+	}
+
+	newTxId := tx.TxHash().String()
+	rawTx, err := provider.GetRawTx(context.Background(), newTxId)
+	if err != nil || rawTx != testTxHex(t, tx) {
+		t.Errorf("GetRawTx: %s, %v", rawTx, err)
+	}
+	status, err := provider.GetTxStatus(context.Background(), newTxId)
+	if err != nil || status.Confirmed {
+		t.Errorf("GetTxStatus: %+v, %v", status, err)
+	}
+	provider.SetTxStatus(&TxStatus{TxId: newTxId, Confirmed: true, Confirmations: 3, BlockHeight: 100})
+	status, _ = provider.GetTxStatus(context.Background(), newTxId)
+	if !status.Confirmed || status.Confirmations != 3 || status.BlockHeight != 100 {
+		t.Errorf("SetTxStatus后: %+v", status)
+	}
+	if _, err := provider.GetRawTx(context.Background(), txId); err == nil {
+		t.Error("不存在的交易应该返回错误")
+	}
+}
+
+func TestRpcUtxoProvider(t *testing.T) {
+	_, address := testDogeKey(t, 1)
+	txId := strings.Repeat("cd", 32)
+	blockHash := strings.Repeat("ef", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Error(err)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "listunspent":
+			result = []rpcUnspent{{TxId: txId, Vout: 1, Address: address, ScriptPubKey: "76a914", Amount: 12.3456789, Confirmations: 2}}
+		case "getrawtransaction":
+			if req.Params[1].(float64) == 0 {
+				result = "0200"
+			} else {
+				result = map[string]interface{}{"confirmations": 2, "blockhash": blockHash}
+			}
+		case "getblockheader":
+			result = map[string]interface{}{"height": 5000000}
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"error": rpcError{Code: -32601, Message: "Method not found"}})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"result": result})
+	}))
+	defer server.Close()
+
+	provider := NewRpcUtxoProvider(DogeMainNetParams, server.URL, "user", "pass")
+	utxos, err := provider.ListUnspent(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(utxos) != 1 || utxos[0].TxId != txId || utxos[0].TxIndex != 1 || utxos[0].Amount != 1234567890 {
+		t.Errorf("ListUnspent: %v", utxos)
+	}
+	rawTx, err := provider.GetRawTx(context.Background(), txId)
+	if err != nil || rawTx != "0200" {
+		t.Errorf("GetRawTx: %s, %v", rawTx, err)
+	}
+	status, err := provider.GetTxStatus(context.Background(), txId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Confirmed || status.Confirmations != 2 || status.BlockHash != blockHash || status.BlockHeight != 5000000 {
+		t.Errorf("GetTxStatus: %+v", status)
+	}
+
+	provider.Password = "wrong"
+	if _, err := provider.ListUnspent(context.Background(), address); err == nil {
+		t.Error("认证失败应该返回错误")
+	}
+}
+
+func TestRestUtxoProvider(t *testing.T) {
+	_, address := testDogeKey(t, 1)
+	txId := strings.Repeat("12", 32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("net") != "livenet" {
+			t.Errorf("net参数: %s", r.URL.Query().Get("net"))
+		}
+		switch r.URL.Path {
+		case "/doge/address/utxo-list":
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]interface{}{
+				"list": []restUtxoItem{
+					{Address: address, TxId: txId, OutIndex: 0, Value: 500000},
+					{Address: address, TxId: txId, OutIndex: 1, Value: 2000000},
+				},
+				"total": 2,
+			}})
+		case "/doge/tx/raw":
+			if r.URL.Query().Get("txId") != txId {
+				json.NewEncoder(w).Encode(map[string]interface{}{"code": 1, "message": "tx not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"code": 0, "data": map[string]string{"hex": "0200"}})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewRestUtxoProvider(DogeMainNetParams, "livenet")
+	provider.Host = server.URL + "/"
+	provider.MinAmount = 1000000
+	utxos, err := provider.ListUnspent(context.Background(), address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkScript, _ := addressPkScriptHex(DogeMainNetParams, address)
+	if len(utxos) != 1 || utxos[0].TxIndex != 1 || utxos[0].Amount != 2000000 || utxos[0].PkScript != pkScript {
+		t.Errorf("ListUnspent: %v", utxos)
+	}
+	// 索引器无法提供确认状态，不能返回一个永远未确认的状态
+	if status, err := provider.GetTxStatus(context.Background(), txId); !errors.Is(err, errors.ErrUnsupported) || status != nil {
+		t.Errorf("GetTxStatus: %+v, %v", status, err)
+	}
+	if _, err := provider.GetRawTx(context.Background(), strings.Repeat("00", 32)); err == nil || !strings.Contains(err.Error(), "tx not found") {
+		t.Errorf("索引器返回错误码时应该返回错误: %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/wire"
+)
+
+// 跨实现的golden vectors
+// testdata/doge_inscription_vectors.json 中的向量同时被Go（TestDogeInscriptionVectors）
+// 和插件（test-doge-inscription-vectors.ts，对照inscription-script.ts和doginals.ts）校验，
+// 任何一侧的输出发生变化都会被发现。有意修改实现后用 go test -run TestDogeInscriptionVectors -update 重新生成
+
+var updateVectors = flag.Bool("update", false, "用Go实现重新生成testdata中的golden vectors")
+
+const dogeVectorsPath = "testdata/doge_inscription_vectors.json"
+
+const (
+	dogeVectorKindScript = "script" // 只比较inscription脚本、partial拆分和lock脚本
+	dogeVectorKindChain  = "chain"  // 额外比较完整交易链的原始数据
+
+	dogeVectorFormatDoginal = "doginal"
+	dogeVectorFormatMetaID  = "metaid"
+)
+
+// dogeVectorImplementations knownDivergences中允许出现的插件实现
+var dogeVectorImplementations = map[string]bool{"doginals.js": true, "inscribe.ts": true}
+
+// dogeInscriptionVector 一条golden vector
+type dogeInscriptionVector struct {
+	Name   string                       `json:"name"`
+	Kind   string                       `json:"kind"`
+	Format string                       `json:"format"`
+	Input  dogeInscriptionVectorInput   `json:"input"`
+	Expect *dogeInscriptionVectorExpect `json:"expect"`
+	// KnownDivergences 已知的实现差异，key为实现名称（doginals.js / inscribe.ts），value为原因
+	KnownDivergences map[string]string `json:"knownDivergences,omitempty"`
+}
+
+// dogeInscriptionVectorInput 向量的固定输入
+type dogeInscriptionVectorInput struct {
+	DataHex       string `json:"dataHex"`
+	ContentType   string `json:"contentType"`   // Doginal的contentType，MetaID的path
+	PrivateKeyHex string `json:"privateKeyHex"` // P2SH临时私钥
+
+	// 以下字段仅用于chain类型
+	Utxos         []*dogeInscriptionVectorUtxo `json:"utxos,omitempty"`
+	OutputAddress string                       `json:"outputAddress,omitempty"`
+	OutputValue   int64                        `json:"outputValue,omitempty"`
+	ChangeAddress string                       `json:"changeAddress,omitempty"`
+	FeeRate       int64                        `json:"feeRate,omitempty"`
+}
+
+// dogeInscriptionVectorUtxo 向量中的固定UTXO
+type dogeInscriptionVectorUtxo struct {
+	TxId     string `json:"txId"`
+	TxIndex  int64  `json:"txIndex"`
+	PkScript string `json:"pkScript"`
+	Amount   uint64 `json:"amount"`
+	PriHex   string `json:"priHex"`
+}
+
+// dogeInscriptionVectorExpect 向量的期望输出（均为十六进制）
+type dogeInscriptionVectorExpect struct {
+	InscriptionScript string   `json:"inscriptionScript"`
+	Partials          []string `json:"partials"`
+	LockScripts       []string `json:"lockScripts"`
+	P2SHScripts       []string `json:"p2shScripts"`
+	RawTxs            []string `json:"rawTxs,omitempty"`
+}
+
+func TestDogeInscriptionVectors(t *testing.T) {
+	content, err := os.ReadFile(dogeVectorsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		Vectors []*dogeInscriptionVector `json:"vectors"`
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		t.Fatal(err)
+	}
+	if len(file.Vectors) == 0 {
+		t.Fatal("没有向量")
+	}
+
+	for _, v := range file.Vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			for impl, reason := range v.KnownDivergences {
+				if !dogeVectorImplementations[impl] || reason == "" {
+					t.Errorf("knownDivergences[%q]必须是已知实现并写明原因", impl)
+				}
+			}
+			actual, err := v.compute()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *updateVectors {
+				v.Expect = actual
+				return
+			}
+			if err := v.check(actual); err != nil {
+				t.Error(err)
+			}
+			if v.Kind == dogeVectorKindChain {
+				checkDogeVectorChain(t, v)
+			}
+		})
+	}
+
+	if *updateVectors {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(&file); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dogeVectorsPath, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// check 与期望值逐字节比较
+func (v *dogeInscriptionVector) check(actual *dogeInscriptionVectorExpect) error {
+	if v.Expect == nil {
+		return fmt.Errorf("缺少期望值")
+	}
+	if actual.InscriptionScript != v.Expect.InscriptionScript {
+		return fmt.Errorf("inscription脚本不一致")
+	}
+	fields := []struct {
+		name     string
+		actual   []string
+		expected []string
+	}{
+		{"partial", actual.Partials, v.Expect.Partials},
+		{"lock脚本", actual.LockScripts, v.Expect.LockScripts},
+		{"P2SH脚本", actual.P2SHScripts, v.Expect.P2SHScripts},
+		{"交易", actual.RawTxs, v.Expect.RawTxs},
+	}
+	for _, f := range fields {
+		if len(f.actual) != len(f.expected) {
+			return fmt.Errorf("%s数量不一致: %d != %d", f.name, len(f.actual), len(f.expected))
+		}
+		for i := range f.actual {
+			if f.actual[i] != f.expected[i] {
+				return fmt.Errorf("第%d个%s不一致", i, f.name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkDogeVectorChain 检查交易链可以被节点接受：没有dust输出，手续费不低于向量的费率
+// 插件侧对同样的条件做了检查，这里保证向量本身满足这些条件
+func checkDogeVectorChain(t *testing.T, v *dogeInscriptionVector) {
+	prevOuts := make(map[wire.OutPoint]int64)
+	for _, utxo := range v.Input.Utxos {
+		outPoint, err := utxoOutPoint(&TxInputUtxo{TxId: utxo.TxId, TxIndex: utxo.TxIndex})
+		if err != nil {
+			t.Fatal(err)
+		}
+		prevOuts[outPoint] = int64(utxo.Amount)
+	}
+	for i, rawTx := range v.Expect.RawTxs {
+		txBytes, _ := hex.DecodeString(rawTx)
+		var tx wire.MsgTx
+		if err := tx.Deserialize(bytes.NewReader(txBytes)); err != nil {
+			t.Fatal(err)
+		}
+		fee := int64(0)
+		for _, in := range tx.TxIn {
+			value, ok := prevOuts[in.PreviousOutPoint]
+			if !ok {
+				t.Fatalf("交易%d引用了未知的输出 %s", i, in.PreviousOutPoint)
+			}
+			fee += value
+		}
+		txHash := tx.TxHash()
+		for n, out := range tx.TxOut {
+			if out.Value < DogeDustLimit {
+				t.Errorf("交易%d的输出%d低于dust限制: %d", i, n, out.Value)
+			}
+			fee -= out.Value
+			prevOuts[*wire.NewOutPoint(&txHash, uint32(n))] = out.Value
+		}
+		if fee < int64(len(txBytes))*v.Input.FeeRate {
+			t.Errorf("交易%d的手续费%d低于费率%d (大小=%d)", i, fee, v.Input.FeeRate, len(txBytes))
+		}
+	}
+}
+
+// compute 用Go实现计算向量的输出
+func (v *dogeInscriptionVector) compute() (*dogeInscriptionVectorExpect, error) {
+	data, err := hex.DecodeString(v.Input.DataHex)
+	if err != nil {
+		return nil, fmt.Errorf("解码数据失败: %w", err)
+	}
+	keyBytes, err := hex.DecodeString(v.Input.PrivateKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("解码私钥失败: %w", err)
+	}
+	privateKey, _ := btcec.PrivKeyFromBytes(keyBytes)
+	publicKeyBytes := privateKey.PubKey().SerializeCompressed()
+
+	var format InscriptionFormat
+	var inscriptionScript []byte
+	switch v.Format {
+	case dogeVectorFormatDoginal:
+		format = InscriptionFormatDoginal
+		inscriptionScript, err = BuildDoginalInscription(data, v.Input.ContentType)
+	case dogeVectorFormatMetaID:
+		format = InscriptionFormatMetaID
+		inscriptionScript, err = BuildDogeMetaIdInscription(data, v.Input.ContentType)
+	default:
+		return nil, fmt.Errorf("未知的格式: %s", v.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("构建inscription脚本失败: %w", err)
+	}
+
+	partials, err := splitInscriptionPartials(inscriptionScript, int(MAX_PAYLOAD_LEN))
+	if err != nil {
+		return nil, fmt.Errorf("拆分inscription脚本失败: %w", err)
+	}
+
+	result := &dogeInscriptionVectorExpect{
+		InscriptionScript: hex.EncodeToString(inscriptionScript),
+		Partials:          make([]string, 0, len(partials)),
+		LockScripts:       make([]string, 0, len(partials)),
+		P2SHScripts:       make([]string, 0, len(partials)),
+	}
+	for _, partial := range partials {
+		lockScript, err := buildInscriptionLockScript(publicKeyBytes, partial)
+		if err != nil {
+			return nil, err
+		}
+		p2shScript, err := BuildDogeP2SHScript(lockScript)
+		if err != nil {
+			return nil, err
+		}
+		result.Partials = append(result.Partials, hex.EncodeToString(partial))
+		result.LockScripts = append(result.LockScripts, hex.EncodeToString(lockScript))
+		result.P2SHScripts = append(result.P2SHScripts, hex.EncodeToString(p2shScript))
+	}
+
+	if v.Kind != dogeVectorKindChain {
+		return result, nil
+	}
+
+	ins := make([]*TxInputUtxo, 0, len(v.Input.Utxos))
+	for _, utxo := range v.Input.Utxos {
+		ins = append(ins, &TxInputUtxo{
+			TxId:     utxo.TxId,
+			TxIndex:  utxo.TxIndex,
+			PkScript: utxo.PkScript,
+			Amount:   utxo.Amount,
+			PriHex:   utxo.PriHex,
+			SignMode: SignModeLegacy,
+		})
+	}
+	chain, err := buildDogeMetaIdInscriptionTxsWithKey(privateKey, DogeMainNetParams, data, v.Input.ContentType, ins,
+		v.Input.OutputAddress, v.Input.OutputValue, v.Input.ChangeAddress, v.Input.FeeRate, false, format)
+	if err != nil {
+		return nil, fmt.Errorf("构建交易链失败: %w", err)
+	}
+	for _, detail := range chain.Details {
+		result.RawTxs = append(result.RawTxs, detail.TxHex)
+	}
+	return result, nil
+}
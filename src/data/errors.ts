@@ -2,4 +2,15 @@ export const ERRORS = {
   INSUFFICIENT_LIQUIDITY: 'Insufficient liquidity for this trade.',
   HAVE_NOT_CHOOSE_GAS_RATE: "You haven't chosen a gas rate.",
   NO_RUNES: 'No runes found.',
+  // DOGE 铭刻后端返回的错误码（common_doge_errors.go 的 DogeErrorCode）
+  INSUFFICIENT_FUNDS: 'Insufficient balance to cover the outputs and network fee.',
+  INVALID_ADDRESS: 'Invalid address.',
+  NOT_INSCRIPTION: 'This transaction does not contain an inscription.',
+  SCRIPT_SIG_TOO_LARGE: 'Inscription data is too large for a standard transaction.',
+  DUST_OUTPUT: 'Output amount is below the dust limit.',
+  NOT_DRC20: 'This inscription is not a valid DRC-20 operation.',
+  NOT_MRC20: 'This PIN is not a valid MRC-20 operation.',
+  PIN_KEY_MISMATCH: 'This PIN is encrypted for a different key.',
+  INVALID_OPTION: 'Invalid inscription parameters.',
+  INVALID_UTXO: 'Invalid UTXO.',
 }
@@ -9,7 +9,7 @@
  * 2. Reveal TX: 花费 P2SH 输出，ScriptSig 包含铭刻数据
  * 
  * ScriptSig 结构:
- * <metaid> <operation> <contentType> <encryption> <version> <path> <body> <signature> <redeem_script>
+ * <metaid> <operation> <path> <encryption> <version> <contentType> <body> <signature> <redeem_script>
  * 
  * Redeem Script 结构:
  * <pubkey> OP_CHECKSIGVERIFY OP_DROP OP_DROP ... OP_TRUE
@@ -21,6 +21,7 @@ import { Buffer } from 'buffer'
 import { getDogeWallet } from './wallet'
 import { fetchDogeUtxos } from '../../../queries/doge/utxos'
 import { broadcastDogeTx } from '../../../queries/doge/transaction'
+import { pushData, buildMetaIdInscriptionScript, buildLockScript, buildP2SHOutputScript } from '../../doge/inscription-script'
 
 // 延迟初始化，避免在模块加载时就导入 @bitcoinerlab/secp256k1
 // 这样可以避免与 bitcore-lib 的 crypto 模块冲突
@@ -65,10 +66,9 @@ const dogeTestnet: bitcoin.Network = {
 }
 
 // 常量定义
-const MAX_CHUNK_LEN = 240
 const MAX_PAYLOAD_LEN = 1500
 const DEFAULT_OUTPUT_VALUE = 1000000 // 0.01 DOGE
-const DUST_LIMIT = 600 // DOGE dust limit
+const DUST_LIMIT = 100000 // DOGE 硬 dust 限制（0.001 DOGE），与 Go 后端 DogeDustLimit 一致
 
 export type Operation = 'init' | 'create' | 'modify' | 'revoke'
 
@@ -122,165 +122,6 @@ interface InscribeTxIdResult {
   totalCost: number
 }
 
-/**
- * 计算 HASH160 (RIPEMD160(SHA256(data)))
- */
-function hash160(data: Buffer): Buffer {
-  return bitcoin.crypto.hash160(data)
-}
-
-/**
- * 添加 push data 到脚本
- * 根据数据长度选择正确的操作码
- */
-function pushData(data: Buffer): Buffer {
-  const len = data.length
-  if (len === 0) {
-    return Buffer.from([bitcoin.opcodes.OP_0])
-  } else if (len < 76) {
-    // 直接使用长度作为操作码
-    return Buffer.concat([Buffer.from([len]), data])
-  } else if (len <= 0xff) {
-    return Buffer.concat([Buffer.from([bitcoin.opcodes.OP_PUSHDATA1, len]), data])
-  } else if (len <= 0xffff) {
-    const lenBuf = Buffer.alloc(2)
-    lenBuf.writeUInt16LE(len)
-    return Buffer.concat([Buffer.from([bitcoin.opcodes.OP_PUSHDATA2]), lenBuf, data])
-  } else {
-    const lenBuf = Buffer.alloc(4)
-    lenBuf.writeUInt32LE(len)
-    return Buffer.concat([Buffer.from([bitcoin.opcodes.OP_PUSHDATA4]), lenBuf, data])
-  }
-}
-
-/**
- * 构建 MetaID 格式的 inscription 脚本
- * 字段顺序: metaid, operation, contentType, encryption, version, path, body
- */
-function buildMetaIdInscriptionScript(data: MetaidData): Buffer {
-  const body = typeof data.body === 'string' 
-    ? Buffer.from(data.body, data.encoding || 'utf8') 
-    : data.body || Buffer.alloc(0)
-
-  // 将 body 数据分块
-  const bodyParts: Buffer[] = []
-  for (let i = 0; i < body.length; i += MAX_CHUNK_LEN) {
-    bodyParts.push(body.slice(i, Math.min(i + MAX_CHUNK_LEN, body.length)))
-  }
-  
-  // 如果 body 为空，添加一个空的 part
-  if (bodyParts.length === 0) {
-    bodyParts.push(Buffer.alloc(0))
-  }
-
-  // 构建 inscription 脚本
-  // 顺序: metaid, operation, contentType, encryption, version, path, body
-  const chunks: Buffer[] = []
-  
-  // 1. metaid (标识符)
-  chunks.push(pushData(Buffer.from('metaid')))
-  
-  // 2. operation (操作类型)
-  chunks.push(pushData(Buffer.from(data.operation)))
-  
-  // 3. contentType (内容类型)
-  chunks.push(pushData(Buffer.from(data.contentType || 'text/plain')))
-  
-  // 4. encryption (加密标志)
-  chunks.push(pushData(Buffer.from(data.encryption || '0')))
-  
-  // 5. version (版本)
-  chunks.push(pushData(Buffer.from(data.version || '0.0.1')))
-  
-  // 6. path (路径)
-  chunks.push(pushData(Buffer.from(data.path || '')))
-  
-  // 7. body (内容，可能有多个分块)
-  for (const part of bodyParts) {
-    chunks.push(pushData(part))
-  }
-
-  return Buffer.concat(chunks)
-}
-
-/**
- * 计算脚本中的 chunk 数量
- * 用于确定需要多少个 OP_DROP
- */
-function countScriptChunks(script: Buffer): number {
-  let count = 0
-  let i = 0
-
-  while (i < script.length) {
-    const opcode = script[i]
-
-    if (opcode === 0) {
-      // OP_0
-      count++
-      i++
-    } else if (opcode >= 1 && opcode <= 75) {
-      // 直接 push opcode 个字节
-      count++
-      i += 1 + opcode
-    } else if (opcode === bitcoin.opcodes.OP_PUSHDATA1) {
-      const len = script[i + 1]
-      count++
-      i += 2 + len
-    } else if (opcode === bitcoin.opcodes.OP_PUSHDATA2) {
-      const len = script[i + 1] | (script[i + 2] << 8)
-      count++
-      i += 3 + len
-    } else if (opcode === bitcoin.opcodes.OP_PUSHDATA4) {
-      const len = script[i + 1] | (script[i + 2] << 8) | (script[i + 3] << 16) | (script[i + 4] << 24)
-      count++
-      i += 5 + len
-    } else {
-      // 其他操作码
-      i++
-    }
-  }
-
-  return count
-}
-
-/**
- * 构建 Lock 脚本 (Redeem Script)
- * 结构: <pubkey> OP_CHECKSIGVERIFY OP_DROP OP_DROP ... OP_TRUE
- */
-function buildLockScript(publicKey: Buffer, inscriptionScript: Buffer): Buffer {
-  const chunks: Buffer[] = []
-
-  // 1. 添加公钥
-  chunks.push(pushData(publicKey))
-
-  // 2. 添加 OP_CHECKSIGVERIFY
-  chunks.push(Buffer.from([bitcoin.opcodes.OP_CHECKSIGVERIFY]))
-
-  // 3. 为 inscription 脚本中的每个 chunk 添加 OP_DROP
-  const dropCount = countScriptChunks(inscriptionScript)
-  for (let i = 0; i < dropCount; i++) {
-    chunks.push(Buffer.from([bitcoin.opcodes.OP_DROP]))
-  }
-
-  // 4. 添加 OP_TRUE
-  chunks.push(Buffer.from([bitcoin.opcodes.OP_TRUE]))
-
-  return Buffer.concat(chunks)
-}
-
-/**
- * 构建 P2SH 输出脚本
- * 结构: OP_HASH160 <hash160(lockScript)> OP_EQUAL
- */
-function buildP2SHOutputScript(lockScript: Buffer): Buffer {
-  const lockHash = hash160(lockScript)
-  return Buffer.concat([
-    Buffer.from([bitcoin.opcodes.OP_HASH160]),
-    pushData(lockHash),
-    Buffer.from([bitcoin.opcodes.OP_EQUAL]),
-  ])
-}
-
 /**
  * 构建 P2PKH 输出脚本
  */
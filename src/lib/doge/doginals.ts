@@ -0,0 +1,122 @@
+/**
+ * Doginal Inscription Scripts
+ *
+ * doginals.js（apezord/doginals）中 inscribe 的脚本部分，按原实现逐行移植:
+ * inscription 脚本、partial 拆分和 lock 脚本。不依赖钱包和网络，
+ * 供需要构建或校验 Doginal 的模块和 test-doge-inscription-vectors.ts 使用。
+ *
+ * 与 Go 后端的唯一差异: doginals.js 把 1 字节的 0x01-0x10 数据用 OP_DATA_1 推送，
+ * Go 按 MINIMALDATA 使用 OP_1-OP_16，这里保留 doginals.js 的行为
+ */
+
+import * as bitcoin from 'bitcoinjs-lib'
+import { Buffer } from 'buffer'
+import { MAX_CHUNK_LEN, hash160 } from './inscription-script'
+
+export const MAX_PAYLOAD_LEN = 1500
+
+/** bitcore Script 的 chunk */
+export type Chunk = { buf?: Buffer; len?: number; opcodenum: number }
+
+export function bufferToChunk(b: Buffer): Chunk {
+  return {
+    buf: b.length ? b : undefined,
+    len: b.length,
+    opcodenum: b.length <= 75 ? b.length : b.length <= 255 ? 76 : 77,
+  }
+}
+
+export function numberToChunk(n: number): Chunk {
+  return {
+    buf: n <= 16 ? undefined : n < 128 ? Buffer.from([n]) : Buffer.from([n % 256, n / 256]),
+    len: n <= 16 ? 0 : n < 128 ? 1 : 2,
+    opcodenum: n == 0 ? 0 : n <= 16 ? 80 + n : n < 128 ? 1 : 2,
+  }
+}
+
+export function opcodeToChunk(op: number): Chunk {
+  return { opcodenum: op }
+}
+
+export function chunksToBuffer(chunks: Chunk[]): Buffer {
+  return Buffer.concat(
+    chunks.map((c) => {
+      if (!c.buf) return Buffer.from([c.opcodenum])
+      if (c.opcodenum < 76) return Buffer.concat([Buffer.from([c.opcodenum]), c.buf])
+      if (c.opcodenum === 76) return Buffer.concat([Buffer.from([76, c.len!]), c.buf])
+      const len = Buffer.alloc(2)
+      len.writeUInt16LE(c.len!)
+      return Buffer.concat([Buffer.from([77]), len, c.buf])
+    })
+  )
+}
+
+/**
+ * 构建 Doginal inscription 脚本
+ * 结构: "ord" <分片数> <contentType> (<剩余分片数> <数据分片>)...
+ */
+export function buildDoginalInscription(data: Buffer, contentType: string): Chunk[] {
+  const parts: Buffer[] = []
+  while (data.length) {
+    const part = data.slice(0, Math.min(MAX_CHUNK_LEN, data.length))
+    data = data.slice(part.length)
+    parts.push(part)
+  }
+
+  const chunks: Chunk[] = []
+  chunks.push(bufferToChunk(Buffer.from('ord')))
+  chunks.push(numberToChunk(parts.length))
+  chunks.push(bufferToChunk(Buffer.from(contentType)))
+  parts.forEach((part, n) => {
+    chunks.push(numberToChunk(parts.length - n - 1))
+    chunks.push(bufferToChunk(part))
+  })
+  return chunks
+}
+
+/**
+ * 把 inscription 拆分为 partial，每个 partial 放入一笔交易的 ScriptSig
+ * 第一个 partial 单独带上 "ord"，之后按 (index, data) 成对拆分
+ */
+export function splitDoginalPartials(inscription: Chunk[]): Chunk[][] {
+  const chunks = [...inscription]
+  const partials: Chunk[][] = []
+  while (chunks.length) {
+    const partial: Chunk[] = []
+    if (partials.length == 0) {
+      partial.push(chunks.shift()!)
+    }
+    while (chunksToBuffer(partial).length <= MAX_PAYLOAD_LEN && chunks.length) {
+      partial.push(chunks.shift()!)
+      partial.push(chunks.shift()!)
+    }
+    if (chunksToBuffer(partial).length > MAX_PAYLOAD_LEN) {
+      chunks.unshift(partial.pop()!)
+      chunks.unshift(partial.pop()!)
+    }
+    partials.push(partial)
+  }
+  return partials
+}
+
+/**
+ * 构建 partial 的 lock 脚本
+ * 结构: <pubkey> OP_CHECKSIGVERIFY OP_DROP... OP_TRUE，每个 chunk 一个 OP_DROP
+ */
+export function buildDoginalLock(publicKey: Buffer, partial: Chunk[]): Buffer {
+  const lock: Chunk[] = [bufferToChunk(publicKey), opcodeToChunk(bitcoin.opcodes.OP_CHECKSIGVERIFY)]
+  partial.forEach(() => lock.push(opcodeToChunk(bitcoin.opcodes.OP_DROP)))
+  lock.push(opcodeToChunk(bitcoin.opcodes.OP_TRUE))
+  return chunksToBuffer(lock)
+}
+
+/**
+ * P2SH 输出脚本: OP_HASH160 <hash160(lock)> OP_EQUAL
+ */
+export function doginalP2SHScript(lock: Buffer): Buffer {
+  return Buffer.concat([
+    Buffer.from([bitcoin.opcodes.OP_HASH160, 20]),
+    hash160(lock),
+    Buffer.from([bitcoin.opcodes.OP_EQUAL]),
+  ])
+}
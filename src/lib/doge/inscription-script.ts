@@ -0,0 +1,186 @@
+/**
+ * DOGE Inscription Scripts
+ *
+ * MetaID 铭刻脚本的纯函数部分（不依赖钱包和网络），供 actions/doge/inscribe.ts
+ * 和与 Go 后端对照的 test-doge-inscription-vectors.ts 共同使用
+ */
+
+import * as bitcoin from 'bitcoinjs-lib'
+import { Buffer } from 'buffer'
+
+export const MAX_CHUNK_LEN = 240
+
+export type MetaidScriptData = {
+  body?: string | Buffer
+  operation: string
+  path?: string
+  contentType?: string
+  encryption?: '0' | '1' | '2'
+  version?: string
+  encoding?: BufferEncoding
+}
+
+/**
+ * 计算 HASH160 (RIPEMD160(SHA256(data)))
+ */
+export function hash160(data: Buffer): Buffer {
+  return bitcoin.crypto.hash160(data)
+}
+
+/**
+ * 添加 push data 到脚本
+ * 根据数据长度选择正确的操作码
+ */
+export function pushData(data: Buffer): Buffer {
+  const len = data.length
+  if (len === 0) {
+    return Buffer.from([bitcoin.opcodes.OP_0])
+  } else if (len === 1 && data[0] >= 1 && data[0] <= 16) {
+    // MINIMALDATA: 单字节 1-16 必须使用 OP_1-OP_16，否则节点不转发
+    return Buffer.from([bitcoin.opcodes.OP_1 + data[0] - 1])
+  } else if (len === 1 && data[0] === 0x81) {
+    return Buffer.from([bitcoin.opcodes.OP_1NEGATE])
+  } else if (len < 76) {
+    // 直接使用长度作为操作码
+    return Buffer.concat([Buffer.from([len]), data])
+  } else if (len <= 0xff) {
+    return Buffer.concat([Buffer.from([bitcoin.opcodes.OP_PUSHDATA1, len]), data])
+  } else if (len <= 0xffff) {
+    const lenBuf = Buffer.alloc(2)
+    lenBuf.writeUInt16LE(len)
+    return Buffer.concat([Buffer.from([bitcoin.opcodes.OP_PUSHDATA2]), lenBuf, data])
+  } else {
+    const lenBuf = Buffer.alloc(4)
+    lenBuf.writeUInt32LE(len)
+    return Buffer.concat([Buffer.from([bitcoin.opcodes.OP_PUSHDATA4]), lenBuf, data])
+  }
+}
+
+/**
+ * 构建 MetaID 格式的 inscription 脚本
+ * 字段顺序（MetaID 规范，与 Go 后端 BuildDogeMetaIdPinInscription 一致）:
+ * metaid, operation, path, encryption, version, contentType, body
+ */
+export function buildMetaIdInscriptionScript(data: MetaidScriptData): Buffer {
+  const body = typeof data.body === 'string' 
+    ? Buffer.from(data.body, data.encoding || 'utf8') 
+    : data.body || Buffer.alloc(0)
+
+  // 将 body 数据分块
+  const bodyParts: Buffer[] = []
+  for (let i = 0; i < body.length; i += MAX_CHUNK_LEN) {
+    bodyParts.push(body.slice(i, Math.min(i + MAX_CHUNK_LEN, body.length)))
+  }
+  
+  // 如果 body 为空，添加一个空的 part
+  if (bodyParts.length === 0) {
+    bodyParts.push(Buffer.alloc(0))
+  }
+
+  // 构建 inscription 脚本
+  // 顺序: metaid, operation, path, encryption, version, contentType, body
+  const chunks: Buffer[] = []
+  
+  // 1. metaid (标识符)
+  chunks.push(pushData(Buffer.from('metaid')))
+  
+  // 2. operation (操作类型)
+  chunks.push(pushData(Buffer.from(data.operation)))
+  
+  // 3. path (路径)
+  chunks.push(pushData(Buffer.from(data.path || '')))
+  
+  // 4. encryption (加密标志)
+  chunks.push(pushData(Buffer.from(data.encryption || '0')))
+  
+  // 5. version (版本)
+  chunks.push(pushData(Buffer.from(data.version || '0.0.1')))
+  
+  // 6. contentType (内容类型)
+  chunks.push(pushData(Buffer.from(data.contentType || 'text/plain')))
+  
+  // 7. body (内容，可能有多个分块)
+  for (const part of bodyParts) {
+    chunks.push(pushData(part))
+  }
+
+  return Buffer.concat(chunks)
+}
+
+/**
+ * 计算脚本中的 chunk 数量
+ * 用于确定需要多少个 OP_DROP
+ */
+export function countScriptChunks(script: Buffer): number {
+  let count = 0
+  let i = 0
+
+  while (i < script.length) {
+    const opcode = script[i]
+
+    if (opcode === 0) {
+      // OP_0
+      count++
+      i++
+    } else if (opcode >= 1 && opcode <= 75) {
+      // 直接 push opcode 个字节
+      count++
+      i += 1 + opcode
+    } else if (opcode === bitcoin.opcodes.OP_PUSHDATA1) {
+      const len = script[i + 1]
+      count++
+      i += 2 + len
+    } else if (opcode === bitcoin.opcodes.OP_PUSHDATA2) {
+      const len = script[i + 1] | (script[i + 2] << 8)
+      count++
+      i += 3 + len
+    } else if (opcode === bitcoin.opcodes.OP_PUSHDATA4) {
+      const len = script[i + 1] | (script[i + 2] << 8) | (script[i + 3] << 16) | (script[i + 4] << 24)
+      count++
+      i += 5 + len
+    } else {
+      // 其他操作码
+      i++
+    }
+  }
+
+  return count
+}
+
+/**
+ * 构建 Lock 脚本 (Redeem Script)
+ * 结构: <pubkey> OP_CHECKSIGVERIFY OP_DROP OP_DROP ... OP_TRUE
+ */
+export function buildLockScript(publicKey: Buffer, inscriptionScript: Buffer): Buffer {
+  const chunks: Buffer[] = []
+
+  // 1. 添加公钥
+  chunks.push(pushData(publicKey))
+
+  // 2. 添加 OP_CHECKSIGVERIFY
+  chunks.push(Buffer.from([bitcoin.opcodes.OP_CHECKSIGVERIFY]))
+
+  // 3. 为 inscription 脚本中的每个 chunk 添加 OP_DROP
+  const dropCount = countScriptChunks(inscriptionScript)
+  for (let i = 0; i < dropCount; i++) {
+    chunks.push(Buffer.from([bitcoin.opcodes.OP_DROP]))
+  }
+
+  // 4. 添加 OP_TRUE
+  chunks.push(Buffer.from([bitcoin.opcodes.OP_TRUE]))
+
+  return Buffer.concat(chunks)
+}
+
+/**
+ * 构建 P2SH 输出脚本
+ * 结构: OP_HASH160 <hash160(lockScript)> OP_EQUAL
+ */
+export function buildP2SHOutputScript(lockScript: Buffer): Buffer {
+  const lockHash = hash160(lockScript)
+  return Buffer.concat([
+    Buffer.from([bitcoin.opcodes.OP_HASH160]),
+    pushData(lockHash),
+    Buffer.from([bitcoin.opcodes.OP_EQUAL]),
+  ])
+}
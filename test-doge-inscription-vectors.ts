@@ -0,0 +1,253 @@
+/**
+ * 对照 Go 后端的 DOGE 铭刻 golden vectors
+ *
+ * testdata/doge_inscription_vectors.json 由 Go 侧（common_doge_vectors_test.go，go test -update）生成和校验，
+ * 这里用插件的 MetaID 脚本构建函数（inscription-script.ts，inscribe.ts 使用）和 Doginal 脚本构建函数
+ * （doginals.ts，doginals.js 的移植）重新计算，逐字节比较；chain 向量重新签名每笔交易并比较原始交易。
+ * knownDivergences 中登记的差异只提示，不计入失败，每一条都必须写明原因。
+ */
+
+import * as fs from 'fs'
+import * as bitcoin from 'bitcoinjs-lib'
+import * as ecc from '@bitcoinerlab/secp256k1'
+import { Buffer } from 'buffer'
+import { dogeMainnet } from './src/lib/doge/network'
+import {
+  pushData,
+  buildMetaIdInscriptionScript,
+  buildLockScript,
+  buildP2SHOutputScript,
+} from './src/lib/doge/inscription-script'
+import {
+  chunksToBuffer,
+  buildDoginalInscription,
+  splitDoginalPartials,
+  buildDoginalLock,
+  doginalP2SHScript,
+} from './src/lib/doge/doginals'
+
+const DUST_LIMIT = 100000
+
+type Vector = {
+  name: string
+  kind: 'script' | 'chain'
+  format: 'doginal' | 'metaid'
+  input: {
+    dataHex: string
+    contentType: string
+    privateKeyHex: string
+    utxos?: { txId: string; txIndex: number; pkScript: string; amount: number; priHex: string }[]
+    outputAddress?: string
+    outputValue?: number
+    changeAddress?: string
+    feeRate?: number
+  }
+  expect: {
+    inscriptionScript: string
+    partials: string[]
+    lockScripts: string[]
+    p2shScripts: string[]
+    rawTxs?: string[]
+  }
+  knownDivergences?: Record<string, string>
+}
+
+// ===== 各实现的计算结果 =====
+
+type Computed = {
+  inscriptionScript: string
+  partials: string[]
+  lockScripts: string[]
+  p2shScripts: string[]
+}
+
+function computeDoginals(v: Vector, publicKey: Buffer): Computed {
+  const inscription = buildDoginalInscription(Buffer.from(v.input.dataHex, 'hex'), v.input.contentType)
+  const partials = splitDoginalPartials(inscription)
+  const locks = partials.map((p) => buildDoginalLock(publicKey, p))
+  return {
+    inscriptionScript: chunksToBuffer(inscription).toString('hex'),
+    partials: partials.map((p) => chunksToBuffer(p).toString('hex')),
+    lockScripts: locks.map((l) => l.toString('hex')),
+    p2shScripts: locks.map((l) => doginalP2SHScript(l).toString('hex')),
+  }
+}
+
+function computeInscribeTs(v: Vector, publicKey: Buffer): Computed {
+  // Go 的 BuildDogeMetaIdInscription 把 contentType 参数作为 path，contentType 默认 application/json
+  const inscription = buildMetaIdInscriptionScript({
+    operation: 'create',
+    path: v.input.contentType,
+    contentType: 'application/json',
+    encryption: '0',
+    version: '0.0.1',
+    body: Buffer.from(v.input.dataHex, 'hex'),
+  })
+  // inscribe.ts 不拆分 partial，整个 inscription 放在一个 reveal 中
+  const lock = buildLockScript(publicKey, inscription)
+  return {
+    inscriptionScript: inscription.toString('hex'),
+    partials: [inscription.toString('hex')],
+    lockScripts: [lock.toString('hex')],
+    p2shScripts: [buildP2SHOutputScript(lock).toString('hex')],
+  }
+}
+
+function diff(actual: Computed, expect: Vector['expect']): string | null {
+  if (actual.inscriptionScript !== expect.inscriptionScript) return 'inscription 脚本不一致'
+  const fields: [string, string[], string[]][] = [
+    ['partial', actual.partials, expect.partials],
+    ['lock 脚本', actual.lockScripts, expect.lockScripts],
+    ['P2SH 脚本', actual.p2shScripts, expect.p2shScripts],
+  ]
+  for (const [name, a, e] of fields) {
+    if (a.length !== e.length) return `${name} 数量不一致: ${a.length} != ${e.length}`
+    for (let i = 0; i < a.length; i++) {
+      if (a[i] !== e[i]) return `第 ${i} 个 ${name} 不一致`
+    }
+  }
+  return null
+}
+
+// chain 向量：用向量中的私钥重新签名每笔交易的所有输入，再与 rawTxs 逐字节比较
+// Go（btcec）和 @bitcoinerlab/secp256k1 都使用 RFC6979 确定性 low-S 签名，结果必须完全一致
+type PrevOut = { script: Buffer; value: number }
+
+function signInput(tx: bitcoin.Transaction, index: number, subscript: Buffer, privateKey: Buffer): Buffer {
+  const hash = tx.hashForSignature(index, subscript, bitcoin.Transaction.SIGHASH_ALL)
+  const signature = Buffer.from(ecc.sign(hash, privateKey))
+  return bitcoin.script.signature.encode(signature, bitcoin.Transaction.SIGHASH_ALL)
+}
+
+function checkChain(v: Vector): string | null {
+  const rawTxs = v.expect.rawTxs || []
+  if (rawTxs.length !== v.expect.partials.length + 1) {
+    return `交易数量应为 partial 数量 + 1: ${rawTxs.length}`
+  }
+  const tempKey = Buffer.from(v.input.privateKeyHex, 'hex')
+
+  // P2PKH 输出脚本 -> 私钥，找零输出也由同一个钱包私钥签名
+  const walletKeys = new Map<string, Buffer>()
+  const prevOuts = new Map<string, PrevOut>()
+  for (const utxo of v.input.utxos || []) {
+    const key = Buffer.from(utxo.priHex, 'hex')
+    const publicKey = Buffer.from(ecc.pointFromScalar(key, true)!)
+    walletKeys.set(bitcoin.payments.p2pkh({ pubkey: publicKey }).output!.toString('hex'), key)
+    prevOuts.set(`${utxo.txId}:${utxo.txIndex}`, { script: Buffer.from(utxo.pkScript, 'hex'), value: utxo.amount })
+  }
+
+  for (let i = 0; i < rawTxs.length; i++) {
+    const tx = bitcoin.Transaction.fromHex(rawTxs[i])
+    const last = i === rawTxs.length - 1
+
+    // 输出 0: 前面的交易是下一个 partial 的 P2SH，最后一笔是 reveal 输出
+    if (!last && tx.outs[0].script.toString('hex') !== v.expect.p2shScripts[i]) {
+      return `交易 ${i} 的输出 0 不是 partial ${i} 的 P2SH`
+    }
+    if (last) {
+      const revealScript = bitcoin.address.toOutputScript(v.input.outputAddress!, dogeMainnet)
+      if (!tx.outs[0].script.equals(revealScript) || tx.outs[0].value !== v.input.outputValue) {
+        return `交易 ${i} 的输出 0 不是 reveal 输出`
+      }
+    }
+    let totalIn = 0
+    let totalOut = 0
+    for (const out of tx.outs) {
+      if (out.value < DUST_LIMIT) return `交易 ${i} 有低于 dust 限制的输出: ${out.value}`
+      totalOut += out.value
+    }
+
+    const resigned = tx.clone()
+    for (let j = 0; j < tx.ins.length; j++) {
+      const outpoint = `${Buffer.from(tx.ins[j].hash).reverse().toString('hex')}:${tx.ins[j].index}`
+      const prev = prevOuts.get(outpoint)
+      if (!prev) return `交易 ${i} 的输入 ${j} 引用了未知的输出 ${outpoint}`
+      prevOuts.delete(outpoint)
+      totalIn += prev.value
+
+      if (j === 0 && i > 0) {
+        if (outpoint !== `${bitcoin.Transaction.fromHex(rawTxs[i - 1]).getId()}:0`) {
+          return `交易 ${i} 的第一个输入没有花费上一笔交易的 P2SH 输出`
+        }
+        // <partial> <signature> <lock>
+        const lock = Buffer.from(v.expect.lockScripts[i - 1], 'hex')
+        resigned.setInputScript(
+          j,
+          Buffer.concat([
+            Buffer.from(v.expect.partials[i - 1], 'hex'),
+            pushData(signInput(resigned, j, lock, tempKey)),
+            pushData(lock),
+          ])
+        )
+        continue
+      }
+      const key = walletKeys.get(prev.script.toString('hex'))
+      if (!key) return `交易 ${i} 的输入 ${j} 不是钱包的 P2PKH 输出`
+      const publicKey = Buffer.from(ecc.pointFromScalar(key, true)!)
+      resigned.setInputScript(j, Buffer.concat([pushData(signInput(resigned, j, prev.script, key)), pushData(publicKey)]))
+    }
+    if (resigned.toHex() !== rawTxs[i]) {
+      return `交易 ${i} 重新签名后与 rawTx 不一致`
+    }
+
+    const fee = totalIn - totalOut
+    if (fee < (rawTxs[i].length / 2) * v.input.feeRate!) {
+      return `交易 ${i} 的手续费 ${fee} 低于费率 ${v.input.feeRate}`
+    }
+    const txId = tx.getId()
+    tx.outs.forEach((out, n) => prevOuts.set(`${txId}:${n}`, { script: out.script, value: out.value }))
+  }
+  return null
+}
+
+// ===== 主流程 =====
+
+const vectors: Vector[] = JSON.parse(
+  fs.readFileSync(new URL('./testdata/doge_inscription_vectors.json', import.meta.url), 'utf8')
+).vectors
+
+console.log('=== DOGE 铭刻 golden vectors 对照测试 ===\n')
+
+let passed = 0
+let failed = 0
+let known = 0
+
+function report(v: Vector, impl: string, problem: string | null) {
+  const reason = v.knownDivergences?.[impl]
+  if (!problem && !reason) {
+    console.log(`✅ ${v.name} [${impl}]`)
+    passed++
+  } else if (!problem && reason) {
+    console.log(`❌ ${v.name} [${impl}] - 登记了已知差异但结果一致，请删除 knownDivergences`)
+    failed++
+  } else if (problem && reason) {
+    console.log(`⚠️  ${v.name} [${impl}] - 已知差异: ${problem}`)
+    console.log(`   原因: ${reason}`)
+    known++
+  } else {
+    console.log(`❌ ${v.name} [${impl}] - ${problem}`)
+    failed++
+  }
+}
+
+for (const v of vectors) {
+  try {
+    const publicKey = Buffer.from(ecc.pointFromScalar(Buffer.from(v.input.privateKeyHex, 'hex'), true)!)
+    if (v.format === 'doginal') {
+      report(v, 'doginals.js', diff(computeDoginals(v, publicKey), v.expect))
+    } else {
+      report(v, 'inscribe.ts', diff(computeInscribeTs(v, publicKey), v.expect))
+    }
+    if (v.kind === 'chain') {
+      report(v, 'chain', checkChain(v))
+    }
+  } catch (e) {
+    console.log(`❌ ${v.name} - 错误: ${e}`)
+    failed++
+  }
+}
+
+console.log(`\n总计: ${passed} 通过, ${known} 已知差异, ${failed} 失败`)
+if (failed > 0) {
+  process.exit(1)
+}